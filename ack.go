@@ -0,0 +1,124 @@
+package rediswatcher
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// UpdateAndWait publishes an update asking every receiver to acknowledge it
+// once its update callback succeeded, and waits until quorum watchers did or
+// ctx is done. A quorum of 0 waits for every live watcher of the
+// PresenceRegistry. It returns the IDs of the watchers that acknowledged,
+// with ctx.Err() when the quorum was not reached, e.g. for revocations that
+// must provably have propagated.
+//
+// Acknowledgements are published on the watcher channel followed by ":ack",
+// which the watcher subscribes to on first use. Squashed or debounced
+// updates are acknowledged only when they are the one delivered.
+func (w *Watcher) UpdateAndWait(ctx context.Context, quorum int) ([]string, error) {
+	if w.subDone == nil {
+		return nil, errors.New("rediswatcher: UpdateAndWait needs a subscribing watcher")
+	}
+
+	var expected map[string]bool
+	if quorum <= 0 {
+		entries, err := w.Presence()
+		if err != nil {
+			return nil, err
+		}
+		expected = make(map[string]bool)
+		for _, e := range entries {
+			if e.Alive && !(w.options.IgnoreSelf && e.ID == w.options.LocalID) {
+				expected[e.ID] = true
+			}
+		}
+		quorum = len(expected)
+	}
+
+	reply := w.channel() + ":ack"
+	if err := w.subscribeChannel(reply); err != nil {
+		return nil, err
+	}
+	nonce := uuid.New().String()
+	received := w.awaitReplies(nonce, quorum+16)
+	defer w.stopReplies(nonce)
+
+	if err := w.waitBeforePublish(); err != nil {
+		return nil, err
+	}
+	if err := w.publishMessage(Message{Type: MessageTypeUpdate, ID: w.options.LocalID, Nonce: nonce, Reply: reply}); err != nil {
+		return nil, err
+	}
+
+	var acked []string
+	seen := make(map[string]bool)
+	for len(acked) < quorum {
+		select {
+		case ack := <-received:
+			id := ack.ID
+			if seen[id] || (expected != nil && !expected[id]) {
+				continue
+			}
+			seen[id] = true
+			acked = append(acked, id)
+		case <-ctx.Done():
+			return acked, ctx.Err()
+		case <-w.closed:
+			return acked, ErrClosed
+		}
+	}
+	return acked, nil
+}
+
+// acknowledge answers an update sent by UpdateAndWait after the update
+// callback handled it.
+func (w *Watcher) acknowledge(data string) {
+	m, ok := decodeMessage(data)
+	if !ok || m.Reply == "" || m.Nonce == "" {
+		return
+	}
+	ack := encodeMessage(Message{Type: MessageTypeAck, ID: w.options.LocalID, Nonce: m.Nonce})
+
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err == nil {
+		_, err = c.Do("PUBLISH", m.Reply, ack)
+	}
+	if err != nil {
+		w.reportError(err)
+	}
+}
+
+// awaitReplies registers a channel receiving up to size answers to the
+// request with nonce, until stopReplies.
+func (w *Watcher) awaitReplies(nonce string, size int) chan Message {
+	received := make(chan Message, size)
+	w.probeMu.Lock()
+	if w.replies == nil {
+		w.replies = make(map[string]chan Message)
+	}
+	w.replies[nonce] = received
+	w.probeMu.Unlock()
+	return received
+}
+
+func (w *Watcher) stopReplies(nonce string) {
+	w.probeMu.Lock()
+	delete(w.replies, nonce)
+	w.probeMu.Unlock()
+}
+
+func (w *Watcher) replyReceived(m Message) {
+	w.probeMu.Lock()
+	defer w.probeMu.Unlock()
+
+	if received, ok := w.replies[m.Nonce]; ok {
+		select {
+		case received <- m:
+		default:
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package rediswatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUpdateAndWait(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	w.subDone = make(chan struct{})
+
+	type result struct {
+		acked []string
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		acked, err := w.UpdateAndWait(context.Background(), 2)
+		done <- result{acked, err}
+	}()
+
+	var request Message
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if calls := pub.calls("PUBLISH"); len(calls) == 1 {
+			request, _ = decodeMessage(calls[0][1].(string))
+			break
+		}
+	}
+	if request.Nonce == "" || request.Reply != "/casbin:ack" {
+		t.Fatalf("Update should ask for acknowledgements, got %+v", request)
+	}
+
+	for _, id := range []string{"node2", "node2", "node3"} {
+		w.handleControlMessage(Message{Type: MessageTypeAck, ID: id, Nonce: request.Nonce})
+	}
+	select {
+	case r := <-done:
+		if r.err != nil || len(r.acked) != 2 || r.acked[0] != "node2" || r.acked[1] != "node3" {
+			t.Fatalf("UpdateAndWait should return the acknowledging watchers, got %v, %v", r.acked, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("UpdateAndWait should return once the quorum acknowledged")
+	}
+
+	w.SetUpdateCallback(func(string) {})
+	w.runCallback(encodeMessage(Message{Type: MessageTypeUpdate, ID: "node2", Nonce: "n1", Reply: "/casbin:ack"}))
+	calls := pub.calls("PUBLISH")
+	if len(calls) != 2 || calls[1][0] != "/casbin:ack" || calls[1][1] != `{"type":"ack","id":"node1","nonce":"n1"}` {
+		t.Fatalf("Handled update should be acknowledged, got %v", calls)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := w.UpdateAndWait(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatalf("UpdateAndWait should end with ctx, got %v", err)
+	}
+}
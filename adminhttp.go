@@ -0,0 +1,70 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// AdminHandler returns an http.Handler for operating a live watcher. Mount
+// it under a prefix with http.StripPrefix and protect it like any admin
+// endpoint:
+//
+//	POST /update       publish an update
+//	POST /resubscribe  drop and renew the subscription
+//	POST /pause        pause the callbacks, see Pause
+//	POST /resume       resume them, reporting the missed updates
+//	GET  /stats        the Stats as JSON
+//	GET  /debug        the DebugJSON dump
+func (w *Watcher) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	post := func(path string, action func() (interface{}, error)) {
+		mux.HandleFunc(path, func(rw http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				rw.Header().Set("Allow", http.MethodPost)
+				http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			result, err := action()
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(rw, result)
+		})
+	}
+
+	post("/update", func() (interface{}, error) {
+		return map[string]bool{"published": true}, w.Update()
+	})
+	post("/resubscribe", func() (interface{}, error) {
+		if err := w.Unsubscribe(); err != nil {
+			return nil, err
+		}
+		return map[string]bool{"resubscribing": true}, w.Resubscribe("")
+	})
+	post("/pause", func() (interface{}, error) {
+		w.Pause()
+		return map[string]bool{"paused": true}, nil
+	})
+	post("/resume", func() (interface{}, error) {
+		return map[string]int{"missed": w.Resume()}, nil
+	})
+	mux.HandleFunc("/stats", func(rw http.ResponseWriter, r *http.Request) {
+		writeJSON(rw, w.Stats())
+	})
+	mux.HandleFunc("/debug", func(rw http.ResponseWriter, r *http.Request) {
+		b, err := w.DebugJSON()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(b)
+	})
+	return mux
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(v)
+}
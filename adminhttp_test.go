@@ -0,0 +1,45 @@
+package rediswatcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	pub := NewTestConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	handler := http.StripPrefix("/admin", w.AdminHandler())
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	if rec := do("GET", "/admin/update"); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Actions should require POST, got %d", rec.Code)
+	}
+	if rec := do("POST", "/admin/update"); rec.Code != http.StatusOK || w.Stats().Published != 1 {
+		t.Fatalf("Update should be published, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("POST", "/admin/pause"); rec.Code != http.StatusOK || !w.Paused() {
+		t.Fatalf("Watcher should be paused, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("POST", "/admin/resume"); rec.Code != http.StatusOK || w.Paused() || !strings.Contains(rec.Body.String(), `"missed":0`) {
+		t.Fatalf("Watcher should be resumed, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("GET", "/admin/stats"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"Published":1`) {
+		t.Fatalf("Stats should be dumped, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("POST", "/admin/resubscribe"); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("A publish watcher cannot resubscribe, got %d %s", rec.Code, rec.Body)
+	}
+}
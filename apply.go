@@ -0,0 +1,192 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// liveOptions are the fields ApplyOptions may change on a running watcher.
+var liveOptions = map[string]bool{
+	"Channel":              true,
+	"Password":             true,
+	"DialTimeout":          true,
+	"DrainTimeout":         true,
+	"CallbackTimeout":      true,
+	"DebounceWindow":       true,
+	"resubscribeThreshold": true,
+}
+
+// ApplyOptions changes options of a running watcher without recreating it,
+// e.g. during a config rollout. Only Channel, Password, DialTimeout,
+// ResubscribeThreshold, DrainTimeout, CallbackTimeout and Debounce may
+// change; setters touching anything else return an error and apply
+// nothing.
+//
+// A new channel is subscribed before the old one is left, so no update is
+// lost in between, and publishing switches with it. A new password is used
+// for the connections dialed from then on; established ones stay
+// authenticated.
+func (w *Watcher) ApplyOptions(setters ...WatcherOption) error {
+	if w.isClosed() {
+		return ErrClosed
+	}
+
+	current := w.GetWatcherOptions()
+	next := current
+	// the setters see the unprefixed channel, as in the constructor
+	next.Channel = strings.TrimPrefix(next.Channel, current.ChannelPrefix)
+	for _, setter := range setters {
+		setter(&next)
+	}
+	if next.err != nil {
+		return next.err
+	}
+	next.Channel = current.ChannelPrefix + next.Channel
+	if fixed := changedOptions(current, next); len(fixed) > 0 {
+		return fmt.Errorf("rediswatcher: options %s cannot change on a running watcher", strings.Join(fixed, ", "))
+	}
+
+	old := current.Channel
+	if next.Channel == old {
+		w.setLiveOptions(next)
+		w.logEvent(levelInfo, "options applied", "channel", next.Channel)
+		return nil
+	}
+
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	subscribed := w.subDone != nil && w.isSubscribed()
+	if subscribed {
+		for _, c := range append(w.federatedConns(), w.subConn) {
+			if err := (redis.PubSubConn{Conn: c}).Subscribe(next.Channel); err != nil {
+				return err
+			}
+		}
+	}
+	w.setLiveOptions(next)
+	if subscribed {
+		for _, c := range append(w.federatedConns(), w.subConn) {
+			redis.PubSubConn{Conn: c}.Unsubscribe(old)
+		}
+	}
+	w.logEvent(levelInfo, "options applied", "channel", next.Channel)
+	return nil
+}
+
+// setLiveOptions copies the liveOptions of next to the watcher.
+func (w *Watcher) setLiveOptions(next WatcherOptions) {
+	w.optMu.Lock()
+	defer w.optMu.Unlock()
+	w.options.Channel = next.Channel
+	w.options.Password = next.Password
+	w.options.DialTimeout = next.DialTimeout
+	w.options.DrainTimeout = next.DrainTimeout
+	w.options.CallbackTimeout = next.CallbackTimeout
+	w.options.DebounceWindow = next.DebounceWindow
+	w.options.resubscribeThreshold = next.resubscribeThreshold
+}
+
+// The liveOptions are read through these accessors, so that ApplyOptions
+// can change them while the watcher runs.
+
+func (w *Watcher) channel() string {
+	w.optMu.RLock()
+	defer w.optMu.RUnlock()
+	return w.options.Channel
+}
+
+func (w *Watcher) password() string {
+	w.optMu.RLock()
+	defer w.optMu.RUnlock()
+	return w.options.Password
+}
+
+func (w *Watcher) dialTimeout() time.Duration {
+	w.optMu.RLock()
+	defer w.optMu.RUnlock()
+	return w.options.DialTimeout
+}
+
+func (w *Watcher) drainTimeout() time.Duration {
+	w.optMu.RLock()
+	defer w.optMu.RUnlock()
+	return w.options.DrainTimeout
+}
+
+func (w *Watcher) callbackTimeout() time.Duration {
+	w.optMu.RLock()
+	defer w.optMu.RUnlock()
+	return w.options.CallbackTimeout
+}
+
+func (w *Watcher) debounceWindow() time.Duration {
+	w.optMu.RLock()
+	defer w.optMu.RUnlock()
+	return w.options.DebounceWindow
+}
+
+func (w *Watcher) resubscribeThreshold() time.Duration {
+	w.optMu.RLock()
+	defer w.optMu.RUnlock()
+	return w.options.resubscribeThreshold
+}
+
+// changedOptions lists the fields other than the liveOptions that differ
+// between a and b. Functions, pointers and maps compare by identity.
+func changedOptions(a, b WatcherOptions) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var changed []string
+	for i := 0; i < va.NumField(); i++ {
+		name := va.Type().Field(i).Name
+		if !liveOptions[name] && !sameValue(va.Field(i), vb.Field(i)) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// sameValue compares a and b without Interface, so it works on unexported
+// fields too.
+func sameValue(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Func, reflect.Ptr, reflect.Map, reflect.Chan, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer()
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return a.Elem().Type() == b.Elem().Type() && sameValue(a.Elem(), b.Elem())
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !sameValue(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !sameValue(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package rediswatcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyOptions(t *testing.T) {
+	pub, sub := newRecordConn(), newRecordConn()
+	sub.GenericCommand("SUBSCRIBE").Expect(nil)
+	sub.GenericCommand("UNSUBSCRIBE").Expect(nil)
+	w, err := newWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		ManualStart(true), ChannelPrefix("prod:"), SubscriptionFailureCallback(func(error) {}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	w.subDone = make(chan struct{})
+	w.setSubscribed(true)
+	defer w.setSubscribed(false)
+
+	err = w.ApplyOptions(Channel("/policies"), Password("rotated"), Debounce(time.Second), ResubscribeThreshold(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed watcher.ApplyOptions(): %v", err)
+	}
+	opts := w.GetWatcherOptions()
+	if opts.Channel != "prod:/policies" || opts.Password != "rotated" || opts.DebounceWindow != time.Second || opts.resubscribeThreshold != time.Minute {
+		t.Fatalf("The options should be applied, got %+v", opts)
+	}
+	if s := sub.calls("SUBSCRIBE"); len(s) != 1 || s[0][0] != "prod:/policies" {
+		t.Fatalf("The new channel should be subscribed, got %v", s)
+	}
+	if u := sub.calls("UNSUBSCRIBE"); len(u) != 1 || u[0][0] != "prod:/casbin" {
+		t.Fatalf("The old channel should be left, got %v", u)
+	}
+
+	err = w.ApplyOptions(Debounce(2*time.Second), IgnoreSelf(true), PresenceRegistry("watchers", time.Second))
+	if err == nil || !strings.Contains(err.Error(), "IgnoreSelf") || !strings.Contains(err.Error(), "presenceKey") {
+		t.Fatalf("Fixed options should be rejected, got %v", err)
+	}
+	if w.GetWatcherOptions().DebounceWindow != time.Second {
+		t.Fatal("Nothing should be applied when an option is rejected")
+	}
+	if err := w.ApplyOptions(SubscriptionFailureCallback(func(error) {})); err == nil {
+		t.Fatal("Changed hooks should be rejected")
+	}
+}
+
+func TestApplyOptionsWhileRunning(t *testing.T) {
+	pub, sub := NewTestConn(), NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			w.DomainChannel("tenant1")
+			w.GetWatcherOptions()
+			_ = w.String()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		channel := "/casbin"
+		if i%2 == 0 {
+			channel = "/policies"
+		}
+		if err := w.ApplyOptions(Channel(channel), CallbackTimeout(time.Duration(i)*time.Millisecond)); err != nil {
+			t.Fatalf("Failed watcher.ApplyOptions(): %v", err)
+		}
+	}
+	<-done
+}
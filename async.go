@@ -0,0 +1,54 @@
+package rediswatcher
+
+const asyncQueueSize = 64
+
+// UpdateAsync queues an Update and returns at once, so callers do not wait
+// for the Redis round trip. The returned channel receives the publish result
+// and is then closed; failures are also reported to the
+// SubscriptionFailureCallback, so ignoring the channel is fine. Queued
+// updates are published in order.
+func (w *Watcher) UpdateAsync() <-chan error {
+	result := make(chan error, 1)
+	if w.isClosed() {
+		result <- ErrClosed
+		close(result)
+		return result
+	}
+	w.asyncOnce.Do(w.startAsyncPublisher)
+
+	select {
+	case w.async <- result:
+	case <-w.closed:
+		result <- ErrClosed
+		close(result)
+	}
+	return result
+}
+
+func (w *Watcher) startAsyncPublisher() {
+	w.async = make(chan chan error, asyncQueueSize)
+	w.spawn(func() {
+		for {
+			select {
+			case <-w.closed:
+				// fail what is still queued
+				for {
+					select {
+					case result := <-w.async:
+						result <- ErrClosed
+						close(result)
+					default:
+						return
+					}
+				}
+			case result := <-w.async:
+				err := w.Update()
+				if err != nil {
+					w.reportError(err)
+				}
+				result <- err
+				close(result)
+			}
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestUpdateAsync(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+
+	var reported error
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), SubscriptionFailureCallback(func(err error) { reported = err }))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+	if err := <-w.UpdateAsync(); err != nil {
+		t.Fatalf("Failed watcher.UpdateAsync(): %v", err)
+	}
+
+	pub.Command("PUBLISH", "/casbin", "node1").ExpectError(fmt.Errorf("connection refused"))
+	if err := <-w.UpdateAsync(); err == nil {
+		t.Fatal("Failed publish should be returned on the channel")
+	}
+	if reported == nil {
+		t.Fatal("Failed publish should be reported to the failure callback")
+	}
+
+	w.Close()
+	if err := <-w.UpdateAsync(); err != ErrClosed {
+		t.Fatalf("UpdateAsync on a closed watcher should fail, got %v", err)
+	}
+}
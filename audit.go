@@ -0,0 +1,104 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditEntry is one line of the audit log.
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"` // "publish" or "receive"
+	Channel   string    `json:"channel"`
+	Message   string    `json:"message"`
+	Outcome   string    `json:"outcome"` // "ok" or "error"
+	Error     string    `json:"error,omitempty"`
+}
+
+// auditLog appends JSON lines to a file, rotating it once it reaches
+// maxSize bytes into path.1 up to path.<maxBackups>.
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// auditMessage records the outcome of publishing or receiving msg in the
+// AuditLog, when one is configured.
+func (w *Watcher) auditMessage(direction, msg string, err error) {
+	if w.options.auditPath == "" {
+		return
+	}
+
+	entry := auditEntry{
+		Time:      time.Now(),
+		Direction: direction,
+		Channel:   w.channel(),
+		Message:   msg,
+		Outcome:   "ok",
+	}
+	if err != nil {
+		entry.Outcome, entry.Error = "error", err.Error()
+	}
+	line, _ := json.Marshal(entry)
+	if err := w.audit.write(w.options, append(line, '\n')); err != nil {
+		w.notifyError(fmt.Errorf("rediswatcher: audit log: %v", err))
+	}
+}
+
+func (a *auditLog) write(options WatcherOptions, line []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file != nil && options.auditMaxSize > 0 && a.size > 0 && a.size+int64(len(line)) > options.auditMaxSize {
+		if err := a.rotate(options); err != nil {
+			return err
+		}
+	}
+	if a.file == nil {
+		f, err := os.OpenFile(options.auditPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		a.file, a.size = f, info.Size()
+	}
+
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+// rotate closes the current file and shifts it and older backups up by one,
+// dropping the oldest.
+func (a *auditLog) rotate(options WatcherOptions) error {
+	a.file.Close()
+	a.file = nil
+
+	path := options.auditPath
+	if options.auditMaxBackups <= 0 {
+		return os.Remove(path)
+	}
+	for i := options.auditMaxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	return os.Rename(path, path+".1")
+}
+
+func (a *auditLog) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
@@ -0,0 +1,53 @@
+package rediswatcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	w := &Watcher{closed: make(chan struct{})}
+	AuditLog(path, 300, 1)(&w.options)
+	w.options.Channel = "/casbin"
+
+	w.auditMessage("publish", "node1", nil)
+	w.auditMessage("receive", "node2", errors.New("reload failed"))
+	w.auditMessage("receive", "node3", nil)
+	w.audit.close()
+
+	var entries []auditEntry
+	for _, name := range []string{path + ".1", path} {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("Audit log %s should exist: %v", name, err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var e auditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				t.Fatalf("Invalid audit entry %q: %v", scanner.Text(), err)
+			}
+			entries = append(entries, e)
+		}
+		f.Close()
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries across the rotated files, got %+v", entries)
+	}
+	if entries[0].Direction != "publish" || entries[0].Outcome != "ok" || entries[1].Error != "reload failed" {
+		t.Fatalf("Unexpected audit entries %+v", entries)
+	}
+}
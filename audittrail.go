@@ -0,0 +1,140 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// AuditRecord is an update mirrored into the AuditStream.
+type AuditRecord struct {
+	ID      string // Stream entry ID.
+	Time    time.Time
+	Message string
+	Channel string
+	LocalID string
+	Type    string // Message type of structured messages, "update" for plain ones.
+	Host    string
+}
+
+// recordAudit appends a published update to the AuditStream. Failures are
+// reported, they never fail the publish. Callers must hold pubMu.
+func (w *Watcher) recordAudit(msg string) {
+	if w.options.auditStream == "" {
+		return
+	}
+
+	msgType := MessageTypeUpdate
+	if m, ok := decodeMessage(msg); ok {
+		msgType = m.Type
+	}
+	host, _ := os.Hostname()
+	args := []interface{}{w.options.auditStream}
+	if w.options.auditStreamMaxLen > 0 {
+		args = append(args, "MAXLEN", "~", w.options.auditStreamMaxLen)
+	}
+	args = append(args, "*",
+		"message", msg,
+		"channel", w.channel(),
+		"localId", w.options.LocalID,
+		"type", msgType,
+		"host", host)
+
+	startTime := time.Now()
+	c, err := w.publisher()
+	if err == nil {
+		_, err = c.Do("XADD", args...)
+	}
+	if w.options.RecordMetrics != nil {
+		w.options.RecordMetrics(w.createMetrics(AuditStreamMetric, startTime, err))
+	}
+	if err != nil {
+		w.notifyError(fmt.Errorf("rediswatcher: audit stream: %v", err))
+	}
+}
+
+// AuditTrail returns the updates recorded in the AuditStream between from
+// and to, oldest first. A zero from or to leaves that end open.
+func (w *Watcher) AuditTrail(from, to time.Time) ([]AuditRecord, error) {
+	if w.options.auditStream == "" {
+		return nil, fmt.Errorf("rediswatcher: no audit stream configured")
+	}
+
+	start, end := "-", "+"
+	if !from.IsZero() {
+		start = strconv.FormatInt(from.UnixNano()/int64(time.Millisecond), 10)
+	}
+	if !to.IsZero() {
+		end = strconv.FormatInt(to.UnixNano()/int64(time.Millisecond), 10)
+	}
+
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := redis.Values(c.Do("XRANGE", w.options.auditStream, start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]AuditRecord, 0, len(entries))
+	for _, entry := range entries {
+		r, err := parseAuditEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+func parseAuditEntry(entry interface{}) (AuditRecord, error) {
+	var r AuditRecord
+	values, err := redis.Values(entry, nil)
+	if err != nil || len(values) != 2 {
+		return r, fmt.Errorf("rediswatcher: unexpected XRANGE entry %v", entry)
+	}
+	if r.ID, err = redis.String(values[0], nil); err != nil {
+		return r, err
+	}
+	fields, err := redis.StringMap(values[1], nil)
+	if err != nil {
+		return r, err
+	}
+
+	if ms, err := strconv.ParseInt(strings.SplitN(r.ID, "-", 2)[0], 10, 64); err == nil {
+		r.Time = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	r.Message = fields["message"]
+	r.Channel = fields["channel"]
+	r.LocalID = fields["localId"]
+	r.Type = fields["type"]
+	r.Host = fields["host"]
+	return r, nil
+}
+
+// ReplayAudit runs the update callback for every update recorded between
+// from and to, oldest first, e.g. to rebuild state after an outage. It
+// returns the number of updates replayed.
+func (w *Watcher) ReplayAudit(from, to time.Time) (int, error) {
+	if w.getCallback() == nil {
+		return 0, fmt.Errorf("no update callback set")
+	}
+
+	records, err := w.AuditTrail(from, to)
+	if err != nil {
+		return 0, err
+	}
+	for n, r := range records {
+		if err := w.callCallback(w.baseContext(), r.Message); err != nil {
+			return n, err
+		}
+	}
+	return len(records), nil
+}
@@ -0,0 +1,51 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditStream(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), AuditStream("casbin:audit", 1000))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	pub.GenericCommand("XADD").Expect("1700000000000-0")
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+	added := pub.calls("XADD")
+	if len(added) != 1 || added[0][0] != "casbin:audit" || added[0][1] != "MAXLEN" || added[0][6] != "node1" {
+		t.Fatalf("Update should be mirrored into the audit stream, got %v", added)
+	}
+
+	pub.GenericCommand("XRANGE").Expect([]interface{}{
+		[]interface{}{[]byte("1700000000000-0"), []interface{}{
+			[]byte("message"), []byte("node1"),
+			[]byte("localId"), []byte("node1"),
+			[]byte("type"), []byte("update"),
+		}},
+	})
+	records, err := w.AuditTrail(time.Unix(1600000000, 0), time.Time{})
+	if err != nil {
+		t.Fatalf("Failed watcher.AuditTrail(): %v", err)
+	}
+	if len(records) != 1 || records[0].Message != "node1" || records[0].Time.Unix() != 1700000000 {
+		t.Fatalf("Unexpected audit records %+v", records)
+	}
+	if r := pub.calls("XRANGE")[0]; r[1] != "1600000000000" || r[2] != "+" {
+		t.Fatalf("Unexpected XRANGE bounds %v", r)
+	}
+
+	var replayed []string
+	w.SetUpdateCallback(func(msg string) { replayed = append(replayed, msg) })
+	if n, err := w.ReplayAudit(time.Time{}, time.Time{}); err != nil || n != 1 || len(replayed) != 1 {
+		t.Fatalf("ReplayAudit should replay one update, got %d, %v, %v", n, err, replayed)
+	}
+}
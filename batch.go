@@ -0,0 +1,100 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// job is an update handed to the callbacks: msg for the update callbacks and
+// batch, the deduplicated messages it stands for, for the batch callback. A
+// nil batch stands for msg alone, saving an allocation per message.
+type job struct {
+	msg      string
+	batch    []string
+	channel  string // set for messages routed by SetChannelCallback
+	control  string // MessageTypePrepare or MessageTypeCommit for TwoPhaseCallbacks
+	delivery Delivery
+}
+
+func singleJob(msg string) job {
+	return job{msg: msg}
+}
+
+// messages returns the batch of j.
+func (j job) messages() []string {
+	if j.batch == nil {
+		return []string{j.msg}
+	}
+	return j.batch
+}
+
+// SetBatchCallback sets a callback receiving the messages collapsed by the
+// Debounce window, deduplicated and in the order they were last received.
+// Without Debounce every message is a batch of one. It runs alongside the
+// update callback, after it, and bypasses middleware and retries; a failed
+// batch is dead-lettered message by message when a DeadLetterList is set.
+func (w *Watcher) SetBatchCallback(callback func(msgs []string) error) error {
+	w.callbackMu.Lock()
+	w.batch = callback
+	w.callbackMu.Unlock()
+	w.notifyCallbackSet()
+	return nil
+}
+
+func (w *Watcher) getBatchCallback() func([]string) error {
+	w.callbackMu.RLock()
+	defer w.callbackMu.RUnlock()
+	return w.batch
+}
+
+// hasCallback reports whether any callback would receive an update.
+func (w *Watcher) hasCallback() bool {
+	return w.options.delegateKey != "" || w.getCallback() != nil || w.getBatchCallback() != nil
+}
+
+// runJob runs the update callbacks and the batch callback for j.
+func (w *Watcher) runJob(j job) {
+	if !w.waitJitter() {
+		return
+	}
+	if j.channel != "" || j.control != "" || w.options.delegateKey != "" {
+		w.runJobCallback(j)
+		return
+	}
+	if w.getCallback() != nil {
+		w.runJobCallback(j)
+	}
+	if callback := w.getBatchCallback(); callback != nil && !w.options.ReceiveDryRun {
+		w.runBatchCallback(callback, j.messages())
+	}
+}
+
+func (w *Watcher) runBatchCallback(callback func([]string) error, batch []string) {
+	atomic.AddInt32(&w.inflight, 1)
+	defer atomic.AddInt32(&w.inflight, -1)
+
+	if w.options.OrderedDelivery {
+		w.orderMu.Lock()
+		defer w.orderMu.Unlock()
+	}
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = w.recoverPanic(strings.Join(batch, ","), r)
+			}
+		}()
+		startTime := time.Now()
+		defer func() {
+			w.checkSlowCallback(strings.Join(batch, ","), time.Since(startTime))
+		}()
+		return callback(batch)
+	}()
+	if err != nil && w.options.DeadLetterList != "" {
+		for _, msg := range batch {
+			w.deadLetter(msg, fmt.Errorf("batch callback: %v", err))
+		}
+	}
+}
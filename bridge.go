@@ -0,0 +1,78 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+)
+
+// Bridge republishes the updates received by one watcher through another,
+// typically connected to the Redis of a different region, so deployments
+// with independent Redis servers still converge on policy changes:
+//
+//	b := rediswatcher.NewBridge(euWatcher, usWatcher, "eu-us")
+//	defer b.Close()
+//
+// Relayed messages carry the bridge id, and a bridge skips messages already
+// carrying it. Bridges relaying in opposite directions between the same
+// servers must therefore share the id, or updates bounce between them.
+type Bridge struct {
+	from, to *Watcher
+	id       string
+	handle   CallbackHandle
+
+	relayed int64
+	skipped int64
+}
+
+// BridgeStats counts the updates handled by a Bridge.
+type BridgeStats struct {
+	Relayed int64 // Updates republished on the target watcher.
+	Skipped int64 // Updates that already went through the bridge.
+}
+
+// NewBridge starts relaying the updates received by from through to,
+// marking them with id; an empty id uses the LocalID of from. The bridge
+// does not take ownership of the watchers, close them after the bridge.
+func NewBridge(from, to *Watcher, id string) *Bridge {
+	if id == "" {
+		id = from.options.LocalID
+	}
+	b := &Bridge{from: from, to: to, id: id}
+	b.handle = from.AddCallback(func(msg string) {
+		// a failed publish is queued for retry or reported by the target
+		_ = b.relay(msg)
+	})
+	return b
+}
+
+// relay republishes msg on the target watcher unless it already went through
+// the bridge. Plain updates are wrapped in a Message keeping the sender ID.
+func (b *Bridge) relay(msg string) error {
+	m, ok := decodeMessage(msg)
+	if !ok {
+		m = Message{Type: MessageTypeUpdate, ID: msg}
+	}
+	for _, id := range m.Relays {
+		if id == b.id {
+			atomic.AddInt64(&b.skipped, 1)
+			return nil
+		}
+	}
+	m.Relays = append(m.Relays, b.id)
+
+	atomic.AddInt64(&b.relayed, 1)
+	return b.to.publishMessage(m)
+}
+
+// Stats returns the number of updates relayed and skipped so far.
+func (b *Bridge) Stats() BridgeStats {
+	return BridgeStats{
+		Relayed: atomic.LoadInt64(&b.relayed),
+		Skipped: atomic.LoadInt64(&b.skipped),
+	}
+}
+
+// Close stops relaying updates. It leaves both watchers open.
+func (b *Bridge) Close() error {
+	b.from.RemoveCallback(b.handle)
+	return nil
+}
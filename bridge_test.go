@@ -0,0 +1,42 @@
+package rediswatcher
+
+import (
+	"testing"
+)
+
+func TestBridge(t *testing.T) {
+	newWatcher := func(pub *recordConn) *Watcher {
+		w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(NewTestConn()))
+		if err != nil {
+			t.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		return w
+	}
+	fromPub, toPub := newRecordConn(), newRecordConn()
+	toPub.GenericCommand("PUBLISH").Expect(int64(1))
+	from, to := newWatcher(fromPub), newWatcher(toPub)
+	defer from.Close()
+	defer to.Close()
+
+	b := NewBridge(from, to, "eu-us")
+	from.runCallback("node1")
+	from.runCallback(encodeMessage(Message{Type: MessageTypeUpdate, ID: "node2", Relays: []string{"eu-us"}}))
+
+	calls := toPub.calls("PUBLISH")
+	if len(calls) != 1 {
+		t.Fatalf("Only the update that did not go through the bridge should be relayed, got %v", calls)
+	}
+	m, ok := decodeMessage(calls[0][1].(string))
+	if !ok || m.ID != "node1" || len(m.Relays) != 1 || m.Relays[0] != "eu-us" {
+		t.Fatalf("Relayed update should keep the sender and carry the bridge id, got %v", calls[0][1])
+	}
+	if s := b.Stats(); s.Relayed != 1 || s.Skipped != 1 {
+		t.Fatalf("Bridge should count relayed and skipped updates, got %+v", s)
+	}
+
+	b.Close()
+	from.runCallback("node1")
+	if calls := toPub.calls("PUBLISH"); len(calls) != 1 {
+		t.Fatalf("Closed bridge should not relay updates, got %v", calls)
+	}
+}
@@ -0,0 +1,54 @@
+package rediswatcher
+
+import "errors"
+
+type bulkState struct {
+	depth   int
+	pending bool
+}
+
+// BeginBulk suppresses the publishes of Update, e.g. while importing many
+// rules one by one. Calls nest; the matching EndBulk publishes a single
+// update for all of them.
+func (w *Watcher) BeginBulk() {
+	w.bulkMu.Lock()
+	w.bulk.depth++
+	w.bulkMu.Unlock()
+}
+
+// EndBulk ends a BeginBulk. Ending the outermost one publishes a single
+// update when Update was called in between.
+func (w *Watcher) EndBulk() error {
+	w.bulkMu.Lock()
+	if w.bulk.depth == 0 {
+		w.bulkMu.Unlock()
+		return errors.New("rediswatcher: EndBulk without BeginBulk")
+	}
+	w.bulk.depth--
+	publish := w.bulk.depth == 0 && w.bulk.pending
+	if w.bulk.depth == 0 {
+		w.bulk.pending = false
+	}
+	w.bulkMu.Unlock()
+
+	if !publish {
+		return nil
+	}
+	return w.Update()
+}
+
+// suppressUpdate records an Update made during a bulk operation and reports
+// whether it has to be held back. Followers of a LeaderElection hold back
+// every update.
+func (w *Watcher) suppressUpdate() bool {
+	if !w.IsLeader() {
+		return true
+	}
+	w.bulkMu.Lock()
+	defer w.bulkMu.Unlock()
+	if w.bulk.depth == 0 {
+		return false
+	}
+	w.bulk.pending = true
+	return true
+}
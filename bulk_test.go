@@ -0,0 +1,37 @@
+package rediswatcher
+
+import "testing"
+
+func TestBulk(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	w.BeginBulk()
+	w.BeginBulk()
+	for i := 0; i < 5; i++ {
+		w.Update()
+	}
+	if err := w.EndBulk(); err != nil {
+		t.Fatalf("Failed watcher.EndBulk(): %v", err)
+	}
+	if n := len(pub.calls("PUBLISH")); n != 0 {
+		t.Fatalf("Nested EndBulk must not publish, published %d times", n)
+	}
+	if err := w.EndBulk(); err != nil {
+		t.Fatalf("Failed watcher.EndBulk(): %v", err)
+	}
+	if n := len(pub.calls("PUBLISH")); n != 1 {
+		t.Fatalf("Bulk should end with a single publish, published %d times", n)
+	}
+
+	if err := w.EndBulk(); err == nil {
+		t.Fatal("EndBulk without BeginBulk should fail")
+	}
+}
@@ -0,0 +1,317 @@
+package rediswatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// CallbackHandle identifies a callback registered with AddCallback.
+type CallbackHandle uint64
+
+type registeredCallback struct {
+	handle   CallbackHandle
+	callback func(context.Context, string) error
+}
+
+// AddCallback registers an additional callback invoked for every update,
+// after the one set by SetUpdateCallback and in registration order. It lets
+// several subsystems, e.g. policy reload and cache invalidation, react to
+// updates independently.
+func (w *Watcher) AddCallback(callback func(string)) CallbackHandle {
+	return w.addCallback(func(_ context.Context, msg string) error {
+		callback(msg)
+		return nil
+	})
+}
+
+// AddCallbackWithError works like AddCallback for a callback that reports
+// failures. Like those of SetUpdateCallbackWithError they are retried,
+// dead-lettered and counted as configured.
+func (w *Watcher) AddCallbackWithError(callback func(string) error) CallbackHandle {
+	return w.addCallback(func(_ context.Context, msg string) error {
+		return callback(msg)
+	})
+}
+
+func (w *Watcher) addCallback(callback func(context.Context, string) error) CallbackHandle {
+	w.callbackMu.Lock()
+	w.nextHandle++
+	handle := w.nextHandle
+	w.callbacks = append(w.callbacks, registeredCallback{handle: handle, callback: callback})
+	w.callbackMu.Unlock()
+	w.notifyCallbackSet()
+	return handle
+}
+
+// RemoveCallback unregisters a callback added by AddCallback. It reports
+// whether the handle was registered.
+func (w *Watcher) RemoveCallback(handle CallbackHandle) bool {
+	w.callbackMu.Lock()
+	defer w.callbackMu.Unlock()
+	for i, r := range w.callbacks {
+		if r.handle == handle {
+			w.callbacks = append(w.callbacks[:i:i], w.callbacks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// runCallback invokes the update callback for data, retrying it as
+// configured and dead-lettering the message when it keeps failing.
+func (w *Watcher) runCallback(data string) {
+	w.runJobCallback(singleJob(data))
+}
+
+// runJobCallback works like runCallback for j, invoking the callback its
+// channel is routed to or the two-phase handler of a control message when
+// there is one, and passing its Delivery.
+func (w *Watcher) runJobCallback(j job) {
+	data := j.msg
+	ctx, cancel := w.callbackContext()
+	defer cancel()
+	if j.channel != "" {
+		ctx = context.WithValue(ctx, channelKey, j.channel)
+	}
+	if j.control != "" {
+		ctx = context.WithValue(ctx, controlKey, j.control)
+	}
+	if j.delivery.Channel != "" {
+		ctx = context.WithValue(ctx, deliveryKey, j.delivery)
+	}
+
+	atomic.AddInt32(&w.inflight, 1)
+	var done chan struct{} // only waited for with a CallbackTimeout
+	run := func() {
+		if done != nil {
+			defer close(done)
+		}
+		defer atomic.AddInt32(&w.inflight, -1)
+		defer func() {
+			if r := recover(); r != nil {
+				w.recoverPanic(data, r)
+			}
+		}()
+		if w.options.OrderedDelivery {
+			w.orderMu.Lock()
+			defer w.orderMu.Unlock()
+		}
+		startTime := time.Now()
+		err := w.handler(ctx)(data)
+		w.auditMessage("receive", data, err)
+		if err == nil && !w.options.ReceiveDryRun {
+			atomic.StoreInt64(&w.counters.lastReload, time.Now().UnixNano())
+			w.setLastMessage(data)
+			w.acknowledge(data)
+		}
+		if w.options.RecordMetrics != nil {
+			w.options.RecordMetrics(w.createMetrics(CallbackMetric, startTime, err))
+		}
+		if err != nil {
+			atomic.AddInt64(&w.counters.failed, 1)
+			w.notifyError(err)
+		}
+		w.checkSlowCallback(data, time.Since(startTime))
+	}
+	if w.callbackTimeout() <= 0 || w.options.Synchronous {
+		run()
+		return
+	}
+
+	// a callback ignoring ctx keeps running, but no longer holds up delivery
+	done = make(chan struct{})
+	go run()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			w.reportError(ErrCallbackTimeout)
+		}
+		if w.options.OrderedDelivery {
+			<-done
+		}
+	}
+}
+
+// callbackContext returns the context passed to the update callback, done
+// when the CallbackTimeout expires or the watcher closes.
+func (w *Watcher) callbackContext() (context.Context, context.CancelFunc) {
+	base := w.baseContext()
+	ctx, cancel := context.WithCancel(base)
+	if w.callbackTimeout() > 0 {
+		ctx, cancel = context.WithTimeout(base, w.callbackTimeout())
+	}
+	if !w.options.Synchronous {
+		go func() {
+			select {
+			case <-w.closed:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel
+}
+
+// baseContext returns the context of the CallbackContext factory, or
+// context.Background without one.
+func (w *Watcher) baseContext() context.Context {
+	if factory := w.options.contextFactory; factory != nil {
+		if ctx := factory(); ctx != nil {
+			return ctx
+		}
+	}
+	return context.Background()
+}
+
+// handleUpdate is the innermost Handler of the middleware chain.
+func (w *Watcher) handleUpdate(ctx context.Context, data string) error {
+	if err := w.waitForLSN(data); err != nil {
+		w.reportError(err)
+	}
+	if w.options.ReceiveDryRun {
+		return w.dryRunReceive(ctx, data)
+	}
+
+	if w.options.DeadLetterList == "" && w.options.CallbackRetries == 0 {
+		if w.callbackFor(ctx) == nil {
+			return nil
+		}
+		return w.callCallback(ctx, data)
+	}
+
+	err := w.callCallback(ctx, data)
+	backoff := w.options.CallbackRetryBackoff
+	for attempt := 0; err != nil && attempt < w.options.CallbackRetries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-w.clock().After(backoff):
+		}
+		backoff *= 2
+		if max := w.options.CallbackRetryMaxBackoff; max > 0 && backoff > max {
+			backoff = max
+		}
+
+		w.logEvent(levelWarn, "update callback failed, retrying", "attempt", attempt+1, "error", err)
+		startTime := time.Now()
+		err = w.callCallback(ctx, data)
+		if w.options.RecordMetrics != nil {
+			w.options.RecordMetrics(w.createMetrics(CallbackRetryMetric, startTime, err))
+		}
+	}
+	if err != nil && w.options.DeadLetterList != "" {
+		w.logEvent(levelWarn, "update callback failed, dead-lettering", "message", data, "error", err)
+		w.deadLetter(data, err)
+	}
+	return err
+}
+
+// dryRunReceive validates and logs data in place of the callbacks, see
+// ReceiveDryRun.
+func (w *Watcher) dryRunReceive(ctx context.Context, data string) error {
+	channel, _ := ctx.Value(channelKey).(string)
+	if channel == "" {
+		channel = w.channel()
+	}
+	if _, ok := decodeMessage(data); !ok && strings.HasPrefix(data, "{") {
+		return fmt.Errorf("rediswatcher: invalid message on %s: %s", channel, data)
+	}
+
+	if w.options.receiveDryRunLogger != nil {
+		w.options.receiveDryRunLogger(channel, data)
+	} else if !w.logEvent(levelInfo, "dry run, not running callbacks", "channel", channel, "message", data) {
+		w.logf("Dry run, not running callbacks for %s: %s", channel, data)
+	}
+	return nil
+}
+
+// drainCallbacks waits up to timeout for running callbacks to return.
+func (w *Watcher) drainCallbacks(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt32(&w.inflight) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+// callCallback invokes the update callback, turning a panic into an error.
+func (w *Watcher) callCallback(ctx context.Context, data string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = w.recoverPanic(data, r)
+		}
+	}()
+	callback := w.callbackFor(ctx)
+	if callback == nil {
+		return errors.New("rediswatcher: no update callback set")
+	}
+	return callback(ctx, data)
+}
+
+// checkSlowCallback calls the SlowCallback hook when handling data took
+// longer than its threshold.
+func (w *Watcher) checkSlowCallback(data string, elapsed time.Duration) {
+	if w.options.slowCallback != nil && elapsed > w.options.slowCallbackThreshold {
+		w.options.slowCallback(data, elapsed)
+	}
+}
+
+// recoverPanic reports a panic raised while handling data to the
+// PanicHandler and the failure callback, so the watcher keeps running.
+func (w *Watcher) recoverPanic(data string, r interface{}) error {
+	err := fmt.Errorf("update callback panicked: %v", r)
+	if w.options.panicHandler != nil {
+		w.options.panicHandler(data, r)
+	}
+	w.reportError(err)
+	return err
+}
+
+// bufferEarlyMessage keeps a message that arrived before any callback was
+// set, dropping the oldest one beyond EarlyBufferSize.
+func (w *Watcher) bufferEarlyMessage(data string) {
+	if w.options.EarlyBufferSize <= 0 {
+		return
+	}
+
+	w.earlyMu.Lock()
+	defer w.earlyMu.Unlock()
+	if len(w.early) >= w.options.EarlyBufferSize {
+		atomic.AddInt64(&w.counters.dropped, 1)
+		w.early = w.early[1:]
+	}
+	w.early = append(w.early, data)
+}
+
+func (w *Watcher) earlyMessages() int {
+	w.earlyMu.Lock()
+	defer w.earlyMu.Unlock()
+	return len(w.early)
+}
+
+func (w *Watcher) takeEarlyMessages() []string {
+	w.earlyMu.Lock()
+	defer w.earlyMu.Unlock()
+	early := w.early
+	w.early = nil
+	return early
+}
+
+func (w *Watcher) notifyCallbackSet() {
+	if w.options.Synchronous {
+		w.replayEarlyMessages()
+		return
+	}
+	select {
+	case w.callbackSet <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,249 @@
+package rediswatcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestCallbackRetry(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	pub.GenericCommand("RPUSH").Expect(int64(1))
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		CallbackRetry(2, time.Millisecond, 2*time.Millisecond), DeadLetterList("casbin:dlq"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	calls := 0
+	w.SetUpdateCallbackWithError(func(msg string) error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("transient error")
+		}
+		return nil
+	})
+	w.runCallback("node2")
+	if calls != 3 {
+		t.Fatalf("Callback should succeed on the third attempt, called %d times", calls)
+	}
+	if len(pub.calls("RPUSH")) != 0 {
+		t.Fatal("Message recovered by a retry must not be dead-lettered")
+	}
+
+	calls = 0
+	w.SetUpdateCallbackWithError(func(msg string) error {
+		calls++
+		return fmt.Errorf("permanent error")
+	})
+	w.runCallback("node2")
+	if calls != 3 {
+		t.Fatalf("Callback should be attempted 3 times, called %d times", calls)
+	}
+	if len(pub.calls("RPUSH")) != 1 {
+		t.Fatal("Message should be dead-lettered after the retries are exhausted")
+	}
+}
+
+func TestSetUpdateCallbackWhileDelivering(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	w.SetUpdateCallback(func(string) {})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			w.runCallback("node2")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		w.SetUpdateCallback(func(string) {})
+	}
+	<-done
+
+	w.SetUpdateCallback(nil)
+	w.runCallback("node2")
+}
+
+func TestEarlyMessagesReplayed(t *testing.T) {
+	w := &Watcher{
+		options:     WatcherOptions{EarlyBufferSize: 2, SquashTimeoutLong: time.Second},
+		closed:      make(chan struct{}),
+		messagesIn:  make(chan redis.Message),
+		callbackSet: make(chan struct{}, 1),
+	}
+	w.messageInProcessor()
+	defer close(w.closed)
+
+	for _, id := range []string{"node2", "node3", "node4"} {
+		w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte(id)}
+	}
+
+	ch := make(chan string, 3)
+	w.SetUpdateCallback(func(msg string) {
+		ch <- msg
+	})
+	for _, want := range []string{"node3", "node4"} {
+		select {
+		case res := <-ch:
+			if res != want {
+				t.Fatalf("Replayed message should be '%s', received '%s' instead", want, res)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Early messages were not replayed")
+		}
+	}
+}
+
+func TestAddCallback(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+
+	var got []string
+	w.SetUpdateCallback(func(msg string) { got = append(got, "main:"+msg) })
+	first := w.AddCallback(func(msg string) { got = append(got, "first:"+msg) })
+	w.AddCallback(func(msg string) { got = append(got, "second:"+msg) })
+
+	w.runCallback("node2")
+	if fmt.Sprint(got) != "[main:node2 first:node2 second:node2]" {
+		t.Fatalf("All callbacks should run in registration order, got %v", got)
+	}
+
+	if !w.RemoveCallback(first) {
+		t.Fatal("Registered callback should be removed")
+	}
+	if w.RemoveCallback(first) {
+		t.Fatal("Removing a callback twice should report false")
+	}
+	got = nil
+	w.runCallback("node3")
+	if fmt.Sprint(got) != "[main:node3 second:node3]" {
+		t.Fatalf("Removed callback should not run, got %v", got)
+	}
+}
+
+func TestCallbackPanic(t *testing.T) {
+	var reported error
+	var recovered interface{}
+	w := &Watcher{closed: make(chan struct{})}
+	SubscriptionFailureCallback(func(err error) { reported = err })(&w.options)
+	PanicHandler(func(msg string, r interface{}) { recovered = r })(&w.options)
+
+	w.SetUpdateCallback(func(msg string) { panic("bad policy") })
+	w.runCallback("node2")
+	if recovered != "bad policy" {
+		t.Fatalf("Panic hook should receive the panic value, got %v", recovered)
+	}
+	if reported == nil {
+		t.Fatal("Panic should be reported to the failure callback")
+	}
+
+	called := false
+	w.SetUpdateCallback(func(msg string) { called = true })
+	w.runCallback("node2")
+	if !called {
+		t.Fatal("Watcher should keep delivering after a panic")
+	}
+}
+
+func TestCallbackTimeout(t *testing.T) {
+	var reported error
+	w := &Watcher{closed: make(chan struct{})}
+	CallbackTimeout(10 * time.Millisecond)(&w.options)
+	SubscriptionFailureCallback(func(err error) { reported = err })(&w.options)
+
+	cancelled := make(chan struct{})
+	w.SetUpdateCallbackWithContext(func(ctx context.Context, msg string) error {
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	})
+
+	w.runCallback("node2")
+	if reported != ErrCallbackTimeout {
+		t.Fatalf("Timeout should be reported, got %v", reported)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Callback context was not cancelled")
+	}
+}
+
+func TestSlowCallback(t *testing.T) {
+	var slow []string
+	w := &Watcher{closed: make(chan struct{})}
+	SlowCallback(5*time.Millisecond, func(msg string, elapsed time.Duration) {
+		if elapsed < 5*time.Millisecond {
+			t.Errorf("Elapsed time %v is below the threshold", elapsed)
+		}
+		slow = append(slow, msg)
+	})(&w.options)
+
+	w.SetUpdateCallback(func(msg string) {
+		if msg == "node3" {
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	w.runCallback("node2")
+	w.runCallback("node3")
+	if fmt.Sprint(slow) != "[node3]" {
+		t.Fatalf("Only the slow callback should be reported, got %v", slow)
+	}
+}
+
+func TestCallbackContext(t *testing.T) {
+	type tenantKey struct{}
+	w := &Watcher{closed: make(chan struct{})}
+	CallbackTimeout(time.Second)(&w.options)
+	CallbackContext(func() context.Context {
+		return context.WithValue(context.Background(), tenantKey{}, "acme")
+	})(&w.options)
+
+	var tenant interface{}
+	var deadline bool
+	w.SetUpdateCallbackWithContext(func(ctx context.Context, msg string) error {
+		tenant = ctx.Value(tenantKey{})
+		_, deadline = ctx.Deadline()
+		return nil
+	})
+
+	w.runCallback("node2")
+	if tenant != "acme" {
+		t.Fatalf("The callback context should derive from the factory, got %v", tenant)
+	}
+	if !deadline {
+		t.Fatal("The callback timeout should still apply")
+	}
+}
+
+func TestCallbackErrors(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	CallbackRetry(1, time.Millisecond, 0)(&w.options)
+	var reported error
+	w.SetErrorCallback(func(err error) { reported = err })
+
+	calls := 0
+	failed := fmt.Errorf("load failed")
+	w.SetUpdateCallback(func(string) {})
+	w.AddCallbackWithError(func(string) error {
+		calls++
+		return failed
+	})
+
+	w.runCallback("node2")
+	if calls != 2 {
+		t.Fatalf("A returned error should be retried, got %d calls", calls)
+	}
+	if reported != failed {
+		t.Fatalf("The error should reach the error callback, got %v", reported)
+	}
+	if s := w.Stats(); s.FailedUpdates != 1 {
+		t.Fatalf("The failure should be counted once, got %d", s.FailedUpdates)
+	}
+}
@@ -0,0 +1,89 @@
+package rediswatcher
+
+import "context"
+
+type contextKey int
+
+const channelKey contextKey = iota
+
+// SetChannelCallback routes the messages of channel to callback instead of
+// the update callback, so one watcher can serve an enforcer per channel.
+// The channel must be subscribed, e.g. with Channels. Routed messages run
+// through the middleware, retries and worker pool, but are not squashed,
+// debounced, held by Pause or passed to the batch callback. A nil callback
+// removes the route.
+func (w *Watcher) SetChannelCallback(channel string, callback func(msg string) error) error {
+	w.setChannelCallback(w.prefixed(channel), callback)
+	return nil
+}
+
+func (w *Watcher) setChannelCallback(channel string, callback func(msg string) error) {
+	w.callbackMu.Lock()
+	if callback == nil {
+		delete(w.routes, channel)
+	} else {
+		if w.routes == nil {
+			w.routes = make(map[string]func(context.Context, string) error)
+		}
+		w.routes[channel] = func(_ context.Context, msg string) error {
+			return callback(msg)
+		}
+	}
+	w.callbackMu.Unlock()
+}
+
+func (w *Watcher) getChannelCallback(channel string) func(context.Context, string) error {
+	w.callbackMu.RLock()
+	defer w.callbackMu.RUnlock()
+	return w.routes[channel]
+}
+
+// prefixed returns channel in the namespace of the ChannelPrefix.
+func (w *Watcher) prefixed(channel string) string {
+	return w.options.ChannelPrefix + channel
+}
+
+// applyChannelPrefix puts every configured channel into the namespace of the
+// ChannelPrefix.
+func (w *Watcher) applyChannelPrefix() {
+	if w.options.ChannelPrefix == "" {
+		return
+	}
+
+	prefixAll := func(channels []string) []string {
+		out := make([]string, len(channels))
+		for i, channel := range channels {
+			out[i] = w.prefixed(channel)
+		}
+		return out
+	}
+	w.options.Channel = w.prefixed(w.options.Channel)
+	w.options.channels = prefixAll(w.options.channels)
+	w.options.patterns = prefixAll(w.options.patterns)
+	if w.options.statsChannel != "" {
+		w.options.statsChannel = w.prefixed(w.options.statsChannel)
+	}
+	if w.options.statusChannel != "" {
+		w.options.statusChannel = w.prefixed(w.options.statusChannel)
+	}
+	if w.options.dualChannel != "" {
+		w.options.dualChannel = w.prefixed(w.options.dualChannel)
+	}
+}
+
+// callbackFor returns the callback handling an update: the one routed to the
+// channel carried by ctx, the DelegatedReload or the update callback.
+func (w *Watcher) callbackFor(ctx context.Context) func(context.Context, string) error {
+	if control, _ := ctx.Value(controlKey).(string); control != "" {
+		return w.twoPhaseCallback(control)
+	}
+	if channel, _ := ctx.Value(channelKey).(string); channel != "" {
+		if callback := w.getChannelCallback(channel); callback != nil {
+			return callback
+		}
+	}
+	if w.options.delegateKey != "" {
+		return w.delegatedReload
+	}
+	return w.getCallback()
+}
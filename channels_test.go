@@ -0,0 +1,76 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestChannelCallbacks(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		Channels("/casbin/model2"), ManualStart(true))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	updates := make(chan string, 2)
+	routed := make(chan string, 2)
+	w.SetUpdateCallback(func(msg string) { updates <- msg })
+	w.SetChannelCallback("/casbin/model2", func(msg string) error {
+		routed <- msg
+		return nil
+	})
+
+	w.messagesIn = make(chan redis.Message)
+	w.messageInProcessor()
+	w.messagesIn <- redis.Message{Channel: "/casbin/model2", Data: []byte("node2")}
+	w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte("node3")}
+
+	for _, want := range []struct {
+		ch  chan string
+		msg string
+	}{{routed, "node2"}, {updates, "node3"}} {
+		select {
+		case msg := <-want.ch:
+			if msg != want.msg {
+				t.Fatalf("Expected %s, got %s", want.msg, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s was not delivered to its callback", want.msg)
+		}
+	}
+	if len(updates) != 0 || len(routed) != 0 {
+		t.Fatal("Messages should only reach the callback of their channel")
+	}
+}
+
+func TestChannelPrefix(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		ChannelPrefix("prod:"), Domains("tenant1"), Channels("/casbin/model2"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+	if err := w.UpdateForDomain("tenant1"); err != nil {
+		t.Fatalf("Failed watcher.UpdateForDomain(): %v", err)
+	}
+	calls := pub.calls("PUBLISH")
+	if len(calls) != 2 || calls[0][0] != "prod:/casbin" || calls[1][0] != "prod:/casbin/tenant1" {
+		t.Fatalf("Updates should be published on prefixed channels, got %v", calls)
+	}
+	if channels := fmt.Sprint(w.channels()); channels != "[prod:/casbin prod:/casbin/tenant1 prod:/casbin/model2]" {
+		t.Fatalf("Watcher should subscribe to prefixed channels, got %v", channels)
+	}
+}
@@ -0,0 +1,264 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ServerTime returns the current time of the Redis server, a clock shared
+// by all watchers regardless of the skew between their hosts.
+func (w *Watcher) ServerTime() (time.Time, error) {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return serverTime(c)
+}
+
+func serverTime(c redis.Conn) (time.Time, error) {
+	values, err := redis.Int64s(c.Do("TIME"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(values) != 2 {
+		return time.Time{}, fmt.Errorf("rediswatcher: unexpected TIME reply %v", values)
+	}
+	return time.Unix(values[0], values[1]*int64(time.Microsecond)), nil
+}
+
+// serverNow returns the Redis server time, or the local time when the
+// server cannot be asked.
+func (w *Watcher) serverNow() time.Time {
+	if t, err := w.ServerTime(); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// stamp sets the times of m before it is published. Callers must hold
+// pubMu. Without a server time only the local one is set.
+func (w *Watcher) stamp(m *Message) {
+	now := time.Now()
+	m.LocalTime = now.UnixNano() / int64(time.Microsecond)
+	if c, err := w.publisher(); err == nil {
+		if t, err := serverTime(c); err == nil {
+			m.Time = t.UnixNano() / int64(time.Microsecond)
+		}
+	}
+}
+
+// MessageAge returns how long ago m was published, measured on the Redis
+// server clock when m carries a server time, see Timestamps, and on the
+// local clock otherwise. It reports false when m has no time at all.
+func (w *Watcher) MessageAge(m Message) (time.Duration, bool) {
+	if m.Time != 0 {
+		if now, err := w.ServerTime(); err == nil {
+			return now.Sub(time.Unix(0, m.Time*int64(time.Microsecond))), true
+		}
+	}
+	if m.LocalTime != 0 {
+		return time.Since(time.Unix(0, m.LocalTime*int64(time.Microsecond))), true
+	}
+	return 0, false
+}
+
+// Clock is the time source of the watcher's timers: retry backoffs and
+// rate limits, the Debounce, PublishCoalesce and PublishDelay waits, the
+// periodic work such as heartbeats, reloads, leader renewal and the outbox
+// relay, maintenance windows and staleness checks. Message squashing, the
+// drain of Close and measured latencies keep to the system clock. Tests
+// inject a ManualClock with WithClock to run them without sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a pending AfterFunc of a Clock.
+type Timer interface {
+	Stop() bool
+}
+
+// Ticker delivers ticks of a Clock on C.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// WithClock replaces the system clock of the watcher's timers by clock.
+func WithClock(clock Clock) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.Clock = clock
+	}
+}
+
+// clock returns the Clock of the watcher, the system clock by default.
+func (w *Watcher) clock() Clock {
+	if w.options.Clock != nil {
+		return w.options.Clock
+	}
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// ManualClock is a Clock that only moves when told to, for deterministic
+// tests of retries, debouncing and staleness.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*manualWaiter
+}
+
+// manualWaiter is an After, AfterFunc or ticker waiting for its time.
+type manualWaiter struct {
+	clock  *ManualClock
+	at     time.Time
+	period time.Duration // of a ticker
+	c      chan time.Time
+	f      func()
+}
+
+// NewManualClock creates a ManualClock showing start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After works like time.After.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	w := &manualWaiter{c: make(chan time.Time, 1)}
+	c.add(w, d)
+	return w.c
+}
+
+// AfterFunc works like time.AfterFunc, running f on the goroutine calling
+// Advance.
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	w := &manualWaiter{f: f}
+	c.add(w, d)
+	return w
+}
+
+// NewTicker works like time.NewTicker.
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	w := &manualWaiter{period: d, c: make(chan time.Time, 1)}
+	c.add(w, d)
+	return manualTicker{w}
+}
+
+// Advance moves the clock forward by d, firing the timers due on the way in
+// order.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		next := -1
+		for i, w := range c.waiters {
+			if !w.at.After(end) && (next < 0 || w.at.Before(c.waiters[next].at)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		w := c.waiters[next]
+		c.now = w.at
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			c.waiters = append(c.waiters[:next], c.waiters[next+1:]...)
+		}
+		now := c.now
+		c.mu.Unlock()
+
+		if w.f != nil {
+			w.f()
+		} else {
+			select {
+			case w.c <- now:
+			default: // a ticker drops ticks nobody read
+			}
+		}
+	}
+}
+
+// Waiters returns the number of pending timers and tickers, so a test can
+// wait for a goroutine to start waiting before advancing the clock.
+func (c *ManualClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+func (c *ManualClock) add(w *manualWaiter, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.clock = c
+	w.at = c.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		if w.f != nil {
+			go w.f()
+		} else {
+			w.c <- c.now
+		}
+		return
+	}
+	c.waiters = append(c.waiters, w)
+}
+
+// Stop removes the waiter from its clock and reports whether it was pending.
+func (w *manualWaiter) Stop() bool {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, v := range c.waiters {
+		if v == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type manualTicker struct {
+	w *manualWaiter
+}
+
+func (t manualTicker) C() <-chan time.Time { return t.w.c }
+
+func (t manualTicker) Stop() { t.w.Stop() }
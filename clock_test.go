@@ -0,0 +1,125 @@
+package rediswatcher
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTimestamps(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), Timestamps(true))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	// the server clock runs an hour behind the local one
+	server := time.Now().Add(-time.Hour)
+	pub.Command("TIME").Expect([]interface{}{
+		[]byte(strconv.FormatInt(server.Unix(), 10)), []byte(strconv.Itoa(server.Nanosecond() / 1000)),
+	})
+	if now, err := w.ServerTime(); err != nil || now.Unix() != server.Unix() {
+		t.Fatalf("ServerTime should return the Redis time, got %v, %v", now, err)
+	}
+
+	if err := w.UpdateWithLSN("42"); err != nil {
+		t.Fatalf("Failed watcher.UpdateWithLSN(): %v", err)
+	}
+	m, _ := decodeMessage(pub.calls("PUBLISH")[0][1].(string))
+	if m.Time/1e6 != server.Unix() || m.LocalTime/1e6 < server.Add(time.Minute).Unix() {
+		t.Fatalf("Message should carry the server and local time, got %d and %d", m.Time, m.LocalTime)
+	}
+	if age, ok := w.MessageAge(m); !ok || age < 0 || age > time.Second {
+		t.Fatalf("Age should be measured on the server clock, got %v", age)
+	}
+	if _, ok := w.MessageAge(Message{}); ok {
+		t.Fatal("Message without a time has no age")
+	}
+}
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	c := NewManualClock(start)
+
+	after := c.After(time.Second)
+	var fired []string
+	c.AfterFunc(3*time.Second, func() { fired = append(fired, "func") })
+	stopped := c.AfterFunc(2*time.Second, func() { fired = append(fired, "stopped") })
+	ticker := c.NewTicker(time.Second)
+	if !stopped.Stop() || c.Waiters() != 3 {
+		t.Fatalf("A stopped timer should be removed, %d pending", c.Waiters())
+	}
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-after:
+		t.Fatal("After fired early")
+	default:
+	}
+	c.Advance(3 * time.Second)
+	if at := <-after; !at.Equal(start.Add(time.Second)) {
+		t.Fatalf("After should fire at its time, got %v", at)
+	}
+	if len(fired) != 1 || !c.Now().Equal(start.Add(3500*time.Millisecond)) {
+		t.Fatalf("Unexpected state %v at %v", fired, c.Now())
+	}
+	<-ticker.C()
+	ticker.Stop()
+	if c.Waiters() != 0 {
+		t.Fatalf("All timers should be done, %d pending", c.Waiters())
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	c := NewManualClock(time.Now())
+	stale := 0
+	w := &Watcher{closed: make(chan struct{})}
+	WithClock(c)(&w.options)
+	Debounce(time.Minute)(&w.options)
+	StalenessHandler(time.Minute, func(time.Duration) { stale++ }, nil)(&w.options)
+	defer close(w.closed)
+
+	ch := make(chan string, 4)
+	w.SetUpdateCallback(func(msg string) { ch <- msg })
+	w.deliver("node2")
+	w.deliver("node3")
+	c.Advance(time.Minute)
+	select {
+	case res := <-ch:
+		if res != "node3" {
+			t.Fatalf("Expected 'node3', got '%s'", res)
+		}
+	default:
+		t.Fatal("Advancing the clock should end the debounce window")
+	}
+
+	w.setSubscribed(true)
+	w.setSubscribed(false)
+	c.Advance(2 * time.Minute)
+	w.checkStaleness()
+	if stale != 1 {
+		t.Fatal("Staleness should follow the injected clock")
+	}
+	// maintenance windows start and end on the injected clock
+	w.ScheduleMaintenance(c.Now().Add(time.Minute), c.Now().Add(2*time.Minute))
+	for deadline := time.Now().Add(time.Second); c.Waiters() == 0 && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+	c.Advance(time.Minute)
+	for deadline := time.Now().Add(time.Second); !w.Paused() && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+	if !w.Paused() {
+		t.Fatal("The window should start when the clock reaches it")
+	}
+	for deadline := time.Now().Add(time.Second); c.Waiters() == 0 && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+	c.Advance(time.Minute)
+	for deadline := time.Now().Add(time.Second); w.Paused() && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+	if w.Paused() {
+		t.Fatal("The window should end when the clock reaches its end")
+	}
+}
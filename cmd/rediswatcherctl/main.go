@@ -0,0 +1,289 @@
+// Command rediswatcherctl speaks the watcher protocol for operators: it
+// publishes update and control messages, tails the channel, lists the
+// presence registry and replays the audit stream.
+//
+//	rediswatcherctl [-addr host:port] [-channel /casbin] <command> [flags]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+const usage = `Usage: rediswatcherctl [-addr host:port] [-channel name] <command> [flags]
+
+Commands:
+  publish   publish an update or a control message
+  tail      print the updates received on the channel
+  presence  list the watchers of a presence registry
+  replay    print the updates recorded in an audit stream
+
+Run rediswatcherctl <command> -h for the flags of a command.
+`
+
+var errUsage = errors.New("usage")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if err != errUsage {
+			fmt.Fprintln(os.Stderr, "rediswatcherctl:", err)
+		}
+		os.Exit(2)
+	}
+}
+
+// run executes the command line args. The options are appended to those
+// derived from the flags, tests use them to inject connections.
+func run(args []string, stdout, stderr io.Writer, options ...rediswatcher.WatcherOption) error {
+	global := flag.NewFlagSet("rediswatcherctl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { fmt.Fprint(stderr, usage) }
+	addr := global.String("addr", "127.0.0.1:6379", "Redis address")
+	channel := global.String("channel", "/casbin", "watcher channel")
+	password := global.String("password", "", "Redis password")
+	id := global.String("id", "", "local ID of the published messages, a random ID by default")
+	if err := global.Parse(args); err != nil {
+		return errUsage
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return errUsage
+	}
+
+	base := []rediswatcher.WatcherOption{rediswatcher.Channel(*channel), rediswatcher.ManualStart(true)}
+	if *password != "" {
+		base = append(base, rediswatcher.Password(*password))
+	}
+	if *id != "" {
+		base = append(base, rediswatcher.LocalID(*id))
+	}
+	c := &command{addr: *addr, stdout: stdout, stderr: stderr, options: append(base, options...)}
+
+	name, args := global.Arg(0), global.Args()[1:]
+	switch name {
+	case "publish":
+		return c.publish(args)
+	case "tail":
+		return c.tail(args)
+	case "presence":
+		return c.presence(args)
+	case "replay":
+		return c.replay(args)
+	}
+	fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+	global.Usage()
+	return errUsage
+}
+
+type command struct {
+	addr           string
+	stdout, stderr io.Writer
+	options        []rediswatcher.WatcherOption
+}
+
+func (c *command) flags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: rediswatcherctl %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// publisher connects a watcher publishing on the channel.
+func (c *command) publisher(extra ...rediswatcher.WatcherOption) (*rediswatcher.Watcher, error) {
+	w, err := rediswatcher.NewPublishWatcher(c.addr, append(c.options, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	return w.(*rediswatcher.Watcher), nil
+}
+
+func (c *command) publish(args []string) error {
+	fs := c.flags("publish", "[update|lsn <lsn>|groups <group>...|prepare <version>|commit <version>|status <nonce>]")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	kind, params := "update", fs.Args()
+	if len(params) > 0 {
+		kind, params = params[0], params[1:]
+	}
+
+	w, err := c.publisher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	param := func() (string, error) {
+		if len(params) != 1 {
+			fs.Usage()
+			return "", errUsage
+		}
+		return params[0], nil
+	}
+	var p string
+	switch kind {
+	case "update":
+		err = w.Update()
+	case "groups":
+		if len(params) == 0 {
+			fs.Usage()
+			return errUsage
+		}
+		err = w.UpdateForGroups(params...)
+	case "lsn":
+		if p, err = param(); err == nil {
+			err = w.UpdateWithLSN(p)
+		}
+	case "prepare":
+		if p, err = param(); err == nil {
+			err = w.Prepare(p)
+		}
+	case "commit":
+		if p, err = param(); err == nil {
+			err = w.Commit(p)
+		}
+	case "status":
+		if p, err = param(); err == nil {
+			err = w.RequestStatus(p)
+		}
+	default:
+		fmt.Fprintf(c.stderr, "unknown message %q\n", kind)
+		fs.Usage()
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "published %s as %s\n", kind, w.GetWatcherOptions().LocalID)
+	return nil
+}
+
+// tail subscribes in receive dry-run mode, so no callback runs and the
+// received messages are only printed, until interrupted.
+func (c *command) tail(args []string) error {
+	fs := c.flags("tail", "")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	show := func(channel, msg string) {
+		line := time.Now().Format(time.RFC3339Nano) + " " + channel
+		if m, ok := rediswatcher.ParseMessage(msg); ok {
+			line += " " + m.Type + " " + m.ID
+			if len(m.Operations) == 0 && m.Version == "" && m.LSN == "" && len(m.Groups) == 0 {
+				msg = ""
+			}
+		}
+		fmt.Fprintln(c.stdout, strings.TrimSpace(line+" "+msg))
+	}
+	w, err := rediswatcher.NewWatcher(c.addr, append(c.options, rediswatcher.ReceiveDryRun(show))...)
+	if err != nil {
+		return err
+	}
+	rw := w.(*rediswatcher.Watcher)
+	rw.SetUpdateCallback(func(string) {})
+	rw.SetErrorCallback(func(err error) { fmt.Fprintln(c.stderr, "error:", err) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := rw.Run(ctx); err != context.Canceled {
+		return err
+	}
+	return nil
+}
+
+func (c *command) presence(args []string) error {
+	fs := c.flags("presence", "")
+	key := fs.String("key", "", "presence registry key")
+	interval := fs.Duration("interval", 10*time.Second, "heartbeat interval of the watchers")
+	prune := fs.Bool("prune", false, "remove the watchers that are not alive")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *key == "" {
+		fmt.Fprintln(c.stderr, "the -key flag is required")
+		fs.Usage()
+		return errUsage
+	}
+
+	w, err := c.publisher(rediswatcher.PresenceRegistry(*key, *interval))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	entries, err := w.Presence()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		state := "alive"
+		if !e.Alive {
+			state = "dead"
+		}
+		fmt.Fprintf(c.stdout, "%s\t%s\t%s\t%s\t%s\n", e.ID, state, e.Host, e.Version, e.LastSeen.Format(time.RFC3339))
+	}
+	if *prune {
+		n, err := w.PruneDead()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "pruned %d\n", n)
+	}
+	return nil
+}
+
+func (c *command) replay(args []string) error {
+	fs := c.flags("replay", "")
+	key := fs.String("stream", "", "audit stream key")
+	since := fs.Duration("since", time.Hour, "replay the updates of this long ago up to now, 0 for all")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *key == "" {
+		fmt.Fprintln(c.stderr, "the -stream flag is required")
+		fs.Usage()
+		return errUsage
+	}
+
+	w, err := c.publisher(rediswatcher.AuditStream(*key, 0))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	records, err := w.AuditTrail(from, time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		fmt.Fprintln(c.stdout, strings.Join([]string{r.Time.Format(time.RFC3339Nano), r.Type, r.LocalID, r.Host, r.Message}, "\t"))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+	"github.com/rafaeljusto/redigomock"
+)
+
+func runWith(t *testing.T, pub *redigomock.Conn, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(args, &stdout, &stderr,
+		rediswatcher.WithRedisPubConnection(pub), rediswatcher.WithRedisSubConnection(redigomock.NewConn()))
+	return stdout.String() + stderr.String(), err
+}
+
+func TestPublish(t *testing.T) {
+	pub := redigomock.NewConn()
+	pub.Command("PUBLISH", "/casbin", "ops").Expect(int64(1))
+	if out, err := runWith(t, pub, "-id", "ops", "publish"); err != nil || out != "published update as ops\n" {
+		t.Fatalf("Update should be published, got %q, %v", out, err)
+	}
+
+	pub.Command("PUBLISH", "/casbin", `{"type":"prepare","id":"ops","version":"v2"}`).Expect(int64(1))
+	if out, err := runWith(t, pub, "-id", "ops", "publish", "prepare", "v2"); err != nil {
+		t.Fatalf("Prepare should be published, got %q, %v", out, err)
+	}
+
+	if _, err := runWith(t, pub, "publish", "prepare"); err != errUsage {
+		t.Fatalf("A missing version should be a usage error, got %v", err)
+	}
+	if _, err := runWith(t, pub, "unknown"); err != errUsage {
+		t.Fatalf("An unknown command should be a usage error, got %v", err)
+	}
+}
+
+func TestPresence(t *testing.T) {
+	pub := redigomock.NewConn()
+	alive, _ := json.Marshal(rediswatcher.PresenceEntry{ID: "node1", Host: "web-1", LastSeen: time.Now()})
+	pub.Command("HGETALL", "casbin:watchers").Expect([]interface{}{[]byte("node1"), alive})
+	out, err := runWith(t, pub, "presence", "-key", "casbin:watchers")
+	if err != nil || !strings.HasPrefix(out, "node1\talive\tweb-1\t") {
+		t.Fatalf("Presence should list the watchers, got %q, %v", out, err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	pub := redigomock.NewConn()
+	pub.GenericCommand("XRANGE").Expect([]interface{}{
+		[]interface{}{[]byte("1600000000000-0"), []interface{}{
+			[]byte("message"), []byte("node1"), []byte("type"), []byte("update"),
+			[]byte("localId"), []byte("node1"), []byte("host"), []byte("web-1"),
+		}},
+	})
+	out, err := runWith(t, pub, "replay", "-stream", "casbin:audit", "-since", "0")
+	if err != nil || !strings.HasSuffix(out, "\tupdate\tnode1\tweb-1\tnode1\n") {
+		t.Fatalf("Replay should print the audit records, got %q, %v", out, err)
+	}
+}
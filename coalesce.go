@@ -0,0 +1,38 @@
+package rediswatcher
+
+import "sync"
+
+type coalesceState struct {
+	mu    sync.Mutex
+	timer Timer
+}
+
+// coalesceUpdate schedules a single publish at the end of the
+// PublishCoalesce window, started by the first Update within it.
+func (w *Watcher) coalesceUpdate() {
+	w.coalesced.mu.Lock()
+	defer w.coalesced.mu.Unlock()
+
+	if w.coalesced.timer == nil {
+		w.coalesced.timer = w.clock().AfterFunc(w.options.PublishCoalesce, w.flushCoalesced)
+	}
+}
+
+// flushCoalesced publishes the update collected in the current window, if
+// any. Errors go to the failure callback as Update has already returned.
+func (w *Watcher) flushCoalesced() {
+	w.coalesced.mu.Lock()
+	pending := w.coalesced.timer != nil
+	if pending {
+		w.coalesced.timer.Stop()
+		w.coalesced.timer = nil
+	}
+	w.coalesced.mu.Unlock()
+
+	if !pending {
+		return
+	}
+	if err := w.publishUpdate(); err != nil {
+		w.reportError(err)
+	}
+}
@@ -0,0 +1,34 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishCoalesce(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		PublishCoalesce(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := w.Update(); err != nil {
+			t.Fatalf("Failed watcher.Update(): %v", err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(pub.calls("PUBLISH")); n != 1 {
+		t.Fatalf("Coalesced updates should publish once, published %d times", n)
+	}
+
+	// a pending update is flushed on Close
+	w.Update()
+	w.Close()
+	if n := len(pub.calls("PUBLISH")); n != 2 {
+		t.Fatalf("Close should flush the pending update, published %d times", n)
+	}
+}
@@ -0,0 +1,78 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// codecPrefix starts a message encoded by a registered codec:
+// "@<format>:<payload>". Messages without it are plain or JSON.
+const codecPrefix = "@"
+
+// Codec encodes structured messages in a format other than the default
+// JSON, e.g. protobuf or a newer schema.
+type Codec interface {
+	Marshal(m Message) ([]byte, error)
+	Unmarshal(data []byte, m *Message) error
+}
+
+var (
+	codecMu sync.RWMutex
+	codecs  = map[string]Codec{}
+)
+
+// RegisterCodec makes messages in format decodable by every watcher of the
+// process and lets watchers publish in it with PublishFormat, so a cluster
+// can run several formats side by side during a migration. Register codecs
+// before creating watchers, typically from init. A format must not be
+// empty or contain ':'.
+func RegisterCodec(format string, codec Codec) error {
+	if format == "" || strings.Contains(format, ":") {
+		return fmt.Errorf("rediswatcher: invalid codec format %q", format)
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecs[format] = codec
+	return nil
+}
+
+func lookupCodec(format string) Codec {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	return codecs[format]
+}
+
+// encode encodes m in the PublishFormat.
+func (w *Watcher) encode(m Message) (string, error) {
+	if w.options.PublishFormat == "" {
+		return encodeMessage(m), nil
+	}
+	codec := lookupCodec(w.options.PublishFormat)
+	if codec == nil {
+		return "", fmt.Errorf("rediswatcher: no codec registered for format %q", w.options.PublishFormat)
+	}
+	b, err := codec.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return codecPrefix + w.options.PublishFormat + ":" + string(b), nil
+}
+
+// decodeEnvelope decodes a message encoded by a registered codec. Messages
+// in unknown formats are treated as plain payloads.
+func decodeEnvelope(data string) (Message, bool) {
+	var m Message
+	i := strings.Index(data, ":")
+	if i < 0 {
+		return m, false
+	}
+	codec := lookupCodec(data[len(codecPrefix):i])
+	if codec == nil {
+		return m, false
+	}
+	if err := codec.Unmarshal([]byte(data[i+1:]), &m); err != nil || m.Type == "" {
+		return m, false
+	}
+	return m, true
+}
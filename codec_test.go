@@ -0,0 +1,67 @@
+package rediswatcher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// pipeCodec encodes the type and ID of a message as "type|id".
+type pipeCodec struct{}
+
+func (pipeCodec) Marshal(m Message) ([]byte, error) {
+	return []byte(m.Type + "|" + m.ID), nil
+}
+
+func (pipeCodec) Unmarshal(data []byte, m *Message) error {
+	parts := strings.SplitN(string(data), "|", 2)
+	if len(parts) != 2 {
+		return errors.New("invalid pipe message")
+	}
+	m.Type, m.ID = parts[0], parts[1]
+	return nil
+}
+
+func TestCodecs(t *testing.T) {
+	if err := RegisterCodec("pipe", pipeCodec{}); err != nil {
+		t.Fatalf("Failed RegisterCodec(): %v", err)
+	}
+	if err := RegisterCodec("pipe:v2", pipeCodec{}); err == nil {
+		t.Fatal("A format containing ':' should be rejected")
+	}
+
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), PublishFormat("pipe"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.UpdateWithLSN("42"); err != nil {
+		t.Fatalf("Failed watcher.UpdateWithLSN(): %v", err)
+	}
+	msg := pub.calls("PUBLISH")[0][1].(string)
+	if msg != "@pipe:update|node1" {
+		t.Fatalf("Message should be published in the pipe format, got %s", msg)
+	}
+
+	for data, want := range map[string]bool{
+		msg:                           true,
+		`{"type":"update","id":"n2"}`: true,
+		"@pipe:broken":                false,
+		"@unknown:update|node1":       false,
+		"node2":                       false,
+	} {
+		if m, ok := ParseMessage(data); ok != want || ok && m.Type != MessageTypeUpdate {
+			t.Errorf("Decoding %q should succeed: %v, got %+v", data, want, m)
+		}
+	}
+
+	PublishFormat("missing")(&w.options)
+	if err := w.UpdateWithLSN("43"); err == nil {
+		t.Fatal("Publishing in an unregistered format should fail")
+	}
+}
@@ -0,0 +1,46 @@
+package rediswatcher
+
+import (
+	"context"
+
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/garyburd/redigo/redis"
+)
+
+// The option names, constructors and the "/casbin" default channel match
+// github.com/billcobbler/casbin-redis-watcher/v2: switching only changes the
+// import path. The constructors return ExtendedWatcher, a persist.Watcher
+// with the common methods of the *Watcher behind it, so assertions such as
+// w.(*rediswatcher.Watcher) keep working. This file holds what the original
+// API had beyond them.
+
+// ExtendedWatcher is the persist.Watcher returned by NewWatcher and
+// NewPublishWatcher, with the methods most callers need. The rest of the API
+// is on the *Watcher it holds.
+type ExtendedWatcher interface {
+	persist.Watcher
+	SetUpdateCallbackWithError(callback func(string) error) error
+	SetErrorCallback(callback func(error))
+	UpdateWithContext(ctx context.Context) error
+	UpdateForDomain(domain string) error
+	Start() error
+	Ready() <-chan struct{}
+	WaitUntilSubscribed(ctx context.Context) error
+	Pause()
+	Resume() int
+	Paused() bool
+	Resync() error
+	Stats() Stats
+	Health() Health
+	LastError() error
+	GetWatcherOptions() WatcherOptions
+	CloseWithReport() ShutdownReport
+}
+
+// WithRedisConnection is the single connection option of the original
+// watcher. The connection publishes; a subscribed connection cannot publish,
+// so the subscription dials addr unless WithRedisSubConnection is given too
+// or the watcher is PublishOnly.
+func WithRedisConnection(connection redis.Conn) WatcherOption {
+	return WithRedisPubConnection(connection)
+}
@@ -0,0 +1,50 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/garyburd/redigo/redis"
+)
+
+// The API of github.com/billcobbler/casbin-redis-watcher/v2, whose
+// constructors return a persist.Watcher.
+var (
+	_ persist.Watcher                                         = ExtendedWatcher(nil)
+	_ ExtendedWatcher                                         = (*Watcher)(nil)
+	_ func(string, ...WatcherOption) (ExtendedWatcher, error) = NewWatcher
+	_ func(string, ...WatcherOption) (ExtendedWatcher, error) = NewPublishWatcher
+	_ func(string) WatcherOption                              = Channel
+	_ func(string) WatcherOption                              = Password
+	_ func(string) WatcherOption                              = Protocol
+	_ func(redis.Conn) WatcherOption                          = WithRedisConnection
+	_ func(redis.Conn) WatcherOption                          = WithRedisSubConnection
+	_ func(redis.Conn) WatcherOption                          = WithRedisPubConnection
+	_ func(string) WatcherOption                              = LocalID
+	_ func(bool) WatcherOption                                = IgnoreSelf
+	_ func(bool) WatcherOption                                = SquashMessages
+	_ func(time.Duration) WatcherOption                       = ResubscribeThreshold
+	_ func(func(error)) WatcherOption                         = SubscriptionFailureCallback
+	_ func(func(*WatcherMetrics)) WatcherOption               = RecordMetrics
+	_ func(time.Duration) WatcherOption                       = SquashTimeoutShort
+	_ func(time.Duration) WatcherOption                       = SquashTimeoutLong
+	_ func(*Watcher, bool) bool                               = IsCallbackPending
+)
+
+func TestCompatDefaults(t *testing.T) {
+	pub, sub := NewTestConn(), NewTestConn()
+	w, err := NewWatcher("", WithRedisConnection(pub), WithRedisSubConnection(sub), ManualStart(true))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	if _, ok := w.(*Watcher); !ok {
+		t.Fatalf("The watcher should be a *Watcher, got %T", w)
+	}
+
+	opts := w.GetWatcherOptions()
+	if opts.Channel != "/casbin" || opts.PubConn != pub || opts.SubConn != sub || opts.Protocol != "tcp" || opts.LocalID == "" {
+		t.Fatalf("The defaults of the original watcher should be kept, got %+v", opts)
+	}
+}
@@ -0,0 +1,63 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/casbin/casbin/v2/persist"
+)
+
+// NoopWatcher is a persist.Watcher doing nothing, for local development and
+// tests without Redis.
+type NoopWatcher struct{}
+
+// SetUpdateCallback ignores the callback, it never runs.
+func (NoopWatcher) SetUpdateCallback(func(string)) error { return nil }
+
+// Update does nothing.
+func (NoopWatcher) Update() error { return nil }
+
+// Close does nothing.
+func (NoopWatcher) Close() {}
+
+// CompositeWatcher fans Update out to several watchers, e.g. this one plus
+// a logging watcher, and receives the updates of all of them.
+type CompositeWatcher struct {
+	watchers []persist.Watcher
+}
+
+// NewCompositeWatcher combines watchers into one persist.Watcher.
+func NewCompositeWatcher(watchers ...persist.Watcher) *CompositeWatcher {
+	return &CompositeWatcher{watchers: watchers}
+}
+
+// SetUpdateCallback sets callback on every watcher.
+func (c *CompositeWatcher) SetUpdateCallback(callback func(string)) error {
+	return c.each(func(w persist.Watcher) error { return w.SetUpdateCallback(callback) })
+}
+
+// Update calls Update on every watcher, even after one of them failed, and
+// returns the errors together.
+func (c *CompositeWatcher) Update() error {
+	return c.each(persist.Watcher.Update)
+}
+
+// Close closes every watcher.
+func (c *CompositeWatcher) Close() {
+	for _, w := range c.watchers {
+		w.Close()
+	}
+}
+
+func (c *CompositeWatcher) each(f func(persist.Watcher) error) error {
+	var errs []string
+	for i, w := range c.watchers {
+		if err := f(w); err != nil {
+			errs = append(errs, fmt.Sprintf("watcher %d: %v", i, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("rediswatcher: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
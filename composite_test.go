@@ -0,0 +1,36 @@
+package rediswatcher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2/persist"
+)
+
+var _ persist.Watcher = NoopWatcher{}
+
+func TestCompositeWatcher(t *testing.T) {
+	failing, ok := &fakeWatcher{failures: 1}, &fakeWatcher{}
+	w := NewCompositeWatcher(failing, NoopWatcher{}, ok)
+
+	err := w.Update()
+	if err == nil || !strings.Contains(err.Error(), "watcher 0: publish failed") || ok.updates != 1 {
+		t.Fatalf("Every watcher should be updated and the failure reported, got %v", err)
+	}
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed Update(): %v", err)
+	}
+
+	var received []string
+	w.SetUpdateCallback(func(msg string) { received = append(received, msg) })
+	failing.callback("a")
+	ok.callback("b")
+	if len(received) != 2 {
+		t.Fatalf("Updates of every watcher should be received, got %v", received)
+	}
+
+	w.Close()
+	if !failing.closed || !ok.closed {
+		t.Fatal("Close should close every watcher")
+	}
+}
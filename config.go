@@ -0,0 +1,133 @@
+package rediswatcher
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Config is the plain struct form of the common watcher options, for
+// configuration loaded from files. Zero fields keep the defaults of
+// NewWatcher.
+type Config struct {
+	Addr     string `json:"addr"` // Redis address "host:port", required.
+	Password string `json:"password"`
+	Channel  string `json:"channel"` // "/casbin" when empty.
+	LocalID  string `json:"localId"` // A random ID when empty.
+	Preset   string `json:"preset"`  // Applied before the other fields, see Preset.
+
+	TLS                   bool        `json:"tls"`
+	TLSServerName         string      `json:"tlsServerName"`
+	TLSInsecureSkipVerify bool        `json:"tlsInsecureSkipVerify"`
+	TLSConfig             *tls.Config `json:"-"` // Replaces the TLS fields above.
+
+	DialTimeout          time.Duration `json:"dialTimeout"`
+	ResubscribeThreshold time.Duration `json:"resubscribeThreshold"`
+	DrainTimeout         time.Duration `json:"drainTimeout"`
+	CallbackTimeout      time.Duration `json:"callbackTimeout"`
+	DebounceWindow       time.Duration `json:"debounceWindow"`
+
+	IgnoreSelf      bool `json:"ignoreSelf"`
+	SquashMessages  bool `json:"squashMessages"`
+	PublishOnly     bool `json:"publishOnly"`
+	SubscribeOnly   bool `json:"subscribeOnly"`
+	OrderedDelivery bool `json:"orderedDelivery"`
+	CallbackWorkers int  `json:"callbackWorkers"`
+
+	Options []WatcherOption `json:"-"` // Applied after the fields, e.g. hooks.
+}
+
+// Validate checks cfg and describes every problem found.
+func (cfg Config) Validate() error {
+	var problems []string
+	add := func(format string, v ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, v...))
+	}
+
+	if cfg.Addr == "" {
+		add("addr is required")
+	} else if !strings.Contains(cfg.Addr, ":") {
+		add("addr %q must be host:port", cfg.Addr)
+	}
+	if _, ok := presets[cfg.Preset]; cfg.Preset != "" && !ok {
+		add("preset %q is unknown, use one of %s", cfg.Preset, strings.Join(Presets(), ", "))
+	}
+	if strings.ContainsAny(cfg.Channel, "*?[") {
+		add("channel %q must not contain glob characters, see SubscribePatterns", cfg.Channel)
+	}
+	if cfg.PublishOnly && cfg.SubscribeOnly {
+		add("publishOnly and subscribeOnly exclude each other")
+	}
+	if !cfg.TLS && cfg.TLSConfig == nil && (cfg.TLSServerName != "" || cfg.TLSInsecureSkipVerify) {
+		add("tlsServerName and tlsInsecureSkipVerify require tls")
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"dialTimeout", cfg.DialTimeout},
+		{"resubscribeThreshold", cfg.ResubscribeThreshold},
+		{"drainTimeout", cfg.DrainTimeout},
+		{"callbackTimeout", cfg.CallbackTimeout},
+		{"debounceWindow", cfg.DebounceWindow},
+	} {
+		if d.value < 0 {
+			add("%s must not be negative, got %v", d.name, d.value)
+		}
+	}
+	if cfg.CallbackWorkers < 0 {
+		add("callbackWorkers must not be negative, got %d", cfg.CallbackWorkers)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("rediswatcher: invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// setters returns the options equivalent to cfg.
+func (cfg Config) setters() []WatcherOption {
+	var setters []WatcherOption
+	set := func(ok bool, setter WatcherOption) {
+		if ok {
+			setters = append(setters, setter)
+		}
+	}
+
+	if cfg.Preset != "" {
+		setters = append(setters, Preset(cfg.Preset))
+	}
+	set(cfg.Password != "", Password(cfg.Password))
+	set(cfg.Channel != "", Channel(cfg.Channel))
+	set(cfg.LocalID != "", LocalID(cfg.LocalID))
+	if cfg.TLSConfig != nil {
+		setters = append(setters, TLSConfig(cfg.TLSConfig))
+	} else if cfg.TLS {
+		setters = append(setters, TLSConfig(&tls.Config{
+			ServerName:         cfg.TLSServerName,
+			InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		}))
+	}
+	set(cfg.DialTimeout > 0, DialTimeout(cfg.DialTimeout))
+	set(cfg.ResubscribeThreshold > 0, ResubscribeThreshold(cfg.ResubscribeThreshold))
+	set(cfg.DrainTimeout > 0, DrainTimeout(cfg.DrainTimeout))
+	set(cfg.CallbackTimeout > 0, CallbackTimeout(cfg.CallbackTimeout))
+	set(cfg.DebounceWindow > 0, Debounce(cfg.DebounceWindow))
+	set(cfg.IgnoreSelf, IgnoreSelf(true))
+	set(cfg.SquashMessages, SquashMessages(true))
+	set(cfg.PublishOnly, PublishOnly(true))
+	set(cfg.SubscribeOnly, SubscribeOnly(true))
+	set(cfg.OrderedDelivery, OrderedDelivery(true))
+	set(cfg.CallbackWorkers > 0, CallbackWorkers(cfg.CallbackWorkers))
+	return append(setters, cfg.Options...)
+}
+
+// NewWatcherWithConfig validates cfg and creates a watcher from it, like
+// NewWatcher with the equivalent options.
+func NewWatcherWithConfig(cfg Config) (*Watcher, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newWatcher(cfg.Addr, cfg.setters()...)
+}
@@ -0,0 +1,60 @@
+package rediswatcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	err := Config{
+		Channel:               "/casbin/*",
+		PublishOnly:           true,
+		SubscribeOnly:         true,
+		TLSInsecureSkipVerify: true,
+		DrainTimeout:          -time.Second,
+	}.Validate()
+	if err == nil {
+		t.Fatal("The config should be invalid")
+	}
+	for _, problem := range []string{"addr is required", "glob characters", "exclude each other", "require tls", "drainTimeout must not be negative"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("The error should mention %q, got %v", problem, err)
+		}
+	}
+
+	if err := (Config{Addr: "localhost"}).Validate(); err == nil || !strings.Contains(err.Error(), "host:port") {
+		t.Fatalf("An address without port should be rejected, got %v", err)
+	}
+	if _, err := NewWatcherWithConfig(Config{}); err == nil {
+		t.Fatal("NewWatcherWithConfig should validate the config")
+	}
+}
+
+func TestNewWatcherWithConfig(t *testing.T) {
+	pub, sub := NewTestConn(), NewTestConn()
+	w, err := NewWatcherWithConfig(Config{
+		Addr:            "127.0.0.1:6379",
+		Channel:         "/policies",
+		TLS:             true,
+		TLSServerName:   "redis.internal",
+		DialTimeout:     3 * time.Second,
+		DebounceWindow:  time.Second,
+		IgnoreSelf:      true,
+		CallbackWorkers: 4,
+		Options:         []WatcherOption{WithRedisPubConnection(pub), WithRedisSubConnection(sub), ManualStart(true)},
+	})
+	if err != nil {
+		t.Fatalf("Failed NewWatcherWithConfig(): %v", err)
+	}
+	defer w.Close()
+
+	opts := w.GetWatcherOptions()
+	if opts.Channel != "/policies" || opts.TLSConfig == nil || opts.TLSConfig.ServerName != "redis.internal" ||
+		opts.DialTimeout != 3*time.Second || opts.DebounceWindow != time.Second || !opts.IgnoreSelf || opts.CallbackWorkers != 4 {
+		t.Fatalf("The config should be applied, got %+v", opts)
+	}
+	if opts.DrainTimeout != defaultCloseTimeout {
+		t.Fatalf("Zero fields should keep the defaults, got %v", opts.DrainTimeout)
+	}
+}
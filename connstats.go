@@ -0,0 +1,41 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats describes the Redis connections of a watcher, separate from the
+// message counters of Stats, to tell network problems from slow callbacks.
+type ConnStats struct {
+	PublishConnected   bool          // The publish connection is open and healthy.
+	SubscribeConnected bool          // The subscription is established.
+	Dials              int64         // Connections dialed successfully.
+	DialErrors         int64         // Dials that failed, including AUTH failures.
+	AuthFailures       int64         // Connections rejected by AUTH.
+	Reconnects         int64         // Subscriptions after the first one.
+	LastReconnect      time.Duration // Disconnection time before the last resubscribe.
+	TotalReconnect     time.Duration // Disconnection time before every resubscribe.
+}
+
+// ConnStats returns a snapshot of the connection statistics.
+func (w *Watcher) ConnStats() ConnStats {
+	w.stateMu.Lock()
+	state := w.state
+	w.stateMu.Unlock()
+
+	w.pubMu.Lock()
+	pubConnected := w.pubConn != nil && w.pubConn.Err() == nil
+	w.pubMu.Unlock()
+
+	return ConnStats{
+		PublishConnected:   pubConnected,
+		SubscribeConnected: state.subscribed,
+		Dials:              atomic.LoadInt64(&w.counters.dials),
+		DialErrors:         atomic.LoadInt64(&w.counters.dialErrors),
+		AuthFailures:       atomic.LoadInt64(&w.counters.authFailures),
+		Reconnects:         w.reconnects(),
+		LastReconnect:      state.lastReconnect,
+		TotalReconnect:     state.totalReconnect,
+	}
+}
@@ -0,0 +1,27 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnStats(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	w.options.Protocol = "tcp"
+
+	if _, err := w.dial("127.0.0.1:1"); err == nil {
+		t.Fatal("Dialing a closed port should fail")
+	}
+	w.setSubscribed(true)
+	w.setSubscribed(false)
+	time.Sleep(5 * time.Millisecond)
+	w.setSubscribed(true)
+
+	s := w.ConnStats()
+	if s.DialErrors != 1 || s.Dials != 0 || s.PublishConnected || !s.SubscribeConnected {
+		t.Fatalf("Unexpected connection stats %+v", s)
+	}
+	if s.Reconnects != 1 || s.LastReconnect < 5*time.Millisecond || s.TotalReconnect != s.LastReconnect {
+		t.Fatalf("Reconnect duration should be recorded, got %+v", s)
+	}
+}
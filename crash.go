@@ -0,0 +1,30 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// crashed counts a panic recovered in a background goroutine, hands it to the
+// CrashHandler and returns it as an error.
+func (w *Watcher) crashed(name string, r interface{}) error {
+	crashes := atomic.AddInt64(&w.crashes, 1)
+	err := fmt.Errorf("rediswatcher: %s crashed: %v", name, r)
+	if w.options.crashHandler != nil {
+		w.options.crashHandler(err, crashes)
+	}
+	return err
+}
+
+// restartOnPanic runs fn and reports whether it panicked, in which case the
+// panic has been reported and the caller should run fn again.
+func (w *Watcher) restartOnPanic(name string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.reportError(w.crashed(name, r))
+			panicked = !w.isClosed()
+		}
+	}()
+	fn()
+	return false
+}
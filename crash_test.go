@@ -0,0 +1,46 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestMessageProcessorRestart(t *testing.T) {
+	w := &Watcher{
+		closed:      make(chan struct{}),
+		messagesIn:  make(chan redis.Message),
+		callbackSet: make(chan struct{}, 1),
+	}
+	w.options.SquashTimeoutLong = time.Second
+
+	crashes := make(chan int64, 1)
+	CrashHandler(func(err error, n int64) { crashes <- n })(&w.options)
+	MessageFilter(func(msg string) bool {
+		if msg == "boom" {
+			panic("filter failed")
+		}
+		return true
+	})(&w.options)
+
+	ch := make(chan string, 1)
+	w.SetUpdateCallback(func(msg string) { ch <- msg })
+	w.messageInProcessor()
+	defer close(w.closed)
+
+	w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte("boom")}
+	if n := <-crashes; n != 1 {
+		t.Fatalf("Crash hook should count the first crash, got %d", n)
+	}
+
+	w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte("node2")}
+	select {
+	case res := <-ch:
+		if res != "node2" {
+			t.Fatalf("Message should be 'node2', received '%s' instead", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Message processor was not restarted after the crash")
+	}
+}
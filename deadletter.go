@@ -0,0 +1,110 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// DeadLetter is a message whose update callback failed, as stored in the
+// DeadLetterList.
+type DeadLetter struct {
+	Message string    `json:"message"`
+	Channel string    `json:"channel"`
+	LocalID string    `json:"localId"`
+	Error   string    `json:"error"`
+	Time    time.Time `json:"time"`
+}
+
+func (w *Watcher) deadLetter(data string, cause error) error {
+	b, _ := json.Marshal(DeadLetter{
+		Message: data,
+		Channel: w.channel(),
+		LocalID: w.options.LocalID,
+		Error:   cause.Error(),
+		Time:    time.Now(),
+	})
+
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+
+	startTime := time.Now()
+	c, err := w.publisher()
+	if err == nil {
+		_, err = c.Do("RPUSH", w.options.DeadLetterList, b)
+	}
+	if w.options.RecordMetrics != nil {
+		w.options.RecordMetrics(w.createMetrics(DeadLetterMetric, startTime, err))
+	}
+	return err
+}
+
+// DeadLetters returns the messages currently held in the DeadLetterList,
+// oldest first.
+func (w *Watcher) DeadLetters() ([]DeadLetter, error) {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+
+	c, err := w.publisher()
+	if err != nil {
+		return nil, err
+	}
+	values, err := redis.ByteSlices(c.Do("LRANGE", w.options.DeadLetterList, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]DeadLetter, 0, len(values))
+	for _, v := range values {
+		var l DeadLetter
+		if err := json.Unmarshal(v, &l); err != nil {
+			return nil, err
+		}
+		letters = append(letters, l)
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetters removes every message from the DeadLetterList and runs
+// the update callback for it again; messages that still fail are
+// dead-lettered anew. It returns the number of messages replayed.
+func (w *Watcher) ReplayDeadLetters() (int, error) {
+	if w.getCallback() == nil {
+		return 0, fmt.Errorf("no update callback set")
+	}
+
+	w.pubMu.Lock()
+	c, err := w.publisher()
+	if err != nil {
+		w.pubMu.Unlock()
+		return 0, err
+	}
+	count, err := redis.Int(c.Do("LLEN", w.options.DeadLetterList))
+	w.pubMu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	// only replay what is there now, failures are appended to the list again
+	for n := 0; n < count; n++ {
+		w.pubMu.Lock()
+		v, err := redis.Bytes(c.Do("LPOP", w.options.DeadLetterList))
+		w.pubMu.Unlock()
+		if err == redis.ErrNil {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		var l DeadLetter
+		if err := json.Unmarshal(v, &l); err != nil {
+			return n, err
+		}
+		if err := w.callCallback(w.baseContext(), l.Message); err != nil {
+			w.deadLetter(l.Message, err)
+		}
+	}
+	return count, nil
+}
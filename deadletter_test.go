@@ -0,0 +1,47 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestDeadLetterList(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), DeadLetterList("casbin:dlq"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	pub.GenericCommand("RPUSH").Expect(int64(1))
+
+	w.SetUpdateCallbackWithError(func(msg string) error {
+		return fmt.Errorf("db unavailable")
+	})
+	w.runCallback("node2")
+
+	pushed := pub.calls("RPUSH")
+	if len(pushed) != 1 || pushed[0][0] != "casbin:dlq" {
+		t.Fatalf("Failed message should be pushed to the dead letter list, got %v", pushed)
+	}
+	var l DeadLetter
+	if err := json.Unmarshal(pushed[0][1].([]byte), &l); err != nil {
+		t.Fatalf("Dead letter is not valid JSON: %v", err)
+	}
+	if l.Message != "node2" || l.Error != "db unavailable" || l.LocalID != "node1" || l.Channel != "/casbin" {
+		t.Fatalf("Unexpected dead letter: %+v", l)
+	}
+
+	// panics are dead-lettered as well
+	w.SetUpdateCallback(func(msg string) {
+		panic("boom")
+	})
+	w.runCallback("node2")
+	if len(pub.calls("RPUSH")) != 2 {
+		t.Fatal("Panicking callback should be dead-lettered")
+	}
+}
@@ -0,0 +1,44 @@
+package rediswatcher
+
+import "sync"
+
+type debounceState struct {
+	mu    sync.Mutex
+	timer Timer
+	batch []string
+	last  Delivery
+}
+
+// debounce collects data for the DebounceWindow, which starts with the first
+// message of a burst, and then delivers the last message received, or the
+// deduplicated burst to a batch callback.
+func (w *Watcher) debounce(data string, d Delivery) {
+	w.debounced.mu.Lock()
+	defer w.debounced.mu.Unlock()
+
+	// keep the position of the last occurrence
+	for i, msg := range w.debounced.batch {
+		if msg == data {
+			w.debounced.batch = append(w.debounced.batch[:i], w.debounced.batch[i+1:]...)
+			break
+		}
+	}
+	w.debounced.batch = append(w.debounced.batch, data)
+	w.debounced.last = d
+	if w.debounced.timer == nil {
+		w.debounced.timer = w.clock().AfterFunc(w.debounceWindow(), w.flushDebounce)
+	}
+}
+
+func (w *Watcher) flushDebounce() {
+	w.debounced.mu.Lock()
+	batch, d := w.debounced.batch, w.debounced.last
+	w.debounced.batch = nil
+	w.debounced.last = Delivery{}
+	w.debounced.timer = nil
+	w.debounced.mu.Unlock()
+
+	if !w.isClosed() && len(batch) > 0 {
+		w.dispatchJob(job{msg: batch[len(batch)-1], batch: batch, delivery: d})
+	}
+}
@@ -0,0 +1,58 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	Debounce(20 * time.Millisecond)(&w.options)
+	defer close(w.closed)
+
+	ch := make(chan string, 10)
+	w.SetUpdateCallback(func(msg string) { ch <- msg })
+
+	for _, id := range []string{"node2", "node3", "node4"} {
+		w.deliver(id)
+	}
+	select {
+	case res := <-ch:
+		if res != "node4" {
+			t.Fatalf("Debounced callback should get the last message, got '%s'", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Debounced message was not delivered")
+	}
+
+	select {
+	case res := <-ch:
+		t.Fatalf("Burst should trigger a single callback, also got '%s'", res)
+	case <-time.After(40 * time.Millisecond):
+	}
+}
+
+func TestBatchCallback(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	Debounce(20 * time.Millisecond)(&w.options)
+	defer close(w.closed)
+
+	ch := make(chan []string, 1)
+	w.SetBatchCallback(func(msgs []string) error {
+		ch <- msgs
+		return nil
+	})
+
+	for _, id := range []string{"node2", "node3", "node2", "node4"} {
+		w.deliver(id)
+	}
+	select {
+	case batch := <-ch:
+		if fmt.Sprint(batch) != "[node3 node2 node4]" {
+			t.Fatalf("Batch should hold the deduplicated burst, got %v", batch)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Batch callback was not invoked")
+	}
+}
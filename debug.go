@@ -0,0 +1,103 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const redacted = "[redacted]"
+
+// debugInfo is the document written by DebugJSON.
+type debugInfo struct {
+	Addr    string                 `json:"addr"`
+	Closed  bool                   `json:"closed"`
+	Options map[string]interface{} `json:"options"`
+	Stats   Stats                  `json:"stats"`
+	Errors  []debugError           `json:"errors"`
+	Queues  debugQueues            `json:"queues"`
+}
+
+type debugError struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+type debugQueues struct {
+	Early     []string `json:"early"`     // Messages waiting for an update callback.
+	Debounced []string `json:"debounced"` // Messages collected in the debounce window.
+	Jobs      int      `json:"jobs"`      // Updates waiting for a callback worker.
+}
+
+// DebugJSON dumps the effective options, with secrets redacted, the
+// connection state, counters, recent errors and queued messages of the
+// watcher as JSON, for debugging a live process.
+func (w *Watcher) DebugJSON() ([]byte, error) {
+	info := debugInfo{
+		Addr:    w.addr,
+		Closed:  w.isClosed(),
+		Options: debugOptions(w.GetWatcherOptions()),
+		Stats:   w.Stats(),
+		Errors:  []debugError{},
+	}
+	for _, e := range w.Errors() {
+		info.Errors = append(info.Errors, debugError{Time: e.Time, Error: e.Err.Error()})
+	}
+
+	w.earlyMu.Lock()
+	info.Queues.Early = append([]string{}, w.early...)
+	w.earlyMu.Unlock()
+	w.debounced.mu.Lock()
+	info.Queues.Debounced = append([]string{}, w.debounced.batch...)
+	w.debounced.mu.Unlock()
+	info.Queues.Jobs = len(w.jobs)
+
+	return json.MarshalIndent(info, "", "  ")
+}
+
+// Options returns the effective options of the watcher, after the defaults
+// and the ChannelPrefix were applied, as plain values by field name, e.g. to
+// log what the watcher runs with. The Password is redacted, connections,
+// loggers and hooks are left out; GetWatcherOptions returns them all.
+func (w *Watcher) Options() map[string]interface{} {
+	return debugOptions(w.GetWatcherOptions())
+}
+
+// debugOptions lists the exported options that can be shown as plain
+// values. Connections, loggers and hooks are left out.
+func debugOptions(options WatcherOptions) map[string]interface{} {
+	if options.Password != "" {
+		options.Password = redacted
+	}
+
+	v := reflect.ValueOf(options)
+	out := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Func, reflect.Interface, reflect.Chan, reflect.Ptr:
+			continue
+		}
+		out[field.Name] = v.Field(i).Interface()
+	}
+	out["TLS"] = options.TLSConfig != nil
+	return out
+}
+
+// String describes the watcher in one line for logging.
+func (w *Watcher) String() string {
+	state := "subscribed"
+	switch {
+	case w.isClosed():
+		state = "closed"
+	case w.messagesIn == nil:
+		state = "publishing"
+	case !w.isSubscribed():
+		state = "disconnected"
+	}
+	return fmt.Sprintf("rediswatcher(%s %s id=%s %s)", w.addr, w.channel(), w.options.LocalID, state)
+}
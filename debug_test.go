@@ -0,0 +1,67 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDebugJSON(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), Password("secret"), LocalID("node-1"), EarlyBufferSize(4))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	w.reportError(errors.New("boom"))
+	w.bufferEarlyMessage("early")
+
+	b, err := w.DebugJSON()
+	if err != nil {
+		t.Fatalf("Failed watcher.DebugJSON(): %v", err)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Fatalf("Password must be redacted: %s", b)
+	}
+
+	var info struct {
+		Options map[string]interface{}
+		Errors  []struct{ Error string }
+		Queues  struct{ Early []string }
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		t.Fatalf("DebugJSON should return valid JSON: %v", err)
+	}
+	if info.Options["LocalID"] != "node-1" || info.Options["Password"] != redacted {
+		t.Fatalf("Options should be included, got %v", info.Options)
+	}
+	if len(info.Errors) != 1 || info.Errors[0].Error != "boom" {
+		t.Fatalf("Errors should be included, got %v", info.Errors)
+	}
+	if len(info.Queues.Early) != 1 || info.Queues.Early[0] != "early" {
+		t.Fatalf("Early messages should be included, got %v", info.Queues.Early)
+	}
+
+	if s := w.String(); !strings.Contains(s, "node-1") || !strings.Contains(s, "/casbin") {
+		t.Fatalf("String should describe the watcher, got %s", s)
+	}
+}
+
+func TestEffectiveOptions(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	w.options = WatcherOptions{Channel: "/casbin", Password: "secret", ChannelPrefix: "prod:", PubConn: NewTestConn()}
+	w.applyChannelPrefix()
+
+	opts := w.Options()
+	if opts["Password"] != redacted || opts["Channel"] != "prod:/casbin" || opts["TLS"] != false {
+		t.Fatalf("Options should show the effective, redacted settings, got %v", opts)
+	}
+	if _, ok := opts["PubConn"]; ok {
+		t.Fatal("Connections should be left out")
+	}
+	if w.GetWatcherOptions().Password != "secret" {
+		t.Fatal("GetWatcherOptions should stay unredacted")
+	}
+}
@@ -0,0 +1,144 @@
+package rediswatcher
+
+import (
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+)
+
+// Decorator wraps a persist.Watcher, this one or any other, to layer
+// behaviour such as logging or retries onto it without changing it.
+type Decorator func(persist.Watcher) persist.Watcher
+
+// Decorate wraps w in decorators, the first one outermost:
+//
+//	w = rediswatcher.Decorate(w,
+//		rediswatcher.LoggingDecorator(logger),
+//		rediswatcher.RetryDecorator(3, 100*time.Millisecond))
+func Decorate(w persist.Watcher, decorators ...Decorator) persist.Watcher {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		w = decorators[i](w)
+	}
+	return w
+}
+
+// decorated implements persist.Watcher from hooks around the wrapped one.
+type decorated struct {
+	persist.Watcher
+	update   func() error
+	callback func(callback func(string)) func(string)
+}
+
+func (d *decorated) Update() error {
+	if d.update == nil {
+		return d.Watcher.Update()
+	}
+	return d.update()
+}
+
+func (d *decorated) SetUpdateCallback(callback func(string)) error {
+	if d.callback != nil {
+		callback = d.callback(callback)
+	}
+	return d.Watcher.SetUpdateCallback(callback)
+}
+
+// LoggingDecorator logs every Update, with its error, and every update
+// callback run.
+func LoggingDecorator(logger Logger) Decorator {
+	return func(w persist.Watcher) persist.Watcher {
+		d := &decorated{Watcher: w}
+		d.update = func() error {
+			err := w.Update()
+			if err != nil {
+				logger.Printf("rediswatcher: update failed: %v", err)
+			} else {
+				logger.Printf("rediswatcher: update published")
+			}
+			return err
+		}
+		d.callback = func(callback func(string)) func(string) {
+			return func(msg string) {
+				logger.Printf("rediswatcher: update received: %s", msg)
+				callback(msg)
+			}
+		}
+		return d
+	}
+}
+
+// MetricsDecorator records a WatcherUpdateMetric for every Update and a
+// WatcherCallbackMetric for every update callback run.
+func MetricsDecorator(record func(*WatcherMetrics)) Decorator {
+	measure := func(name string, start time.Time, err error, size int) {
+		record(&WatcherMetrics{
+			Name:        name,
+			LatencyMs:   float64(time.Since(start)) / float64(time.Millisecond),
+			Error:       err,
+			MessageSize: int64(size),
+		})
+	}
+	return func(w persist.Watcher) persist.Watcher {
+		d := &decorated{Watcher: w}
+		d.update = func() error {
+			start := time.Now()
+			err := w.Update()
+			measure(WatcherUpdateMetric, start, err, 0)
+			return err
+		}
+		d.callback = func(callback func(string)) func(string) {
+			return func(msg string) {
+				start := time.Now()
+				callback(msg)
+				measure(WatcherCallbackMetric, start, nil, len(msg))
+			}
+		}
+		return d
+	}
+}
+
+// TracingDecorator calls start before every Update ("Update") and update
+// callback run ("Callback") and the function it returns with the outcome,
+// e.g. to start and end a span of any tracing library.
+func TracingDecorator(start func(operation string) (end func(err error))) Decorator {
+	return func(w persist.Watcher) persist.Watcher {
+		d := &decorated{Watcher: w}
+		d.update = func() error {
+			end := start("Update")
+			err := w.Update()
+			end(err)
+			return err
+		}
+		d.callback = func(callback func(string)) func(string) {
+			return func(msg string) {
+				end := start("Callback")
+				defer end(nil)
+				callback(msg)
+			}
+		}
+		return d
+	}
+}
+
+// RetryDecorator retries a failed Update up to retries times, waiting
+// backoff before the first retry and doubling it after each. Updates of a
+// closed or subscribe-only watcher are not retried.
+func RetryDecorator(retries int, backoff time.Duration) Decorator {
+	return func(w persist.Watcher) persist.Watcher {
+		d := &decorated{Watcher: w}
+		d.update = func() error {
+			err := w.Update()
+			wait := backoff
+			for attempt := 0; err != nil && attempt < retries; attempt++ {
+				if err == ErrClosed || err == ErrSubscribeOnly {
+					break
+				}
+				time.Sleep(wait)
+				wait *= 2
+				err = w.Update()
+			}
+			return err
+		}
+		return d
+	}
+}
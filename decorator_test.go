@@ -0,0 +1,83 @@
+package rediswatcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeWatcher is a persist.Watcher failing the first failures updates.
+type fakeWatcher struct {
+	failures int
+	updates  int
+	callback func(string)
+	closed   bool
+}
+
+func (f *fakeWatcher) SetUpdateCallback(callback func(string)) error {
+	f.callback = callback
+	return nil
+}
+
+func (f *fakeWatcher) Update() error {
+	f.updates++
+	if f.updates <= f.failures {
+		return errors.New("publish failed")
+	}
+	return nil
+}
+
+func (f *fakeWatcher) Close() { f.closed = true }
+
+type logRecorder struct{ lines []string }
+
+func (l *logRecorder) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestDecorate(t *testing.T) {
+	inner := &fakeWatcher{failures: 2}
+	logs := &logRecorder{}
+	var metrics []*WatcherMetrics
+	var spans []string
+	w := Decorate(inner,
+		LoggingDecorator(logs),
+		MetricsDecorator(func(m *WatcherMetrics) { metrics = append(metrics, m) }),
+		TracingDecorator(func(op string) func(error) {
+			return func(err error) { spans = append(spans, fmt.Sprintf("%s:%v", op, err)) }
+		}),
+		RetryDecorator(2, 0))
+
+	if err := w.Update(); err != nil || inner.updates != 3 {
+		t.Fatalf("The update should succeed on the last retry, got %v after %d updates", err, inner.updates)
+	}
+	if len(logs.lines) != 1 || logs.lines[0] != "rediswatcher: update published" {
+		t.Fatalf("The outer logger should see one update, got %v", logs.lines)
+	}
+	if len(metrics) != 1 || metrics[0].Name != WatcherUpdateMetric || metrics[0].Error != nil {
+		t.Fatalf("Unexpected metrics %+v", metrics)
+	}
+
+	var received string
+	w.SetUpdateCallback(func(msg string) { received = msg })
+	inner.callback("node2")
+	if received != "node2" || !strings.Contains(logs.lines[1], "node2") || metrics[1].Name != WatcherCallbackMetric {
+		t.Fatalf("The callback should be wrapped by every decorator, got %q, %v, %+v", received, logs.lines, metrics)
+	}
+	if len(spans) != 2 || spans[0] != "Update:<nil>" || spans[1] != "Callback:<nil>" {
+		t.Fatalf("Unexpected spans %v", spans)
+	}
+
+	w.Close()
+	if !inner.closed {
+		t.Fatal("Close should reach the wrapped watcher")
+	}
+}
+
+func TestRetryDecoratorGivesUp(t *testing.T) {
+	inner := &fakeWatcher{failures: 5}
+	if err := RetryDecorator(2, 0)(inner).Update(); err == nil || inner.updates != 3 {
+		t.Fatalf("Update should fail after two retries, got %v after %d updates", err, inner.updates)
+	}
+}
@@ -0,0 +1,153 @@
+package rediswatcher
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// IsLoader reports whether the watcher holds the DelegatedReload key and
+// reloads the policy for its peers.
+func (w *Watcher) IsLoader() bool {
+	return w.options.delegateKey != "" && atomic.LoadInt32(&w.loader) == 1
+}
+
+func (w *Watcher) startLoaderElection() {
+	if w.options.delegateKey == "" {
+		return
+	}
+
+	w.spawn(func() {
+		ticker := w.clock().NewTicker(w.options.delegateTTL / 3)
+		defer ticker.Stop()
+		for {
+			if err := w.campaignLoader(); err != nil {
+				w.reportError(err)
+			}
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+			}
+		}
+	})
+}
+
+// campaignLoader takes or renews the DelegatedReload key, stepping down on
+// failure like campaign.
+func (w *Watcher) campaignLoader() error {
+	won, err := w.holdKey(w.options.delegateKey, w.options.delegateTTL)
+	w.setLoader(won)
+	return err
+}
+
+func (w *Watcher) setLoader(loader bool) {
+	var v int32
+	if loader {
+		v = 1
+	}
+	if atomic.SwapInt32(&w.loader, v) != v {
+		w.logEvent(levelInfo, "loader changed", "loader", loader)
+	}
+}
+
+// resignLoader hands the DelegatedReload key back when the watcher closes.
+func (w *Watcher) resignLoader() {
+	if w.options.delegateKey == "" || !w.IsLoader() {
+		return
+	}
+
+	if err := w.releaseKey(w.options.delegateKey); err != nil {
+		w.logEvent(levelWarn, "resigning as loader failed", "error", err)
+	}
+	w.setLoader(false)
+}
+
+// delegatedReload is the update callback in DelegatedReload mode, run like
+// the one of SetUpdateCallback: the loader reloads for its peers, which
+// apply the snapshots it sends. A peer reloads itself when no snapshot is
+// coming or it expired.
+func (w *Watcher) delegatedReload(_ context.Context, msg string) error {
+	m, structured := decodeHeader(msg)
+	switch {
+	case structured && m.Snapshot != "":
+		if m.ID == w.options.LocalID {
+			return nil // loaded it ourselves
+		}
+		snapshot, err := w.LoadSnapshot(msg)
+		if err == ErrNoSnapshot {
+			_, err = w.options.delegateLoad()
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		return w.options.delegateApply(snapshot)
+	case w.IsLoader():
+		return w.reloadForPeers()
+	case w.awaitsSnapshot(m, structured, msg):
+		return nil // the loader answers with a snapshot
+	default:
+		_, err := w.options.delegateLoad()
+		return err
+	}
+}
+
+// awaitsSnapshot reports whether a loader other than the sender of an update
+// holds the DelegatedReload key. Without one, or when the update is the
+// answer of the loader downgraded to a plain update for peers lacking
+// snapshots, see Hello, no snapshot follows.
+func (w *Watcher) awaitsSnapshot(m Message, structured bool, msg string) bool {
+	sender := msg
+	if structured {
+		sender = m.ID
+	}
+	loader, err := w.currentLoader()
+	return err == nil && loader != "" && loader != sender
+}
+
+// currentLoader returns the LocalID of the loader, empty when there is none.
+func (w *Watcher) currentLoader() (string, error) {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return "", err
+	}
+	loader, err := redis.String(c.Do("GET", w.options.delegateKey))
+	if err == redis.ErrNil {
+		return "", nil
+	}
+	return loader, err
+}
+
+// delegatedResync is Resync in DelegatedReload mode: the loader reloads for
+// its peers, the others reload themselves.
+func (w *Watcher) delegatedResync() {
+	var err error
+	if w.IsLoader() {
+		err = w.reloadForPeers()
+	} else {
+		_, err = w.options.delegateLoad()
+	}
+	if err == nil {
+		atomic.StoreInt64(&w.counters.lastReload, time.Now().UnixNano())
+	} else {
+		atomic.AddInt64(&w.counters.failed, 1)
+		w.reportError(err)
+	}
+}
+
+// reloadForPeers loads the policy and broadcasts it as a snapshot.
+func (w *Watcher) reloadForPeers() error {
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+	snapshot, err := w.options.delegateLoad()
+	if err != nil {
+		return err
+	}
+	return w.storeSnapshot(snapshot)
+}
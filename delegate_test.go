@@ -0,0 +1,118 @@
+package rediswatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDelegatedReload(t *testing.T) {
+	kv := newKVConn()
+	loads := 0
+	load := func() ([]byte, error) {
+		loads++
+		return []byte("p, alice, data1, read"), nil
+	}
+	var applied []string
+	apply := func(policy []byte) error {
+		applied = append(applied, string(policy))
+		return nil
+	}
+	newWatcher := func(id string) *Watcher {
+		w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(kv), WithRedisSubConnection(NewTestConn()),
+			LocalID(id), ManualStart(true), DelegatedReload("casbin:loader", time.Second, load, apply))
+		if err != nil {
+			t.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		return w
+	}
+	loader := newWatcher("node1")
+	defer loader.Close()
+	peer := newWatcher("node2")
+	defer peer.Close()
+	peer.SetUpdateCallback(func(string) {
+		t.Error("The update callback should not run for delegated reloads")
+	})
+
+	loader.setLoader(true)
+	kv.Do("SET", "casbin:loader", "node1")
+	if !loader.IsLoader() || peer.IsLoader() {
+		t.Fatal("Only node1 should be the loader")
+	}
+	if err := loader.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+	if loads != 1 || len(kv.messages) != 1 {
+		t.Fatalf("The loader should reload once and broadcast, got %d loads and %v", loads, kv.messages)
+	}
+	snapshot := kv.messages[0]
+	if m, _ := decodeMessage(snapshot); m.Snapshot == "" {
+		t.Fatalf("The broadcast should carry a snapshot, got %s", snapshot)
+	}
+
+	peer.runJob(singleJob(snapshot))
+	if len(applied) != 1 || applied[0] != "p, alice, data1, read" {
+		t.Fatalf("The peer should apply the snapshot, got %v", applied)
+	}
+	loader.runJob(singleJob(snapshot))
+	if loads != 1 || len(applied) != 1 {
+		t.Fatal("The loader should ignore its own snapshot")
+	}
+
+	// an update of another node is answered by the loader only
+	peer.runJob(singleJob("node3"))
+	loader.runJob(singleJob("node3"))
+	if loads != 2 || len(kv.messages) != 2 {
+		t.Fatalf("Only the loader should reload, got %d loads and %d broadcasts", loads, len(kv.messages))
+	}
+	if s := peer.Stats(); s.LastReload.IsZero() || s.FailedUpdates != 0 {
+		t.Fatalf("Applying should count as a reload, got %+v", s)
+	}
+
+	// no snapshot follows a downgraded answer of the loader, a Resync, or an
+	// update without a loader
+	peer.runJob(singleJob("node1"))
+	if loads != 3 {
+		t.Fatalf("The peer should reload itself on a plain update of the loader, got %d loads", loads)
+	}
+	if err := peer.Resync(); err != nil || loads != 4 {
+		t.Fatalf("Resync should reload the peer, got %v and %d loads", err, loads)
+	}
+	kv.Do("DEL", "casbin:loader")
+	peer.runJob(singleJob("node3"))
+	if loads != 5 || len(kv.messages) != 2 {
+		t.Fatalf("The peer should reload itself without a loader, got %d loads and %d broadcasts", loads, len(kv.messages))
+	}
+
+	// an expired snapshot is loaded in full
+	expired := encodeMessage(Message{Type: MessageTypeUpdate, ID: "node1", Snapshot: "casbin:loader:gone"})
+	peer.runJob(singleJob(expired))
+	if loads != 6 || len(applied) != 1 {
+		t.Fatalf("The peer should reload itself when the snapshot expired, got %d loads", loads)
+	}
+
+	// UpdateWithContext of the loader reloads for its peers too
+	kv.Do("SET", "casbin:loader", "node1")
+	if err := loader.UpdateWithContext(context.Background()); err != nil || loads != 7 || len(kv.messages) != 3 {
+		t.Fatalf("The loader should reload and broadcast, got %v, %d loads and %d broadcasts", err, loads, len(kv.messages))
+	}
+
+	// a panicking apply is recovered like a panicking callback
+	panicky, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(kv), WithRedisSubConnection(NewTestConn()),
+		LocalID("node3"), ManualStart(true), DelegatedReload("casbin:loader", time.Second, load, func([]byte) error {
+			panic("boom")
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer panicky.Close()
+	panicky.runJob(singleJob(kv.messages[2]))
+	if s := panicky.Stats(); s.FailedUpdates != 1 {
+		t.Fatalf("The panic should count as a failed update, got %+v", s)
+	}
+
+	_, err = newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(kv), DelegatedReload("casbin:loader", 0, load, apply))
+	if err == nil {
+		t.Fatal("DelegatedReload without a ttl should be rejected")
+	}
+}
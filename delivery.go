@@ -0,0 +1,110 @@
+package rediswatcher
+
+import (
+	"context"
+	"time"
+)
+
+// Delivery describes where and when a received update came from.
+type Delivery struct {
+	Channel    string    // Channel the update was published on.
+	Pattern    string    // Subscribed pattern matching Channel, see SubscribePatterns.
+	ReceivedAt time.Time // When the watcher received the update.
+}
+
+const deliveryKey contextKey = channelKey + 1
+
+// DeliveryFromContext returns the Delivery of the update handled by a
+// callback set with SetUpdateCallbackWithContext, so multi-channel and
+// pattern subscriptions can act per source. Updates not received from
+// Redis, e.g. replayed by ReplayAudit or Resync, and those buffered before a
+// callback was set have none. Squashed and debounced updates carry the
+// Delivery of their last message.
+func DeliveryFromContext(ctx context.Context) (Delivery, bool) {
+	d, ok := ctx.Value(deliveryKey).(Delivery)
+	return d, ok
+}
+
+// newDelivery describes an update received now on channel.
+func (w *Watcher) newDelivery(channel string) Delivery {
+	d := Delivery{Channel: channel, ReceivedAt: time.Now()}
+	for _, pattern := range w.options.patterns {
+		if matchPattern(pattern, channel) {
+			d.Pattern = pattern
+			break
+		}
+	}
+	return d
+}
+
+// matchPattern reports whether channel matches the Redis glob pattern, with
+// the same rules as PSUBSCRIBE: * and ? match any characters including '/',
+// [...] a class, optionally negated with ^ and with ranges, and \ escapes.
+func matchPattern(pattern, channel string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(channel); i++ {
+				if matchPattern(pattern[1:], channel[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(channel) == 0 {
+				return false
+			}
+		case '[':
+			if len(channel) == 0 {
+				return false
+			}
+			end := 1
+			negate := end < len(pattern) && pattern[end] == '^'
+			if negate {
+				end++
+			}
+			matched := false
+			for end < len(pattern) && pattern[end] != ']' {
+				switch {
+				case pattern[end] == '\\' && end+1 < len(pattern):
+					end++
+					matched = matched || pattern[end] == channel[0]
+				case end+2 < len(pattern) && pattern[end+1] == '-' && pattern[end+2] != ']':
+					lo, hi := pattern[end], pattern[end+2]
+					if lo > hi {
+						lo, hi = hi, lo
+					}
+					matched = matched || (channel[0] >= lo && channel[0] <= hi)
+					end += 2
+				default:
+					matched = matched || pattern[end] == channel[0]
+				}
+				end++
+			}
+			if matched == negate {
+				return false
+			}
+			if end < len(pattern) {
+				end++ // the closing ]
+			}
+			pattern = pattern[end-1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(channel) == 0 || pattern[0] != channel[0] {
+				return false
+			}
+		}
+		pattern, channel = pattern[1:], channel[1:]
+	}
+	return len(channel) == 0
+}
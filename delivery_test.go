@@ -0,0 +1,62 @@
+package rediswatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestMatchPattern(t *testing.T) {
+	for _, c := range []struct {
+		pattern, channel string
+		match            bool
+	}{
+		{"/casbin/*", "/casbin/tenant1", true},
+		{"/casbin/*", "/casbin/a/b", true},
+		{"/casbin/*", "/other", false},
+		{"/casbin/t?", "/casbin/t1", true},
+		{"/casbin/t?", "/casbin/t12", false},
+		{"/casbin/[ab]x", "/casbin/bx", true},
+		{"/casbin/[^ab]x", "/casbin/bx", false},
+		{"/casbin/[a-c]", "/casbin/b", true},
+		{"/casbin/\\*", "/casbin/*", true},
+		{"/casbin/\\*", "/casbin/x", false},
+		{"*", "", true},
+	} {
+		if got := matchPattern(c.pattern, c.channel); got != c.match {
+			t.Errorf("matchPattern(%q, %q) = %v", c.pattern, c.channel, got)
+		}
+	}
+}
+
+func TestDeliveryFromContext(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{}), messagesIn: make(chan redis.Message, 1), callbackSet: make(chan struct{}, 1)}
+	w.options = WatcherOptions{Channel: "/casbin", SquashTimeoutLong: time.Minute}
+	SubscribePatterns("/casbin/*")(&w.options)
+	defer w.Close()
+
+	got := make(chan Delivery, 1)
+	w.SetUpdateCallbackWithContext(func(ctx context.Context, msg string) error {
+		d, _ := DeliveryFromContext(ctx)
+		got <- d
+		return nil
+	})
+	w.messageInProcessor()
+	before := time.Now()
+	w.messagesIn <- redis.Message{Channel: "/casbin/tenant1", Data: []byte("node2")}
+
+	select {
+	case d := <-got:
+		if d.Channel != "/casbin/tenant1" || d.Pattern != "/casbin/*" || d.ReceivedAt.Before(before) {
+			t.Fatalf("Unexpected delivery %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The update callback should run")
+	}
+
+	if _, ok := DeliveryFromContext(context.Background()); ok {
+		t.Fatal("A context without delivery should report none")
+	}
+}
@@ -0,0 +1,97 @@
+package rediswatcher
+
+import "github.com/garyburd/redigo/redis"
+
+// UpdateForDomain publishes an update on the channel of domain, see
+// DomainChannel, so only watchers listening to that domain reload. Updates
+// that end up in the PublishQueue are replayed on the watcher channel.
+func (w *Watcher) UpdateForDomain(domain string) error {
+	return w.updateOn(w.DomainChannel(domain))
+}
+
+// updateOn publishes an update on channel instead of the watcher channel.
+func (w *Watcher) updateOn(channel string) error {
+	if w.suppressUpdate() {
+		return nil
+	}
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+
+	return w.publishLocked(func() error {
+		w.pubChannel = channel
+		defer func() { w.pubChannel = "" }()
+		return w.publishOrQueue(w.options.LocalID)
+	})
+}
+
+// DomainChannel returns the channel carrying the updates of domain, by
+// default the watcher channel followed by "/" and the domain, e.g.
+// /casbin/tenant1, or the one returned by the ChannelResolver.
+func (w *Watcher) DomainChannel(domain string) string {
+	if w.options.channelResolver != nil {
+		return w.prefixed(w.options.channelResolver(domain))
+	}
+	return w.channel() + "/" + domain
+}
+
+// SubscribeDomain adds the channel of domain to the channels of a running
+// watcher, e.g. when a tenant is provisioned.
+func (w *Watcher) SubscribeDomain(domain string) error {
+	return w.subscribeChannel(w.DomainChannel(domain))
+}
+
+// UnsubscribeDomain leaves the channel of domain, whether it was subscribed
+// with Domains or SubscribeDomain.
+func (w *Watcher) UnsubscribeDomain(domain string) error {
+	w.channelMu.Lock()
+	for i, d := range w.options.domains {
+		if d == domain {
+			w.options.domains = append(w.options.domains[:i:i], w.options.domains[i+1:]...)
+			w.channelMu.Unlock()
+			if w.subDone == nil {
+				return nil
+			}
+			w.subMu.Lock()
+			defer w.subMu.Unlock()
+			if c := w.subscriptionConn(w.DomainChannel(domain)); c != nil {
+				return redis.PubSubConn{Conn: c}.Unsubscribe(w.DomainChannel(domain))
+			}
+			return nil
+		}
+	}
+	w.channelMu.Unlock()
+	return w.unsubscribeChannel(w.DomainChannel(domain))
+}
+
+// channels returns the channels the watcher subscribes to: the watcher
+// channel, the ones of the Domains it listens to and further Channels.
+func (w *Watcher) channels() []interface{} {
+	channels := []interface{}{w.channel()}
+	w.channelMu.Lock()
+	for _, domain := range w.options.domains {
+		channels = append(channels, w.DomainChannel(domain))
+	}
+	for _, channel := range w.options.channels {
+		channels = append(channels, channel)
+	}
+	w.channelMu.Unlock()
+	return channels
+}
+
+func (w *Watcher) patterns() []interface{} {
+	patterns := make([]interface{}, len(w.options.patterns))
+	for i, pattern := range w.options.patterns {
+		patterns[i] = pattern
+	}
+	return patterns
+}
+
+// publishChannel is the channel the publish in progress goes to. Callers
+// must hold pubMu.
+func (w *Watcher) publishChannel() string {
+	if w.pubChannel != "" {
+		return w.pubChannel
+	}
+	return w.channel()
+}
@@ -0,0 +1,63 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestUpdateForDomain(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), Domains("tenant1", "tenant2"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.UpdateForDomain("tenant1"); err != nil {
+		t.Fatalf("Failed watcher.UpdateForDomain(): %v", err)
+	}
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+	calls := pub.calls("PUBLISH")
+	if len(calls) != 2 || calls[0][0] != "/casbin/tenant1" || calls[1][0] != "/casbin" {
+		t.Fatalf("Domain updates should go to the domain channel only, got %v", calls)
+	}
+
+	if channels := fmt.Sprint(w.channels()); channels != "[/casbin /casbin/tenant1 /casbin/tenant2]" {
+		t.Fatalf("Watcher should subscribe to its domains, got %v", channels)
+	}
+}
+
+func TestChannelResolver(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		ChannelPrefix("prod:"), Domains("acme", "globex"),
+		ChannelResolver(func(tenant string) string { return "/tenants/" + tenant[:1] + "/" + tenant }))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.UpdateForDomain("acme"); err != nil {
+		t.Fatalf("Failed watcher.UpdateForDomain(): %v", err)
+	}
+	if calls := pub.calls("PUBLISH"); len(calls) != 1 || calls[0][0] != "prod:/tenants/a/acme" {
+		t.Fatalf("Update should be published on the resolved channel, got %v", calls)
+	}
+	if channels := fmt.Sprint(w.channels()); channels != "[prod:/casbin prod:/tenants/a/acme prod:/tenants/g/globex]" {
+		t.Fatalf("Watcher should subscribe to the resolved channels, got %v", channels)
+	}
+
+	if err := w.UnsubscribeDomain("globex"); err != nil {
+		t.Fatalf("Failed watcher.UnsubscribeDomain(): %v", err)
+	}
+	if channels := fmt.Sprint(w.channels()); channels != "[prod:/casbin prod:/tenants/a/acme]" {
+		t.Fatalf("Unsubscribed domains should be left, got %v", channels)
+	}
+}
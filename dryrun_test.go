@@ -0,0 +1,44 @@
+package rediswatcher
+
+import (
+	"testing"
+)
+
+func TestReceiveDryRun(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	var logged []string
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		ReceiveDryRun(func(channel, message string) {
+			logged = append(logged, channel+" "+message)
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	var failed error
+	w.SetErrorCallback(func(err error) {
+		failed = err
+	})
+	w.SetUpdateCallback(func(string) {
+		t.Error("Update callback should not run in a dry run")
+	})
+	w.SetBatchCallback(func([]string) error {
+		t.Error("Batch callback should not run in a dry run")
+		return nil
+	})
+
+	w.runJob(singleJob("node2"))
+	if len(logged) != 1 || logged[0] != "/casbin node2" || failed != nil {
+		t.Fatalf("Update should be logged, got %v and error %v", logged, failed)
+	}
+	if !w.Stats().LastReload.IsZero() {
+		t.Fatal("A dry run should not count as a reload")
+	}
+
+	w.runJob(singleJob(`{"type":`))
+	if len(logged) != 1 || failed == nil {
+		t.Fatalf("Undecodable message should fail, got %v and error %v", logged, failed)
+	}
+}
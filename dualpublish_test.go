@@ -0,0 +1,31 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDualPublish(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), ChannelPrefix("prod:"), DualPublish("/casbin-v1", func(msg string) string {
+			return "v1:" + msg
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+	if err := w.UpdateForDomain("acme"); err != nil {
+		t.Fatalf("Failed watcher.UpdateForDomain(): %v", err)
+	}
+	want := "[[prod:/casbin node1] [prod:/casbin-v1 v1:node1] [prod:/casbin/acme node1]]"
+	if calls := fmt.Sprint(pub.calls("PUBLISH")); calls != want {
+		t.Fatalf("Updates of the watcher channel should be published on both channels, got %v", calls)
+	}
+}
@@ -0,0 +1,102 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix starts the environment variables read by ConfigFromEnv.
+const envPrefix = "REDISWATCHER_"
+
+// ConfigFromEnv reads a Config from the environment, one variable per field
+// named after its JSON key: REDISWATCHER_ADDR, REDISWATCHER_PASSWORD,
+// REDISWATCHER_CHANNEL, REDISWATCHER_LOCAL_ID, REDISWATCHER_PRESET,
+// REDISWATCHER_TLS, REDISWATCHER_TLS_SERVER_NAME,
+// REDISWATCHER_TLS_INSECURE_SKIP_VERIFY,
+// REDISWATCHER_DIAL_TIMEOUT, REDISWATCHER_RESUBSCRIBE_THRESHOLD,
+// REDISWATCHER_DRAIN_TIMEOUT, REDISWATCHER_CALLBACK_TIMEOUT,
+// REDISWATCHER_DEBOUNCE_WINDOW, REDISWATCHER_IGNORE_SELF,
+// REDISWATCHER_SQUASH_MESSAGES, REDISWATCHER_PUBLISH_ONLY,
+// REDISWATCHER_SUBSCRIBE_ONLY, REDISWATCHER_ORDERED_DELIVERY and
+// REDISWATCHER_CALLBACK_WORKERS. Durations use time.ParseDuration syntax,
+// flags strconv.ParseBool. Unset variables keep the defaults.
+func ConfigFromEnv() (Config, error) {
+	return configFromEnv(os.LookupEnv)
+}
+
+func configFromEnv(lookup func(string) (string, bool)) (Config, error) {
+	var cfg Config
+	var problems []string
+	get := func(name string) (string, bool) {
+		v, ok := lookup(envPrefix + name)
+		return strings.TrimSpace(v), ok && strings.TrimSpace(v) != ""
+	}
+	str := func(name string, dst *string) {
+		if v, ok := get(name); ok {
+			*dst = v
+		}
+	}
+	flag := func(name string, dst *bool) {
+		if v, ok := get(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s%s=%q is not a boolean", envPrefix, name, v))
+			}
+			*dst = b
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		if v, ok := get(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s%s=%q is not a duration", envPrefix, name, v))
+			}
+			*dst = d
+		}
+	}
+
+	str("ADDR", &cfg.Addr)
+	str("PASSWORD", &cfg.Password)
+	str("CHANNEL", &cfg.Channel)
+	str("LOCAL_ID", &cfg.LocalID)
+	str("PRESET", &cfg.Preset)
+	flag("TLS", &cfg.TLS)
+	str("TLS_SERVER_NAME", &cfg.TLSServerName)
+	flag("TLS_INSECURE_SKIP_VERIFY", &cfg.TLSInsecureSkipVerify)
+	duration("DIAL_TIMEOUT", &cfg.DialTimeout)
+	duration("RESUBSCRIBE_THRESHOLD", &cfg.ResubscribeThreshold)
+	duration("DRAIN_TIMEOUT", &cfg.DrainTimeout)
+	duration("CALLBACK_TIMEOUT", &cfg.CallbackTimeout)
+	duration("DEBOUNCE_WINDOW", &cfg.DebounceWindow)
+	flag("IGNORE_SELF", &cfg.IgnoreSelf)
+	flag("SQUASH_MESSAGES", &cfg.SquashMessages)
+	flag("PUBLISH_ONLY", &cfg.PublishOnly)
+	flag("SUBSCRIBE_ONLY", &cfg.SubscribeOnly)
+	flag("ORDERED_DELIVERY", &cfg.OrderedDelivery)
+	if v, ok := get("CALLBACK_WORKERS"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%sCALLBACK_WORKERS=%q is not a number", envPrefix, v))
+		}
+		cfg.CallbackWorkers = n
+	}
+
+	if len(problems) > 0 {
+		return cfg, fmt.Errorf("rediswatcher: invalid environment: %s", strings.Join(problems, "; "))
+	}
+	return cfg, nil
+}
+
+// NewWatcherFromEnv creates a watcher configured by the environment, see
+// ConfigFromEnv, applying setters after it, e.g. for callbacks and hooks.
+func NewWatcherFromEnv(setters ...WatcherOption) (*Watcher, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Options = setters
+	return NewWatcherWithConfig(cfg)
+}
@@ -0,0 +1,56 @@
+package rediswatcher
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"REDISWATCHER_ADDR":             "redis:6379",
+		"REDISWATCHER_CHANNEL":          "/policies",
+		"REDISWATCHER_TLS":              "true",
+		"REDISWATCHER_DIAL_TIMEOUT":     "2s",
+		"REDISWATCHER_IGNORE_SELF":      "1",
+		"REDISWATCHER_CALLBACK_WORKERS": "3",
+		"REDISWATCHER_PASSWORD":         " ",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	cfg, err := configFromEnv(lookup)
+	if err != nil {
+		t.Fatalf("Failed configFromEnv(): %v", err)
+	}
+	if cfg.Addr != "redis:6379" || cfg.Channel != "/policies" || !cfg.TLS || cfg.DialTimeout != 2*time.Second ||
+		!cfg.IgnoreSelf || cfg.CallbackWorkers != 3 || cfg.Password != "" {
+		t.Fatalf("Unexpected config %+v", cfg)
+	}
+
+	env["REDISWATCHER_TLS"] = "maybe"
+	env["REDISWATCHER_DRAIN_TIMEOUT"] = "5"
+	_, err = configFromEnv(lookup)
+	if err == nil || !strings.Contains(err.Error(), `REDISWATCHER_TLS="maybe" is not a boolean`) ||
+		!strings.Contains(err.Error(), "REDISWATCHER_DRAIN_TIMEOUT") {
+		t.Fatalf("Malformed variables should be reported, got %v", err)
+	}
+}
+
+func TestNewWatcherFromEnv(t *testing.T) {
+	os.Setenv("REDISWATCHER_ADDR", "127.0.0.1:6379")
+	os.Setenv("REDISWATCHER_CHANNEL", "/env")
+	defer os.Unsetenv("REDISWATCHER_ADDR")
+	defer os.Unsetenv("REDISWATCHER_CHANNEL")
+
+	w, err := NewWatcherFromEnv(WithRedisPubConnection(NewTestConn()), WithRedisSubConnection(NewTestConn()), ManualStart(true))
+	if err != nil {
+		t.Fatalf("Failed NewWatcherFromEnv(): %v", err)
+	}
+	defer w.Close()
+	if ch := w.GetWatcherOptions().Channel; ch != "/env" {
+		t.Fatalf("The channel should come from the environment, got %q", ch)
+	}
+}
@@ -0,0 +1,40 @@
+package rediswatcher
+
+import "time"
+
+const defaultErrorHistorySize = 32
+
+// ErrorRecord is an error reported by the watcher and when it happened.
+type ErrorRecord struct {
+	Time time.Time
+	Err  error
+}
+
+// LastError returns the last error reported to the failure callback, or nil.
+func (w *Watcher) LastError() error {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	return w.state.lastError
+}
+
+// Errors returns the most recent reported errors, oldest first, up to
+// ErrorHistorySize of them.
+func (w *Watcher) Errors() []ErrorRecord {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	return append([]ErrorRecord(nil), w.state.errors...)
+}
+
+// recordError adds err to the history. Callers must hold stateMu.
+func (w *Watcher) recordError(err error, at time.Time) {
+	w.state.lastError, w.state.lastErrorAt = err, at
+
+	size := w.options.ErrorHistorySize
+	if size <= 0 {
+		return
+	}
+	if len(w.state.errors) >= size {
+		w.state.errors = append(w.state.errors[:0], w.state.errors[len(w.state.errors)-size+1:]...)
+	}
+	w.state.errors = append(w.state.errors, ErrorRecord{Time: at, Err: err})
+}
@@ -0,0 +1,26 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestErrorHistory(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	ErrorHistorySize(2)(&w.options)
+
+	if w.LastError() != nil || len(w.Errors()) != 0 {
+		t.Fatal("New watcher should have no errors")
+	}
+	for i := 1; i <= 3; i++ {
+		w.reportError(fmt.Errorf("error %d", i))
+	}
+
+	if err := w.LastError(); err == nil || err.Error() != "error 3" {
+		t.Fatalf("LastError should be 'error 3', got %v", err)
+	}
+	errs := w.Errors()
+	if len(errs) != 2 || errs[0].Err.Error() != "error 2" || errs[1].Err.Error() != "error 3" || errs[0].Time.IsZero() {
+		t.Fatalf("History should keep the last 2 errors, got %v", errs)
+	}
+}
@@ -0,0 +1,45 @@
+package rediswatcher
+
+import "errors"
+
+// Failure kinds of the watcher. Errors returned or reported by the watcher
+// wrap them, so errors.Is(err, ErrPublishFailed) tells a failed publish
+// apart, while errors.As with *Error gives access to the cause.
+var (
+	ErrClosed          = errors.New("rediswatcher: watcher closed")
+	ErrAuthFailed      = errors.New("rediswatcher: redis authentication failed")
+	ErrSubscribeClosed = errors.New("rediswatcher: subscription closed")
+	ErrPublishFailed   = errors.New("rediswatcher: publish failed")
+	ErrSubscribeOnly   = errors.New("rediswatcher: watcher is subscribe only and cannot publish")
+	ErrLockLost        = errors.New("rediswatcher: policy lock expired before it was released")
+	ErrNoSnapshot      = errors.New("rediswatcher: no policy snapshot available")
+	ErrNoPermission    = errors.New("rediswatcher: redis user may not use the channel")
+)
+
+// Error is a failure of the watcher: Kind is one of the Err* variables and
+// Err the underlying Redis or network error.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of e.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func wrapError(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
@@ -0,0 +1,35 @@
+//go:build go1.13
+// +build go1.13
+
+package rediswatcher
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	pub.Command("PUBLISH", "/casbin", "node1").ExpectError(fmt.Errorf("connection refused"))
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	err = w.Update()
+	if !errors.Is(err, ErrPublishFailed) {
+		t.Fatalf("Failed publish should be ErrPublishFailed, got %v", err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Err.Error() != "connection refused" {
+		t.Fatalf("Cause should be available through *Error, got %v", err)
+	}
+
+	w.Close()
+	if err := w.Start(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Starting a closed watcher should be ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// eventsHeartbeat is the interval of the comments keeping idle event
+// streams open through proxies.
+const eventsHeartbeat = 15 * time.Second
+
+// Event is a received update as streamed by EventsHandler.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Type    string    `json:"type"`             // Message type, "update" for plain updates.
+	Sender  string    `json:"sender,omitempty"` // LocalID of the publisher.
+}
+
+// EventsHandler returns an http.Handler streaming every received update as
+// a Server-Sent Event named "update" with an Event as data, so services
+// without a Redis client, or admin UIs, can follow policy changes:
+//
+//	http.Handle("/casbin/events", w.EventsHandler())
+//
+// Events reach a client when the callbacks run. A client too slow to keep
+// up with 64 pending events misses further ones until it caught up.
+func (w *Watcher) EventsHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		events := make(chan Event, 64)
+		handle := w.AddCallback(func(msg string) {
+			e := Event{Time: time.Now(), Message: msg, Type: MessageTypeUpdate, Sender: msg}
+			if m, ok := decodeMessage(msg); ok {
+				e.Type, e.Sender = m.Type, m.ID
+			}
+			select {
+			case events <- e:
+			default:
+			}
+		})
+		defer w.RemoveCallback(handle)
+
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-w.closed:
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(rw, ": heartbeat\n\n")
+			case e := <-events:
+				b, _ := json.Marshal(e)
+				fmt.Fprintf(rw, "event: update\ndata: %s\n\n", b)
+			}
+			flusher.Flush()
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package rediswatcher
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventsHandler(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	server := httptest.NewServer(w.EventsHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to connect to the event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Unexpected content type %s", ct)
+	}
+
+	for deadline := time.Now().Add(time.Second); !w.hasCallback() && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+	w.runCallback(encodeMessage(Message{Type: MessageTypeUpdate, ID: "node2", LSN: "42"}))
+
+	lines := bufio.NewReader(resp.Body)
+	event, _ := lines.ReadString('\n')
+	data, _ := lines.ReadString('\n')
+	if event != "event: update\n" || !strings.Contains(data, `"type":"update","sender":"node2"`) {
+		t.Fatalf("Update should be streamed as an event, got %q %q", event, data)
+	}
+}
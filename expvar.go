@@ -0,0 +1,66 @@
+package rediswatcher
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	expvarMu       sync.Mutex
+	expvarWatchers = make(map[string]*Watcher)
+)
+
+// publishExpvar exports the counters of w under name. expvar variables
+// cannot be removed, so the name is bound to the most recent watcher using
+// it and reads as null once that watcher is closed.
+func (w *Watcher) publishExpvar(name string) {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if _, ok := expvarWatchers[name]; !ok && expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() interface{} {
+			expvarMu.Lock()
+			w := expvarWatchers[name]
+			expvarMu.Unlock()
+			if w == nil {
+				return nil
+			}
+			return w.expvarValue()
+		}))
+	}
+	expvarWatchers[name] = w
+}
+
+func (w *Watcher) unpublishExpvar() {
+	if w.options.ExpvarName == "" {
+		return
+	}
+
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if expvarWatchers[w.options.ExpvarName] == w {
+		expvarWatchers[w.options.ExpvarName] = nil
+	}
+}
+
+func (w *Watcher) expvarValue() map[string]interface{} {
+	formatTime := func(addr *int64) string {
+		if t := loadTime(addr); !t.IsZero() {
+			return t.Format(time.RFC3339Nano)
+		}
+		return ""
+	}
+	return map[string]interface{}{
+		"published":   atomic.LoadInt64(&w.counters.published),
+		"received":    atomic.LoadInt64(&w.counters.received),
+		"errors":      atomic.LoadInt64(&w.counters.errors),
+		"reconnects":  w.reconnects(),
+		"queued":      len(w.jobs),
+		"running":     atomic.LoadInt32(&w.inflight),
+		"dropped":     atomic.LoadInt64(&w.counters.dropped),
+		"lastPublish": formatTime(&w.counters.lastPublish),
+		"lastReceive": formatTime(&w.counters.lastReceive),
+	}
+}
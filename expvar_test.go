@@ -0,0 +1,38 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestExpvar(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), Expvar("casbin_watcher_test"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+
+	var vars struct {
+		Published   int64
+		LastPublish string
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("casbin_watcher_test").String()), &vars); err != nil {
+		t.Fatalf("Invalid expvar value: %v", err)
+	}
+	if vars.Published != 1 || vars.LastPublish == "" {
+		t.Fatalf("Publish should be counted, got %+v", vars)
+	}
+
+	w.Close()
+	if v := expvar.Get("casbin_watcher_test").String(); v != "null" {
+		t.Fatalf("Closed watcher should no longer be exported, got %s", v)
+	}
+}
@@ -0,0 +1,32 @@
+package rediswatcher
+
+// UpdateChannels publishes one update on every channel, e.g. on the watcher
+// channel and the one of a tenant, in a single pipelined round trip where
+// possible. Channels are named as in Channels, the ChannelPrefix being
+// added. Each update is delivered, retried and queued as by Update; queued
+// ones are replayed on the watcher channel.
+func (w *Watcher) UpdateChannels(channels ...string) error {
+	if w.options.SubscribeOnly {
+		return ErrSubscribeOnly
+	}
+	if w.suppressUpdate() {
+		return nil
+	}
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+
+	prefixed := make([]string, len(channels))
+	msgs := make([]string, len(channels))
+	for i, channel := range channels {
+		prefixed[i] = w.prefixed(channel)
+		msgs[i] = w.options.LocalID
+	}
+
+	return w.publishLocked(func() error {
+		// a retry only repeats the updates not handled yet
+		sent, err := w.publishOrQueueAll(prefixed, msgs)
+		prefixed, msgs = prefixed[sent:], msgs[sent:]
+		return err
+	})
+}
@@ -0,0 +1,61 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestUpdateChannels(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(2)).Expect(int64(2))
+	sub := NewTestConn()
+	var receivers int64
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), RecordMetrics(func(m *WatcherMetrics) {
+			if m.Name == PubSubPublishMetric {
+				receivers += m.Receivers
+			}
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.UpdateChannels("/casbin", w.DomainChannel("acme")); err != nil {
+		t.Fatalf("Failed watcher.UpdateChannels(): %v", err)
+	}
+	if calls := fmt.Sprint(pub.calls("PUBLISH")); calls != "[[/casbin node1] [/casbin/acme node1]]" {
+		t.Fatalf("Update should be published on every channel, got %v", calls)
+	}
+	if receivers != 4 || w.Stats().Published != 2 {
+		t.Fatalf("Every publish should be counted, got %d receivers and %+v", receivers, w.Stats())
+	}
+}
+
+func TestUpdateChannelsQueued(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	queue := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		WithRedisQueueConnection(queue), PublishQueue("casbin:queue"), ChannelPrefix("app1:"), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	first := pub.Command("PUBLISH", "app1:/casbin", "node1").Expect(int64(1))
+	refused := fmt.Errorf("connection refused")
+	second := pub.Command("PUBLISH", "app1:/casbin/acme", "node1").ExpectError(refused).ExpectError(refused)
+	queue.Command("LLEN", "casbin:queue").Expect(int64(0)).Expect(int64(0))
+	push := queue.Command("RPUSH", "casbin:queue", "node1").Expect(int64(1))
+
+	if err := w.UpdateChannels("/casbin", "/casbin/acme"); err != nil {
+		t.Fatalf("UpdateChannels should succeed when the update is queued: %v", err)
+	}
+	if pub.Stats(first) != 1 || pub.Stats(second) != 2 {
+		t.Fatal("Updates should be published on the prefixed channels")
+	}
+	if queue.Stats(push) != 1 {
+		t.Fatal("Failed update was not stored in the queue")
+	}
+}
@@ -0,0 +1,91 @@
+package rediswatcher
+
+import (
+	"errors"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ErrInjectedFault is reported for the failures a FaultInjector forces.
+var ErrInjectedFault = errors.New("rediswatcher: injected fault")
+
+// Fault is what a FaultInjector does to a received message.
+type Fault int
+
+const (
+	FaultNone       Fault = iota // Deliver the message.
+	FaultDrop                    // Discard the message, as if lost.
+	FaultDelay                   // Deliver the message after FaultInjector.Delay.
+	FaultDuplicate               // Deliver the message twice.
+	FaultCorrupt                 // Deliver FaultInjector.Corrupt of the message.
+	FaultDisconnect              // Discard the message and end the subscription.
+)
+
+// FaultInjector forces failures into a watcher, so applications can test
+// their resilience logic, e.g. gap detection or staleness handlers,
+// deterministically. It is meant for tests only, see InjectFaults. Nil hooks
+// inject nothing.
+type FaultInjector struct {
+	Receive func(channel, msg string) Fault // Decides the fate of each received message.
+	Delay   time.Duration                   // Hold-up of FaultDelay.
+	Corrupt func(msg string) string         // Damage done by FaultCorrupt, truncation to half by default.
+	Publish func(msg string) error          // A non-nil error fails the publish of msg before it reaches Redis.
+	Dial    func(addr string) error         // A non-nil error fails the dial of addr.
+}
+
+// InjectFaults installs f on the watcher. Don't use it in production.
+func InjectFaults(f *FaultInjector) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.faults = f
+	}
+}
+
+// receiveFault applies the Receive hook to msg. It returns the messages to
+// deliver in its place and whether the subscription goes on.
+func (w *Watcher) receiveFault(msg redis.Message) ([]redis.Message, bool) {
+	f := w.options.faults
+	if f == nil || f.Receive == nil {
+		return []redis.Message{msg}, true
+	}
+
+	switch f.Receive(msg.Channel, string(msg.Data)) {
+	case FaultDrop:
+		return nil, true
+	case FaultDelay:
+		select {
+		case <-time.After(f.Delay):
+		case <-w.closed:
+		}
+	case FaultDuplicate:
+		return []redis.Message{msg, msg}, true
+	case FaultCorrupt:
+		data := string(msg.Data)
+		if f.Corrupt != nil {
+			data = f.Corrupt(data)
+		} else {
+			data = data[:len(data)/2]
+		}
+		msg.Data = []byte(data)
+	case FaultDisconnect:
+		w.reportError(wrapError(ErrSubscribeClosed, ErrInjectedFault))
+		return nil, false
+	}
+	return []redis.Message{msg}, true
+}
+
+// publishFault returns the error the Publish hook forces for msg, if any.
+func (w *Watcher) publishFault(msg string) error {
+	if f := w.options.faults; f != nil && f.Publish != nil {
+		return f.Publish(msg)
+	}
+	return nil
+}
+
+// dialFault returns the error the Dial hook forces for addr, if any.
+func (w *Watcher) dialFault(addr string) error {
+	if f := w.options.faults; f != nil && f.Dial != nil {
+		return f.Dial(addr)
+	}
+	return nil
+}
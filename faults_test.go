@@ -0,0 +1,79 @@
+package rediswatcher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInjectFaults(t *testing.T) {
+	b := NewMemoryBroker()
+	faults := &FaultInjector{
+		Receive: func(channel, msg string) Fault {
+			switch {
+			case strings.HasPrefix(msg, "drop"):
+				return FaultDrop
+			case strings.HasPrefix(msg, "dup"):
+				return FaultDuplicate
+			case strings.HasPrefix(msg, "corrupt"):
+				return FaultCorrupt
+			case strings.HasPrefix(msg, "disconnect"):
+				return FaultDisconnect
+			}
+			return FaultNone
+		},
+		Publish: func(msg string) error {
+			if msg == "fail" {
+				return errors.New("network down")
+			}
+			return nil
+		},
+	}
+	w, err := newWatcher("", WithMemoryBroker(b), InjectFaults(faults), ResubscribeThreshold(10*time.Millisecond), CallbackWorkers(0))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Close()
+	ch := make(chan string, 8)
+	w.SetUpdateCallback(func(msg string) { ch <- msg })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := w.WaitUntilSubscribed(ctx); err != nil {
+		t.Fatalf("The watcher should subscribe, got %v", err)
+	}
+
+	for _, msg := range []string{"drop1", "dup1", "corrupt1", "end"} {
+		b.Publish("/casbin", []byte(msg))
+	}
+	var got []string
+	for msg := range ch {
+		if got = append(got, msg); msg == "end" {
+			break
+		}
+	}
+	if res := strings.Join(got, " "); res != "dup1 dup1 corr end" {
+		t.Fatalf("Unexpected deliveries %q", res)
+	}
+
+	var reported int32
+	w.SetErrorCallback(func(err error) {
+		if errors.Is(err, ErrInjectedFault) {
+			atomic.AddInt32(&reported, 1)
+		}
+	})
+	b.Publish("/casbin", []byte("disconnect"))
+	deadline := time.Now().Add(time.Second)
+	for w.Stats().Reconnects == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if w.Stats().Reconnects == 0 || atomic.LoadInt32(&reported) != 1 {
+		t.Fatalf("A forced disconnect should be reported and reconnected, got %+v", w.Stats())
+	}
+
+	if err := w.publish("fail"); !errors.Is(err, ErrPublishFailed) {
+		t.Fatalf("The publish should fail, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package rediswatcher
+
+import (
+	"github.com/garyburd/redigo/redis"
+)
+
+// startFederation starts a subscription loop for every FederatedServers
+// address, feeding the same processor as the regular subscription.
+func (w *Watcher) startFederation() {
+	for _, addr := range w.options.FederatedServers {
+		s := &subShard{addr: addr, wake: make(chan struct{}, 1)}
+		w.federated = append(w.federated, s)
+	}
+	for _, s := range w.federated {
+		w.runShard(s)
+	}
+}
+
+// federatedConns returns the open subscriptions on federated servers; a
+// disconnected one picks up the current channels when it reconnects.
+func (w *Watcher) federatedConns() []redis.Conn {
+	var conns []redis.Conn
+	for _, s := range w.federated {
+		if c := s.getConn(); c != nil {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
@@ -0,0 +1,38 @@
+package rediswatcher
+
+import (
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestFederatedServers(t *testing.T) {
+	conn := NewTestConn()
+	var dialed string
+	w := &Watcher{closed: make(chan struct{}), messagesIn: make(chan redis.Message, 1)}
+	w.options.Channel = "/casbin"
+	SubscribePatterns("/casbin/*")(&w.options)
+	FederatedServers("10.0.1.1:6379")(&w.options)
+	w.options.shardDialer = func(addr string) (redis.Conn, error) {
+		dialed = addr
+		return conn, nil
+	}
+
+	w.federated = []*subShard{{addr: "10.0.1.1:6379", wake: make(chan struct{}, 1)}}
+	conn.Command("SUBSCRIBE", "/casbin").Expect([]interface{}{[]byte("subscribe"), []byte("/casbin"), int64(1)})
+	conn.Command("PSUBSCRIBE", "/casbin/*").Expect([]interface{}{[]byte("pmessage"), []byte("/casbin/*"), []byte("/casbin/acme"), []byte("node2")})
+	if err := w.subscribeShard(w.federated[0]); err == nil {
+		t.Fatal("The mock federated subscription should end with an error")
+	}
+	if dialed != "10.0.1.1:6379" {
+		t.Fatalf("The federated server should be dialed, got %q", dialed)
+	}
+	select {
+	case msg := <-w.messagesIn:
+		if msg.Channel != "/casbin/acme" || string(msg.Data) != "node2" {
+			t.Fatalf("Unexpected message %+v", msg)
+		}
+	default:
+		t.Fatal("Messages of the federated server should be received")
+	}
+}
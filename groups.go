@@ -0,0 +1,34 @@
+package rediswatcher
+
+// UpdateForGroups publishes an update only watchers in one of groups act on,
+// see InstanceGroups, e.g. to roll a policy change out to canary instances
+// before broadcasting it with Update. Watchers of older versions treat it as
+// an ordinary update.
+func (w *Watcher) UpdateForGroups(groups ...string) error {
+	if w.suppressUpdate() {
+		return nil
+	}
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+	return w.publishMessage(Message{
+		Type:   MessageTypeUpdate,
+		ID:     w.options.LocalID,
+		Groups: groups,
+	})
+}
+
+// inGroups reports whether an update for groups is meant for this watcher.
+func (w *Watcher) inGroups(groups []string) bool {
+	if len(groups) == 0 {
+		return true
+	}
+	for _, group := range groups {
+		for _, own := range w.options.InstanceGroups {
+			if group == own {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package rediswatcher
+
+import (
+	"testing"
+)
+
+func TestUpdateForGroups(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), InstanceGroups("canary"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	publish := pub.Command("PUBLISH", "/casbin", `{"type":"update","id":"node1","groups":["canary"]}`).Expect(int64(1))
+	if err := w.UpdateForGroups("canary"); err != nil {
+		t.Fatalf("Failed watcher.UpdateForGroups(): %v", err)
+	}
+	if pub.Stats(publish) != 1 {
+		t.Fatal("Targeted update was not published")
+	}
+
+	for _, c := range []struct {
+		groups  []string
+		ignored bool
+	}{
+		{nil, false},
+		{[]string{"canary"}, false},
+		{[]string{"eu", "canary"}, false},
+		{[]string{"eu"}, true},
+	} {
+		m := Message{Type: MessageTypeUpdate, ID: "node2", Groups: c.groups}
+		if ignored := w.handleControlMessage(m); ignored != c.ignored {
+			t.Errorf("Update for %v should be ignored: %v, got %v", c.groups, c.ignored, ignored)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package rediswatcher
+
+import "time"
+
+// Health describes whether a watcher is working, e.g. for readiness checks.
+type Health struct {
+	Connected        bool          // Subscribed, for subscribing watchers, and the last publish succeeded.
+	Subscribed       bool          // Currently subscribed to the channel.
+	Stale            bool          // Disconnected for longer than the staleness threshold.
+	SinceLastMessage time.Duration // Time since the last received message, -1 if none arrived.
+	LastError        error         // Last error reported to the failure callback.
+	LastErrorAt      time.Time
+}
+
+// Health returns the current health of the watcher.
+func (w *Watcher) Health() Health {
+	w.stateMu.Lock()
+	state := w.state
+	w.stateMu.Unlock()
+
+	h := Health{
+		Connected:        !state.publishFailing && (w.messagesIn == nil || state.subscribed),
+		Subscribed:       state.subscribed,
+		Stale:            state.stale,
+		SinceLastMessage: -1,
+		LastError:        state.lastError,
+		LastErrorAt:      state.lastErrorAt,
+	}
+	if last := loadTime(&w.counters.lastReceive); !last.IsZero() {
+		h.SinceLastMessage = time.Since(last)
+	}
+	return h
+}
+
+func (w *Watcher) setPublishFailing(failing bool) {
+	w.stateMu.Lock()
+	w.state.publishFailing = failing
+	w.stateMu.Unlock()
+}
@@ -0,0 +1,57 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestHealth(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if h := w.Health(); !h.Connected || h.SinceLastMessage != -1 || h.LastError != nil {
+		t.Fatalf("New publish watcher should be healthy, got %+v", h)
+	}
+
+	pub.Command("PUBLISH", "/casbin", "node1").ExpectError(fmt.Errorf("connection refused"))
+	w.Update()
+	w.reportError(fmt.Errorf("connection refused"))
+	if h := w.Health(); h.Connected || h.LastError == nil || h.LastErrorAt.IsZero() {
+		t.Fatalf("Failed publish should be visible, got %+v", h)
+	}
+
+	pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+	w.Update()
+	w.countReceived()
+	if h := w.Health(); !h.Connected || h.SinceLastMessage < 0 {
+		t.Fatalf("Watcher should have recovered, got %+v", h)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{}), messagesIn: make(chan redis.Message)}
+	handler := w.HealthHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Unsubscribed watcher should be unavailable, got %d", rec.Code)
+	}
+
+	w.setSubscribed(true)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"healthy":true`) {
+		t.Fatalf("Subscribed watcher should be healthy, got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,43 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// HealthHandler returns an http.Handler for readiness and liveness probes.
+// It responds 200 while the watcher is connected and not stale, 503
+// otherwise, with the Health as JSON.
+func (w *Watcher) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		h := w.Health()
+		body := struct {
+			Healthy          bool      `json:"healthy"`
+			Connected        bool      `json:"connected"`
+			Subscribed       bool      `json:"subscribed"`
+			Stale            bool      `json:"stale"`
+			SinceLastMessage string    `json:"sinceLastMessage,omitempty"`
+			LastError        string    `json:"lastError,omitempty"`
+			LastErrorAt      time.Time `json:"lastErrorAt,omitempty"`
+		}{
+			Healthy:     h.Connected && !h.Stale,
+			Connected:   h.Connected,
+			Subscribed:  h.Subscribed,
+			Stale:       h.Stale,
+			LastErrorAt: h.LastErrorAt,
+		}
+		if h.SinceLastMessage >= 0 {
+			body.SinceLastMessage = h.SinceLastMessage.String()
+		}
+		if h.LastError != nil {
+			body.LastError = h.LastError.Error()
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		if !body.Healthy {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(rw).Encode(body)
+	})
+}
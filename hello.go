@@ -0,0 +1,129 @@
+package rediswatcher
+
+import "sort"
+
+// capabilitySnapshot tells that a watcher loads snapshots referenced by
+// updates, see UpdateWithSnapshot.
+const capabilitySnapshot = "snapshot"
+
+// capabilities lists the structured message types and features this watcher
+// understands.
+var capabilities = []string{
+	MessageTypeUpdate,
+	MessageTypePrepare,
+	MessageTypeCommit,
+	MessageTypeProbe,
+	MessageTypeHello,
+	MessageTypeStatus,
+	MessageTypeAck,
+	MessageTypePolicyRequest,
+	MessageTypePolicy,
+	MessageTypeBatch,
+	MessageTypeBye,
+	MessageTypePayload,
+	capabilitySnapshot,
+}
+
+// announce publishes the hello message, when enabled, after the watcher has
+// joined the channel.
+func (w *Watcher) announce() {
+	w.sayHello("")
+}
+
+// sayHello publishes the hello message. A non-empty answering is the ID of
+// the joining watcher it answers, which does not answer in turn.
+func (w *Watcher) sayHello(answering string) {
+	if w.options.helloVersion == "" {
+		return
+	}
+
+	if err := w.publishMessage(Message{
+		Type:         MessageTypeHello,
+		ID:           w.options.LocalID,
+		Version:      w.options.helloVersion,
+		Nonce:        answering,
+		Capabilities: capabilities,
+	}); err != nil {
+		w.reportError(err)
+	}
+}
+
+// helloReceived records the hello of a peer and answers one that joined, so
+// it learns about the watchers already running.
+func (w *Watcher) helloReceived(m Message) {
+	w.peerMu.Lock()
+	if w.peers == nil {
+		w.peers = make(map[string]Message)
+	}
+	w.peers[m.ID] = m
+	w.peerMu.Unlock()
+
+	if m.Nonce == "" {
+		w.sayHello(m.ID)
+	}
+	if w.options.helloCallback != nil {
+		w.options.helloCallback(m)
+	}
+}
+
+// sayGoodbye tells the peers that the watcher leaves when it closes.
+func (w *Watcher) sayGoodbye() {
+	if w.options.helloVersion == "" || w.options.SubscribeOnly {
+		return
+	}
+	if err := w.publishMessage(Message{Type: MessageTypeBye, ID: w.options.LocalID}); err != nil {
+		w.logEvent(levelWarn, "saying goodbye failed", "error", err)
+	}
+}
+
+func (w *Watcher) forgetPeer(id string) {
+	w.peerMu.Lock()
+	delete(w.peers, id)
+	w.peerMu.Unlock()
+}
+
+// Peers returns the last hello of every other watcher seen since this one
+// started and still running, ordered by ID. It needs Hello.
+func (w *Watcher) Peers() []Message {
+	w.peerMu.Lock()
+	defer w.peerMu.Unlock()
+	peers := make([]Message, 0, len(w.peers))
+	for _, m := range w.peers {
+		peers = append(peers, m)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+	return peers
+}
+
+// PeersSupport reports whether every known peer advertised capability, a
+// message type or feature.
+func (w *Watcher) PeersSupport(capability string) bool {
+	w.peerMu.Lock()
+	defer w.peerMu.Unlock()
+	for _, m := range w.peers {
+		supported := false
+		for _, c := range m.Capabilities {
+			if c == capability {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return false
+		}
+	}
+	return true
+}
+
+// downgrade reports whether m has to be sent as a plain update because a
+// peer does not understand it. Updates meant for InstanceGroups are never
+// downgraded, older peers would act on them.
+func (w *Watcher) downgrade(m Message) bool {
+	switch {
+	case m.Type == MessageTypeBatch:
+		return !w.PeersSupport(MessageTypeBatch)
+	case m.Type == MessageTypeUpdate && m.Snapshot != "":
+		return !w.PeersSupport(capabilitySnapshot)
+	}
+	return false
+}
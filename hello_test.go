@@ -0,0 +1,80 @@
+package rediswatcher
+
+import (
+	"testing"
+)
+
+func TestHello(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+
+	var peer Message
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), Hello("1.2.0", func(m Message) {
+			peer = m
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	w.announce()
+	published := pub.calls("PUBLISH")
+	if len(published) != 1 {
+		t.Fatal("Hello message was not published")
+	}
+	m, ok := decodeMessage(published[0][1].(string))
+	if !ok || m.Type != MessageTypeHello || m.ID != "node1" || m.Version != "1.2.0" || len(m.Capabilities) == 0 {
+		t.Fatalf("Unexpected hello message: %+v", m)
+	}
+
+	if !w.handleControlMessage(Message{Type: MessageTypeHello, ID: "node2", Version: "1.1.0"}) {
+		t.Fatal("Hello messages must not reach the update callback")
+	}
+	if peer.ID != "node2" {
+		t.Fatalf("Hello hook should receive 'node2', received '%s' instead", peer.ID)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), Hello("1.3.0", nil))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	w.handleControlMessage(Message{Type: MessageTypeHello, ID: "node2", Version: "1.1.0", Capabilities: []string{MessageTypeUpdate}})
+	published := pub.calls("PUBLISH")
+	if m, _ := decodeMessage(published[0][1].(string)); len(published) != 1 || m.Type != MessageTypeHello || m.Nonce != "node2" {
+		t.Fatalf("Joining watcher should be answered, got %v", published)
+	}
+	w.handleControlMessage(Message{Type: MessageTypeHello, ID: "node3", Nonce: "node1", Capabilities: capabilities})
+	if len(pub.calls("PUBLISH")) != 1 {
+		t.Fatal("Answers should not be answered")
+	}
+	if peers := w.Peers(); len(peers) != 2 || peers[0].ID != "node2" || peers[1].ID != "node3" {
+		t.Fatalf("Peers should list the watchers that said hello, got %+v", peers)
+	}
+
+	if err := w.NewBatch().AddPolicies("p", "p", []string{"alice", "data1", "read"}).Publish(); err != nil {
+		t.Fatalf("Failed batch.Publish(): %v", err)
+	}
+	if msg := pub.calls("PUBLISH")[1][1]; msg != "node1" {
+		t.Fatalf("Batch should be downgraded for the older peer, got %v", msg)
+	}
+
+	w.handleControlMessage(Message{Type: MessageTypeBye, ID: "node2"})
+	w.NewBatch().AddPolicies("p", "p", []string{"alice", "data1", "read"}).Publish()
+	if m, ok := decodeMessage(pub.calls("PUBLISH")[2][1].(string)); !ok || m.Type != MessageTypeBatch {
+		t.Fatalf("Batch should be sent once the older peer left, got %v", pub.calls("PUBLISH")[2][1])
+	}
+
+	w.Close()
+	if m, _ := decodeMessage(pub.calls("PUBLISH")[3][1].(string)); m.Type != MessageTypeBye || m.ID != "node1" {
+		t.Fatalf("Close should say goodbye, got %v", pub.calls("PUBLISH")[3])
+	}
+}
@@ -0,0 +1,35 @@
+package rediswatcher
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// waitJitter sleeps for a random time up to ReloadJitter, so instances
+// receiving the same message do not reload at the same moment. It returns
+// false when the watcher was closed meanwhile.
+func (w *Watcher) waitJitter() bool {
+	if w.options.ReloadJitter <= 0 {
+		return true
+	}
+	if w.isClosed() {
+		return false
+	}
+
+	jitterMu.Lock()
+	d := time.Duration(jitterRand.Int63n(int64(w.options.ReloadJitter)))
+	jitterMu.Unlock()
+
+	select {
+	case <-w.closed:
+		return false
+	case <-w.clock().After(d):
+		return true
+	}
+}
@@ -0,0 +1,26 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReloadJitter(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	ReloadJitter(20 * time.Millisecond)(&w.options)
+
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if !w.waitJitter() {
+			t.Fatal("Jitter should not abort an open watcher")
+		}
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+			t.Fatalf("Jitter should stay below its bound, waited %v", elapsed)
+		}
+	}
+
+	close(w.closed)
+	if w.waitJitter() {
+		t.Fatal("Jitter should abort once the watcher is closed")
+	}
+}
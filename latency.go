@@ -0,0 +1,45 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// startLatencyProbe measures the publish to receive round trip every
+// latencyProbeInterval.
+func (w *Watcher) startLatencyProbe() {
+	if w.options.latencyProbeInterval <= 0 {
+		return
+	}
+
+	w.spawn(func() {
+		ticker := w.clock().NewTicker(w.options.latencyProbeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+				w.probeLatency()
+			}
+		}
+	})
+}
+
+// probeLatency publishes one probe, waiting at most one interval for it,
+// and records the round trip as ProbeLatencyMetric.
+func (w *Watcher) probeLatency() {
+	startTime := time.Now()
+	err := w.Verify(w.options.latencyProbeInterval)
+	if err == ErrClosed {
+		return
+	}
+	if err != nil {
+		w.reportError(err)
+	} else {
+		atomic.StoreInt64(&w.counters.probeLatency, int64(time.Since(startTime)))
+	}
+	if w.options.RecordMetrics != nil {
+		w.options.RecordMetrics(w.createMetrics(ProbeLatencyMetric, startTime, err))
+	}
+}
@@ -0,0 +1,46 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestLatencyProbe(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+
+	metrics := make(chan *WatcherMetrics, 10)
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LatencyProbe(time.Second), RecordMetrics(func(m *WatcherMetrics) {
+			if m.Name == ProbeLatencyMetric {
+				metrics <- m
+			}
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	// pretend to subscribe and loop the published probe back
+	w.messagesIn = make(chan redis.Message)
+	go func() {
+		for len(pub.calls("PUBLISH")) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(5 * time.Millisecond)
+		m, _ := decodeMessage(pub.calls("PUBLISH")[0][1].(string))
+		w.handleControlMessage(m)
+	}()
+	w.probeLatency()
+
+	m := <-metrics
+	if m.Error != nil || m.LatencyMs < 5 {
+		t.Fatalf("Probe should take at least 5ms without error, got %v ms, %v", m.LatencyMs, m.Error)
+	}
+	if latency := w.Stats().ProbeLatency; latency < 5*time.Millisecond {
+		t.Fatalf("Stats should report the probe latency, got %v", latency)
+	}
+}
@@ -0,0 +1,112 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// campaignScript takes or renews the leader key for ARGV[1].
+var campaignScript = redis.NewScript(1, `
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0`)
+
+// releaseScript deletes KEYS[1] if ARGV[1] holds it.
+var releaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// IsLeader reports whether the watcher holds the LeaderElection key. Without
+// LeaderElection every watcher is leader.
+func (w *Watcher) IsLeader() bool {
+	return w.options.leaderKey == "" || atomic.LoadInt32(&w.leader) == 1
+}
+
+func (w *Watcher) startLeaderElection() {
+	if w.options.leaderKey == "" {
+		return
+	}
+
+	w.spawn(func() {
+		ticker := w.clock().NewTicker(w.options.leaderTTL / 3)
+		defer ticker.Stop()
+		for {
+			if err := w.campaign(); err != nil {
+				w.reportError(err)
+			}
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+			}
+		}
+	})
+}
+
+// campaign takes or renews the leader key. On failure the watcher steps
+// down, as its lease may run out before the next attempt.
+func (w *Watcher) campaign() error {
+	won, err := w.holdKey(w.options.leaderKey, w.options.leaderTTL)
+	w.setLeader(won)
+	return err
+}
+
+// holdKey takes or renews key for the LocalID for ttl and reports whether
+// the watcher holds it.
+func (w *Watcher) holdKey(key string, ttl time.Duration) (bool, error) {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return false, err
+	}
+	return redis.Bool(campaignScript.Do(c, key, w.options.LocalID, int64(ttl/time.Millisecond)))
+}
+
+func (w *Watcher) setLeader(leader bool) {
+	var v int32
+	if leader {
+		v = 1
+	}
+	if atomic.SwapInt32(&w.leader, v) == v {
+		return
+	}
+	w.logEvent(levelInfo, "leadership changed", "leader", leader)
+	if w.options.onLeaderChange != nil {
+		w.options.onLeaderChange(leader)
+	}
+}
+
+// resign hands the leader key back when the watcher closes, so another one
+// takes over without waiting for the lease to expire.
+func (w *Watcher) resign() {
+	if w.options.leaderKey == "" || atomic.LoadInt32(&w.leader) == 0 {
+		return
+	}
+
+	if err := w.releaseKey(w.options.leaderKey); err != nil {
+		w.logEvent(levelWarn, "resigning leadership failed", "error", err)
+	}
+	w.setLeader(false)
+}
+
+// releaseKey deletes key if the LocalID holds it.
+func (w *Watcher) releaseKey(key string) error {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return err
+	}
+	_, err = releaseScript.Do(c, key, w.options.LocalID)
+	return err
+}
@@ -0,0 +1,50 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaderElection(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	var changes []bool
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), ManualStart(true), LeaderElection("casbin:leader", 3*time.Second, func(leader bool) {
+			changes = append(changes, leader)
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	if err := w.Update(); err != nil || w.IsLeader() || len(pub.calls("PUBLISH")) != 0 {
+		t.Fatalf("Followers should not publish, got %v and %v", err, pub.calls("PUBLISH"))
+	}
+
+	pub.GenericCommand("EVALSHA").Expect(int64(1))
+	if err := w.campaign(); err != nil || !w.IsLeader() {
+		t.Fatalf("Watcher should win the election, got %v", err)
+	}
+	if calls := pub.calls("EVALSHA"); calls[0][2] != "casbin:leader" || calls[0][3] != "node1" || calls[0][4] != int64(3000) {
+		t.Fatalf("Campaign should take the key for the LocalID, got %v", calls)
+	}
+	if err := w.Update(); err != nil || len(pub.calls("PUBLISH")) != 1 {
+		t.Fatalf("The leader should publish, got %v and %v", err, pub.calls("PUBLISH"))
+	}
+
+	pub.GenericCommand("EVALSHA").Expect(int64(0))
+	if err := w.campaign(); err != nil || w.IsLeader() {
+		t.Fatalf("Watcher should lose the leadership, got %v", err)
+	}
+
+	pub.GenericCommand("EVALSHA").Expect(int64(1))
+	w.campaign()
+	w.Close()
+	if n := len(pub.calls("EVALSHA")); n != 4 || w.IsLeader() {
+		t.Fatalf("Close should hand the leadership back, got %d scripts", n)
+	}
+	if len(changes) != 4 || !changes[0] || changes[1] || !changes[2] {
+		t.Fatalf("Leadership changes should be reported, got %v", changes)
+	}
+}
@@ -0,0 +1,147 @@
+package rediswatcher
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Start starts the subscription and background goroutines of a watcher
+// created with ManualStart. It does nothing when already started.
+func (w *Watcher) Start() error {
+	if w.isClosed() {
+		return ErrClosed
+	}
+	w.start()
+	return nil
+}
+
+// Run starts the watcher if needed and blocks until ctx is done, closing the
+// watcher and returning ctx.Err(), or until the watcher is closed, returning
+// the close error. It fits errgroup based supervisors.
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.Start(); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		w.shutdown()
+		return ctx.Err()
+	case <-w.closed:
+		return w.shutdown()
+	}
+}
+
+func (w *Watcher) start() {
+	w.startOnce.Do(func() {
+		if w.options.Synchronous {
+			return
+		}
+		if w.options.ExpvarName != "" {
+			w.publishExpvar(w.options.ExpvarName)
+		}
+		if w.messagesIn != nil {
+			w.startCallbackWorkers()
+			w.messageInProcessor()
+			w.startStalenessMonitor()
+			w.startShards()
+			w.startFederation()
+			w.startSubscription()
+			w.startLatencyProbe()
+			w.startPeriodicReload()
+			w.startWebhooks()
+			for _, mw := range w.options.maintenance {
+				w.ScheduleMaintenance(mw.Start, mw.End)
+			}
+		}
+		w.startOutboxRelay()
+		w.startGaugeReports()
+		w.startStatsPush()
+		w.startPresence()
+		w.startLeaderElection()
+		w.startLoaderElection()
+		w.startScheduler()
+		w.startChannelCheck()
+		w.startSignalHandler()
+	})
+}
+
+// startSubscription keeps the watcher subscribed, reconnecting after
+// failures until it is closed.
+func (w *Watcher) startSubscription() {
+	w.subDone = make(chan struct{})
+	w.spawn(func() {
+		defer close(w.subDone)
+		attempt := 0
+		for {
+			select {
+			case <-w.closed:
+				return
+			default:
+				if w.isUnsubscribed() {
+					w.waitBeforeResubscribe(attempt)
+					continue
+				}
+				subscriptions := atomic.LoadInt64(&w.counters.subscriptions)
+				err := w.subscribeOnce()
+				if w.isClosed() {
+					return
+				}
+				if err != nil {
+					// Make callback on error
+					w.reportError(err)
+				}
+				if atomic.LoadInt64(&w.counters.subscriptions) != subscriptions {
+					// the subscription was up, back off from the start
+					attempt = 0
+					resetRetry(w.options.ReconnectStrategy)
+				}
+				w.waitBeforeResubscribe(attempt)
+				attempt++
+			}
+		}
+	})
+}
+
+// subscribeOnce connects and stays subscribed until the subscription ends.
+// A panic is recovered and returned, so the loop reconnects as after any
+// other failure.
+func (w *Watcher) subscribeOnce() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.setSubscribed(false)
+			err = w.crashed("subscription", r)
+		}
+	}()
+
+	err = w.connect(w.addr)
+	if err == nil {
+		if w.options.onConnect != nil {
+			w.options.onConnect()
+		}
+		w.flushPublishQueue()
+		err = w.subscribe()
+		if w.setSubscribed(false) && w.options.onDisconnect != nil {
+			w.options.onDisconnect(err)
+		}
+		w.logEvent(levelInfo, "subscription ended", "error", err)
+	} else {
+		w.logEvent(levelWarn, "connecting to redis failed", "error", err)
+	}
+	return err
+}
+
+// waitBeforeResubscribe pauses the subscription loop before its attempt-th
+// reconnect, or until Resubscribe when unsubscribed on request.
+func (w *Watcher) waitBeforeResubscribe(attempt int) {
+	var retry <-chan time.Time
+	if !w.isUnsubscribed() {
+		retry = w.clock().After(w.reconnectDelay(attempt))
+	}
+	select {
+	case <-w.closed:
+	case <-w.resubscribe:
+	case <-retry:
+	}
+}
@@ -0,0 +1,210 @@
+package rediswatcher
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestManualStart(t *testing.T) {
+	c := NewTestConn()
+	c.ReceiveWait = true
+	c.CloseMock = func() error {
+		select {
+		case c.ReceiveNow <- true:
+		default:
+		}
+		return nil
+	}
+
+	w, err := newWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c), ManualStart(true))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	if w.subDone != nil {
+		t.Fatal("Subscription started before Start")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- w.Run(ctx)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Fatalf("Run should return the context error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancelling the context")
+	}
+	if w.subDone == nil {
+		t.Fatal("Run did not start the subscription")
+	}
+	if err := w.Start(); err == nil {
+		t.Fatal("Starting a closed watcher should fail")
+	}
+}
+
+func TestLazyConnect(t *testing.T) {
+	// nothing listens on this address, constructing must still work
+	w, err := newPublishWatcher("127.0.0.1:1", LazyConnect(true))
+	if err != nil {
+		t.Fatalf("Lazy watcher should not dial in the constructor: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.Update(); err == nil {
+		t.Fatal("Update should dial and fail without Redis")
+	}
+}
+
+func TestCloseDrainsCallbacks(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	connClosed := false
+	pub.CloseMock = func() error {
+		connClosed = true
+		return nil
+	}
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), DrainTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	started := make(chan struct{})
+	finished := false
+	w.SetUpdateCallback(func(string) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		if connClosed {
+			t.Error("Connection closed while the callback was running")
+		}
+		finished = true
+	})
+	go w.runCallback("node2")
+	<-started
+
+	w.Close()
+	if !finished {
+		t.Fatal("Close returned before the running callback finished")
+	}
+}
+
+// closeCheckConn fails the test when used after Close.
+type closeCheckConn struct {
+	*testConn
+	t      *testing.T
+	closed int32
+}
+
+func (c *closeCheckConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		c.t.Errorf("%s sent on a closed connection", commandName)
+	}
+	return c.testConn.Do(commandName, args...)
+}
+
+func (c *closeCheckConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func TestCloseWaitsForPublishers(t *testing.T) {
+	pub := &closeCheckConn{testConn: NewTestConn(), t: t}
+	pub.GenericCommand("EVALSHA").Expect([]interface{}{})
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(NewTestConn()),
+		Scheduler("casbin:scheduled", time.Millisecond), SubscriptionFailureCallback(func(error) {}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	w.Close()
+	time.Sleep(5 * time.Millisecond)
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	expectSubscribe := func() {
+		sub.Clear()
+		sub.Command("SUBSCRIBE", "/casbin").Expect([]interface{}{[]byte("subscribe"), []byte("/casbin"), []byte("1")})
+	}
+
+	var events []string
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		OnConnect(func() { events = append(events, "connect") }),
+		OnSubscribed(func() { events = append(events, "subscribed") }),
+		OnResubscribed(func() { events = append(events, "resubscribed") }),
+		OnDisconnect(func(err error) { events = append(events, "disconnect") }))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	w.messagesIn = make(chan redis.Message)
+
+	// the mock fails every Receive after the subscription confirmation
+	expectSubscribe()
+	w.subscribeOnce()
+	expectSubscribe()
+	w.subscribeOnce()
+	if fmt.Sprint(events) != "[connect subscribed disconnect connect resubscribed disconnect]" {
+		t.Fatalf("Unexpected lifecycle events %v", events)
+	}
+}
+
+func TestPublishOnly(t *testing.T) {
+	pub := NewTestConn()
+	pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), PublishOnly(true))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+	if w.subConn != nil || w.subDone != nil || w.messagesIn != nil {
+		t.Fatal("Publish only watchers must not subscribe")
+	}
+	select {
+	case <-w.Ready():
+	default:
+		t.Fatal("Publish only watchers should be ready right away")
+	}
+}
+
+func TestSubscribeOnly(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		SubscribeOnly(true))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	for name, err := range map[string]error{
+		"Update":          w.Update(),
+		"UpdateForDomain": w.UpdateForDomain("tenant1"),
+		"UpdateAsync":     <-w.UpdateAsync(),
+	} {
+		if err != ErrSubscribeOnly {
+			t.Fatalf("%s should fail with ErrSubscribeOnly, got %v", name, err)
+		}
+	}
+	if w.pubConn != nil || len(pub.calls("PUBLISH")) != 0 {
+		t.Fatal("Subscribe only watchers must not use a publish connection")
+	}
+}
@@ -0,0 +1,71 @@
+package rediswatcher
+
+import "fmt"
+
+// Logger receives the log output of a watcher. *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// NoopLogger discards all log output.
+var NoopLogger Logger = noopLogger{}
+
+type noopLogger struct{}
+
+func (noopLogger) Printf(string, ...interface{}) {}
+
+// stdoutLogger is the default Logger, printing to stdout as the watcher
+// always did.
+type stdoutLogger struct{}
+
+func (stdoutLogger) Printf(format string, v ...interface{}) {
+	fmt.Printf(format+"\n", v...)
+}
+
+// LeveledLogger is a structured logger with levels, taking key-value pairs
+// after the message. *slog.Logger implements it.
+type LeveledLogger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// logf prints to the Logger. Messages also logged at a level go through
+// logEvent instead once a LeveledLogger is set.
+func (w *Watcher) logf(format string, v ...interface{}) {
+	if w.options.Logger != nil {
+		w.options.Logger.Printf(format, v...)
+	}
+}
+
+// logEvent logs msg and its key-value pairs to the LeveledLogger, if any,
+// and reports whether it did.
+func (w *Watcher) logEvent(level logLevel, msg string, args ...interface{}) bool {
+	l := w.options.LeveledLogger
+	if l == nil {
+		return false
+	}
+
+	args = append([]interface{}{"channel", w.channel(), "localId", w.options.LocalID}, args...)
+	switch level {
+	case levelDebug:
+		l.Debug(msg, args...)
+	case levelInfo:
+		l.Info(msg, args...)
+	case levelWarn:
+		l.Warn(msg, args...)
+	default:
+		l.Error(msg, args...)
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type recordLogger struct {
+	lines []string
+}
+
+func (l *recordLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestWithLogger(t *testing.T) {
+	c := NewTestConn()
+	logger := &recordLogger{}
+	w, err := newWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c),
+		ManualStart(true), WithLogger(logger), PublishDryRun(nil))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	w.reportError(fmt.Errorf("connection refused"))
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+
+	if len(logger.lines) != 2 ||
+		!strings.Contains(logger.lines[0], "connection refused") ||
+		!strings.HasPrefix(logger.lines[1], "Dry run") {
+		t.Fatalf("Subscription failure and dry run should be logged, got %q", logger.lines)
+	}
+}
+
+func TestSetErrorCallback(t *testing.T) {
+	var errs []error
+	w := &Watcher{closed: make(chan struct{})}
+	w.SetErrorCallback(func(err error) { errs = append(errs, err) })
+
+	w.SetUpdateCallbackWithError(func(string) error { return fmt.Errorf("load failed") })
+	w.runCallback("node2")
+	w.reportError(fmt.Errorf("connection refused"))
+	w.setSubscribed(true)
+	w.setSubscribed(false)
+	w.setSubscribed(true)
+
+	if fmt.Sprint(errs) != "[load failed connection refused]" {
+		t.Fatalf("Callback and subscription errors should be passed on, got %v", errs)
+	}
+}
@@ -0,0 +1,28 @@
+package rediswatcher
+
+// UpdateWithLSN works like Update but embeds the database transaction ID or
+// LSN of the change, so receivers can make sure their replica has caught up
+// before reloading, see WaitForLSN and ParseMessage.
+func (w *Watcher) UpdateWithLSN(lsn string) error {
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+	return w.publishMessage(Message{
+		Type: MessageTypeUpdate,
+		ID:   w.options.LocalID,
+		LSN:  lsn,
+	})
+}
+
+// waitForLSN blocks until the local replica has reached the LSN carried by
+// data, if any.
+func (w *Watcher) waitForLSN(data string) error {
+	if w.options.waitForLSN == nil {
+		return nil
+	}
+	m, ok := decodeMessage(data)
+	if !ok || m.LSN == "" {
+		return nil
+	}
+	return w.options.waitForLSN(m.LSN)
+}
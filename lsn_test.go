@@ -0,0 +1,45 @@
+package rediswatcher
+
+import (
+	"testing"
+)
+
+func TestUpdateWithLSN(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+
+	var waited string
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), WaitForLSN(func(lsn string) error {
+			waited = lsn
+			return nil
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	msg := `{"type":"update","id":"node1","lsn":"0/16B3748"}`
+	publish := pub.Command("PUBLISH", "/casbin", msg).Expect(int64(1))
+	if err := w.UpdateWithLSN("0/16B3748"); err != nil {
+		t.Fatalf("Failed watcher.UpdateWithLSN(): %v", err)
+	}
+	if pub.Stats(publish) != 1 {
+		t.Fatal("LSN message was not published")
+	}
+
+	var received string
+	w.SetUpdateCallback(func(data string) {
+		if waited == "" {
+			t.Error("Callback invoked before waiting for the LSN")
+		}
+		received = data
+	})
+	w.runCallback(msg)
+	if waited != "0/16B3748" {
+		t.Fatalf("Should wait for LSN '0/16B3748', waited for '%s'", waited)
+	}
+	if m, ok := ParseMessage(received); !ok || m.LSN != "0/16B3748" {
+		t.Fatalf("Callback should receive the LSN message, received '%s'", received)
+	}
+}
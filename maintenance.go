@@ -0,0 +1,40 @@
+package rediswatcher
+
+import "time"
+
+// MaintenanceWindow is a quiet period, e.g. planned database maintenance,
+// during which update callbacks are deferred.
+type MaintenanceWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ScheduleMaintenance defers update callbacks from start until end. Updates
+// keep being received and counted; when the window ends the callback runs
+// once with the last of them, unless the watcher is still paused. A window
+// that already started takes effect at once, one that ended is ignored.
+func (w *Watcher) ScheduleMaintenance(start, end time.Time) {
+	if !end.After(w.clock().Now()) {
+		return
+	}
+
+	w.spawn(func() {
+		select {
+		case <-w.closed:
+			return
+		case <-w.clock().After(start.Sub(w.clock().Now())):
+		}
+		w.pauseMu.Lock()
+		w.pause.quiet = true
+		w.pauseMu.Unlock()
+		w.logEvent(levelInfo, "maintenance window started", "end", end)
+
+		select {
+		case <-w.closed:
+			return
+		case <-w.clock().After(end.Sub(w.clock().Now())):
+		}
+		missed := w.unpause(func(p *pauseState) { p.quiet = false })
+		w.logEvent(levelInfo, "maintenance window ended", "missed", missed)
+	})
+}
@@ -0,0 +1,59 @@
+package rediswatcher
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduleMaintenance(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	defer close(w.closed)
+	var mu sync.Mutex
+	var received []string
+	w.SetUpdateCallback(func(msg string) {
+		mu.Lock()
+		received = append(received, msg)
+		mu.Unlock()
+	})
+
+	w.ScheduleMaintenance(time.Now().Add(-time.Second), time.Now().Add(50*time.Millisecond))
+	for deadline := time.Now().Add(time.Second); !w.Paused() && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+	if !w.Paused() {
+		t.Fatal("Callbacks should be deferred inside the window")
+	}
+	w.deliver("node2")
+	w.deliver("node3")
+	mu.Lock()
+	if len(received) != 0 {
+		t.Fatalf("No callbacks expected inside the window, received %v", received)
+	}
+	mu.Unlock()
+
+	for deadline := time.Now().Add(time.Second); w.Paused() && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if w.Paused() || len(received) != 1 || received[0] != "node3" {
+		t.Fatalf("The window should end with one catch-up, received %v", received)
+	}
+}
+
+func TestMaintenanceWhilePaused(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	var received []string
+	w.SetUpdateCallback(func(msg string) {
+		received = append(received, msg)
+	})
+
+	w.Pause()
+	w.pause.quiet = true
+	w.deliver("node2")
+	if missed := w.Resume(); missed != 1 || len(received) != 0 {
+		t.Fatalf("Resume inside a window should not catch up, received %v", received)
+	}
+	if missed := w.unpause(func(p *pauseState) { p.quiet = false }); missed != 1 || len(received) != 1 {
+		t.Fatalf("The end of the window should catch up, received %v", received)
+	}
+}
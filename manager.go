@@ -0,0 +1,194 @@
+package rediswatcher
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Manager hands out a watcher per tenant, all sharing the connections and
+// goroutines of one Watcher. Tenant watchers are created on first use, on
+// the channel given by DomainChannel, and kept while the enforcers using
+// them hold them. Once released they are evicted after idleTimeout without
+// a Tenant call, Update or received update:
+//
+//	m, _ := rediswatcher.NewManager(addr, time.Hour)
+//	tw, _ := m.Tenant("acme")
+//	enforcer.SetWatcher(tw)
+//	...
+//	m.Release("acme") // the enforcer of acme is gone
+type Manager struct {
+	w    *Watcher
+	idle time.Duration
+
+	mu        sync.Mutex
+	tenants   map[string]*managedTenant
+	evictions int64
+}
+
+type managedTenant struct {
+	watcher  *ChannelWatcher
+	clock    Clock
+	lastUsed int64 // UnixNano, accessed atomically
+	holders  int   // Tenant calls not yet released, guarded by Manager.mu
+}
+
+// ManagerStats is a snapshot of a Manager and its shared watcher.
+type ManagerStats struct {
+	Tenants   int   // Tenant watchers currently open.
+	Evictions int64 // Tenant watchers closed as no one held them.
+	Watcher   Stats
+}
+
+// NewManager creates the shared watcher with setters and starts evicting
+// released tenant watchers idle for idleTimeout, 0 closing them on their
+// last Release.
+func NewManager(addr string, idleTimeout time.Duration, setters ...WatcherOption) (*Manager, error) {
+	w, err := newWatcher(addr, setters...)
+	if err != nil {
+		return nil, err
+	}
+	return newManager(w, idleTimeout), nil
+}
+
+func newManager(w *Watcher, idleTimeout time.Duration) *Manager {
+	m := &Manager{w: w, idle: idleTimeout, tenants: make(map[string]*managedTenant)}
+	if idleTimeout > 0 {
+		w.spawn(func() {
+			ticker := w.clock().NewTicker(idleTimeout / 2)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-w.closed:
+					return
+				case <-ticker.C():
+					m.evictIdle()
+				}
+			}
+		})
+	}
+	return m
+}
+
+// Tenant returns the watcher of tenant, creating it when needed. It stays
+// subscribed until Release is called as often as Tenant.
+func (m *Manager) Tenant(tenant string) (*ChannelWatcher, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, err := m.tenant(tenant)
+	if err != nil {
+		return nil, err
+	}
+	t.holders++
+	return t.watcher, nil
+}
+
+// Release gives back a watcher returned by Tenant. When no one holds it any
+// more, it is closed once idle.
+func (m *Manager) Release(tenant string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tenants[tenant]
+	if !ok || t.holders == 0 {
+		return
+	}
+	t.holders--
+	if t.holders == 0 && m.idle <= 0 {
+		m.evict(tenant, t)
+	}
+}
+
+// tenant returns the watcher of tenant, creating it when needed. Callers
+// must hold mu.
+func (m *Manager) tenant(tenant string) (*managedTenant, error) {
+	if t, ok := m.tenants[tenant]; ok {
+		t.touch()
+		return t, nil
+	}
+	t := &managedTenant{clock: m.w.clock()}
+	cw, err := m.w.forChannel(m.w.DomainChannel(tenant), func(string) bool {
+		t.touch()
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	cw.onUpdate = t.touch
+	t.watcher = cw
+	t.touch()
+	m.tenants[tenant] = t
+	return t, nil
+}
+
+// Update publishes an update for tenant on the shared watcher, without
+// subscribing to the channel of the tenant.
+func (m *Manager) Update(tenant string) error {
+	m.mu.Lock()
+	if t, ok := m.tenants[tenant]; ok {
+		t.touch()
+	}
+	m.mu.Unlock()
+	return m.w.updateOn(m.w.DomainChannel(tenant))
+}
+
+// Tenants returns the tenants with an open watcher, sorted.
+func (m *Manager) Tenants() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tenants := make([]string, 0, len(m.tenants))
+	for tenant := range m.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// Stats returns the number of tenant watchers and the shared watcher stats.
+func (m *Manager) Stats() ManagerStats {
+	m.mu.Lock()
+	s := ManagerStats{Tenants: len(m.tenants), Evictions: m.evictions}
+	m.mu.Unlock()
+	s.Watcher = m.w.Stats()
+	return s
+}
+
+// Watcher returns the shared watcher, e.g. to set an error callback.
+func (m *Manager) Watcher() *Watcher {
+	return m.w
+}
+
+// Close closes every tenant watcher and the shared watcher.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.tenants = make(map[string]*managedTenant)
+	m.mu.Unlock()
+	m.w.Close()
+}
+
+func (m *Manager) evictIdle() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deadline := m.w.clock().Now().Add(-m.idle).UnixNano()
+	for tenant, t := range m.tenants {
+		if t.holders == 0 && t.lastUse() < deadline {
+			m.evict(tenant, t)
+		}
+	}
+}
+
+// evict closes the watcher of tenant. Callers must hold mu.
+func (m *Manager) evict(tenant string, t *managedTenant) {
+	t.watcher.Close()
+	delete(m.tenants, tenant)
+	m.evictions++
+}
+
+func (t *managedTenant) touch() {
+	atomic.StoreInt64(&t.lastUsed, t.clock.Now().UnixNano())
+}
+
+func (t *managedTenant) lastUse() int64 {
+	return atomic.LoadInt64(&t.lastUsed)
+}
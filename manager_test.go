@@ -0,0 +1,57 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestManager(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), ManualStart(true))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	w.messagesIn = make(chan redis.Message)
+	m := newManager(w, 0)
+	defer m.Close()
+
+	acme, err := m.Tenant("acme")
+	if err != nil {
+		t.Fatalf("Failed manager.Tenant(): %v", err)
+	}
+	if again, _ := m.Tenant("acme"); again != acme {
+		t.Fatal("Tenant should return the open tenant watcher")
+	}
+	if err := m.Update("globex"); err != nil {
+		t.Fatalf("Failed manager.Update(): %v", err)
+	}
+	if calls := pub.calls("PUBLISH"); len(calls) != 1 || calls[0][0] != "/casbin/globex" {
+		t.Fatalf("Update should be published on the tenant channel, got %v", calls)
+	}
+	if channels := fmt.Sprint(w.channels()); channels != "[/casbin /casbin/acme]" || fmt.Sprint(m.Tenants()) != "[acme]" {
+		t.Fatalf("Only the channels of held tenants should be subscribed, got %v", channels)
+	}
+
+	// acme has been idle, but it is held until released twice
+	m.tenants["acme"].lastUsed = 1
+	m.idle = time.Hour
+	m.evictIdle()
+	m.Release("acme")
+	m.evictIdle()
+	if s := m.Stats(); s.Tenants != 1 || s.Evictions != 0 {
+		t.Fatalf("Held tenants should not be evicted, got %v and %+v", m.Tenants(), s)
+	}
+	m.Release("acme")
+	m.evictIdle()
+	if s := m.Stats(); len(m.Tenants()) != 0 || s.Tenants != 0 || s.Evictions != 1 {
+		t.Fatalf("Idle tenants should be evicted, got %v and %+v", m.Tenants(), s)
+	}
+	if channels := fmt.Sprint(w.channels()); channels != "[/casbin]" {
+		t.Fatalf("Evicted tenant channels should be left, got %v", channels)
+	}
+}
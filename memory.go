@@ -0,0 +1,298 @@
+package rediswatcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// errMemoryConnClosed is returned by a closed connection of a MemoryBroker.
+var errMemoryConnClosed = errors.New("rediswatcher: memory connection closed")
+
+// MemoryBroker is an in-process stand-in for the Pub/Sub of a Redis server.
+// Watchers created WithMemoryBroker on the same broker exchange updates as
+// they would through Redis, so application tests can exercise publishing
+// and receiving without a server. It supports PUBLISH, the subscription
+// commands, PING, TIME and PUBSUB NUMSUB; options relying on other commands
+// fail with an error.
+type MemoryBroker struct {
+	mu    sync.Mutex
+	conns map[*memoryConn]bool
+}
+
+// NewMemoryBroker creates an empty broker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{conns: make(map[*memoryConn]bool)}
+}
+
+// WithMemoryBroker connects the watcher to b instead of Redis, with a publish
+// and a subscription connection of its own.
+func WithMemoryBroker(b *MemoryBroker) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PubConn = b.Conn()
+		options.SubConn = b.Conn()
+	}
+}
+
+// Conn returns a new connection to b.
+func (b *MemoryBroker) Conn() redis.Conn {
+	c := &memoryConn{
+		broker:   b,
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	b.mu.Lock()
+	b.conns[c] = true
+	b.mu.Unlock()
+	return c
+}
+
+// Publish delivers data to the subscribers of channel and returns their
+// number, like the PUBLISH command.
+func (b *MemoryBroker) Publish(channel string, data []byte) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var receivers int64
+	for c := range b.conns {
+		receivers += c.deliver(channel, data)
+	}
+	return receivers
+}
+
+// numsub counts the connections subscribed to channel.
+func (b *MemoryBroker) numsub(channel string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var n int64
+	for c := range b.conns {
+		c.mu.Lock()
+		if c.channels[channel] {
+			n++
+		}
+		c.mu.Unlock()
+	}
+	return n
+}
+
+// memoryConn is a redis.Conn of a MemoryBroker. Replies to Send and pushed
+// messages wait in replies for Receive.
+type memoryConn struct {
+	broker   *MemoryBroker
+	mu       sync.Mutex
+	cond     *sync.Cond
+	closed   bool
+	channels map[string]bool
+	patterns map[string]bool
+	replies  []interface{}
+}
+
+func (c *memoryConn) Close() error {
+	c.broker.mu.Lock()
+	delete(c.broker.conns, c)
+	c.broker.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.cond.Broadcast()
+	return nil
+}
+
+func (c *memoryConn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errMemoryConnClosed
+	}
+	return nil
+}
+
+func (c *memoryConn) Do(command string, args ...interface{}) (interface{}, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	if command == "" {
+		// a bare flush, Send queues its replies for Receive
+		return nil, nil
+	}
+	return c.exec(strings.ToUpper(command), stringArgs(args))
+}
+
+func (c *memoryConn) DoWithTimeout(_ time.Duration, command string, args ...interface{}) (interface{}, error) {
+	return c.Do(command, args...)
+}
+
+func (c *memoryConn) Send(command string, args ...interface{}) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	command = strings.ToUpper(command)
+	names := stringArgs(args)
+	switch command {
+	case "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE":
+		c.subscription(command, names)
+	default:
+		reply, err := c.exec(command, names)
+		if err != nil {
+			c.push(redis.Error(err.Error()))
+		} else {
+			c.push(reply)
+		}
+	}
+	return nil
+}
+
+func (c *memoryConn) Flush() error {
+	return c.Err()
+}
+
+func (c *memoryConn) Receive() (interface{}, error) {
+	return c.receive(time.Time{})
+}
+
+func (c *memoryConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	if timeout <= 0 {
+		return c.Receive()
+	}
+	// wake the waiting receive at the deadline
+	timer := time.AfterFunc(timeout, func() {
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	})
+	defer timer.Stop()
+	return c.receive(time.Now().Add(timeout))
+}
+
+// receive waits for the next reply, until deadline unless it is zero.
+func (c *memoryConn) receive(deadline time.Time) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.replies) == 0 && !c.closed {
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return nil, errors.New("rediswatcher: memory connection receive timed out")
+		}
+		c.cond.Wait()
+	}
+	if c.closed {
+		return nil, errMemoryConnClosed
+	}
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	if err, ok := reply.(redis.Error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// exec runs a command that is not about subscriptions.
+func (c *memoryConn) exec(command string, args []string) (interface{}, error) {
+	switch {
+	case command == "PUBLISH" && len(args) == 2:
+		return c.broker.Publish(args[0], []byte(args[1])), nil
+	case command == "PING":
+		if c.subscribed() {
+			data := ""
+			if len(args) > 0 {
+				data = args[0]
+			}
+			return []interface{}{[]byte("pong"), []byte(data)}, nil
+		}
+		return "PONG", nil
+	case command == "TIME":
+		now := time.Now()
+		return []interface{}{
+			[]byte(fmt.Sprint(now.Unix())),
+			[]byte(fmt.Sprint(now.Nanosecond() / int(time.Microsecond))),
+		}, nil
+	case command == "PUBSUB" && len(args) > 0 && strings.ToUpper(args[0]) == "NUMSUB":
+		reply := []interface{}{}
+		for _, channel := range args[1:] {
+			reply = append(reply, []byte(channel), c.broker.numsub(channel))
+		}
+		return reply, nil
+	}
+	return nil, fmt.Errorf("rediswatcher: %s is not supported by the memory broker", command)
+}
+
+// subscription changes the subscriptions of c and queues the confirmations.
+func (c *memoryConn) subscription(command string, names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set := c.channels
+	if command == "PSUBSCRIBE" || command == "PUNSUBSCRIBE" {
+		set = c.patterns
+	}
+	subscribe := command == "SUBSCRIBE" || command == "PSUBSCRIBE"
+	if !subscribe && len(names) == 0 {
+		for name := range set {
+			names = append(names, name)
+		}
+	}
+	kind := []byte(strings.ToLower(command))
+	for _, name := range names {
+		if subscribe {
+			set[name] = true
+		} else {
+			delete(set, name)
+		}
+		c.replies = append(c.replies, []interface{}{kind, []byte(name), int64(len(c.channels) + len(c.patterns))})
+	}
+	c.cond.Broadcast()
+}
+
+// deliver queues data for c when it subscribes channel, and returns the
+// number of messages queued.
+func (c *memoryConn) deliver(channel string, data []byte) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	if c.channels[channel] {
+		c.replies = append(c.replies, []interface{}{[]byte("message"), []byte(channel), data})
+		n++
+	}
+	for pattern := range c.patterns {
+		if matchPattern(pattern, channel) {
+			c.replies = append(c.replies, []interface{}{[]byte("pmessage"), []byte(pattern), []byte(channel), data})
+			n++
+		}
+	}
+	if n > 0 {
+		c.cond.Broadcast()
+	}
+	return n
+}
+
+func (c *memoryConn) subscribed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.channels)+len(c.patterns) > 0
+}
+
+func (c *memoryConn) push(reply interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.replies = append(c.replies, reply)
+	c.cond.Broadcast()
+}
+
+// stringArgs converts the arguments of a command like redigo writes them.
+func stringArgs(args []interface{}) []string {
+	names := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case []byte:
+			names[i] = string(v)
+		case string:
+			names[i] = v
+		default:
+			names[i] = fmt.Sprint(v)
+		}
+	}
+	return names
+}
@@ -0,0 +1,45 @@
+package rediswatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	b := NewMemoryBroker()
+	w1, err := newWatcher("", WithMemoryBroker(b), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w1.Close()
+	w2, err := newWatcher("", WithMemoryBroker(b), LocalID("node2"))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w2.Close()
+
+	ch := make(chan string, 1)
+	w2.SetUpdateCallback(func(msg string) { ch <- msg })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := w2.WaitUntilSubscribed(ctx); err != nil {
+		t.Fatalf("The watcher should subscribe to the broker, got %v", err)
+	}
+
+	if err := w1.Update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	select {
+	case res := <-ch:
+		if res != "node1" {
+			t.Fatalf("Expected 'node1', got '%s'", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The update should be delivered in-process")
+	}
+
+	if n := b.Publish("/casbin", []byte("node3")); n != 2 {
+		t.Fatalf("Both watchers should receive, got %d", n)
+	}
+}
@@ -0,0 +1,179 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Message types of structured watcher messages. A plain Update still
+// publishes the bare LocalID so older watchers keep working.
+const (
+	MessageTypeUpdate  = "update"
+	MessageTypePrepare = "prepare"
+	MessageTypeCommit  = "commit"
+	MessageTypeProbe   = "probe"
+	MessageTypeHello   = "hello"
+	MessageTypeBatch   = "batch"
+	MessageTypeStatus  = "status"
+	MessageTypeAck     = "ack"
+	MessageTypeReload  = "reload" // Issued locally by PeriodicReload, never published.
+	MessageTypeBye     = "bye"
+	MessageTypePayload = "payload"
+
+	MessageTypePolicyRequest = "policyRequest"
+	MessageTypePolicy        = "policy"
+)
+
+// Message is the JSON payload published for protocol messages that carry
+// more than the sender ID.
+type Message struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Version string `json:"version,omitempty"`
+	Nonce   string `json:"nonce,omitempty"`
+	LSN     string `json:"lsn,omitempty"` // Database transaction ID/LSN of the change.
+
+	Capabilities []string    `json:"capabilities,omitempty"`
+	Operations   []Operation `json:"operations,omitempty"` // Policy changes of a batch.
+	Relays       []string    `json:"relays,omitempty"`     // IDs of the bridges that relayed the message.
+	Groups       []string    `json:"groups,omitempty"`     // Instance groups the update is meant for, empty for all.
+	Reply        string      `json:"reply,omitempty"`      // Channel receiving acknowledgements, see UpdateAndWait.
+	Snapshot     string      `json:"snapshot,omitempty"`   // Redis key of the policy snapshot, see UpdateWithSnapshot.
+	Encoding     string      `json:"encoding,omitempty"`   // Compression of the snapshot, "gzip" for UpdateWithSnapshotStream.
+	Policy       []byte      `json:"policy,omitempty"`     // Policy sent in answer to FetchPolicy.
+	Time         int64       `json:"time,omitempty"`       // Redis server time of the publish in Unix microseconds, see Timestamps.
+	LocalTime    int64       `json:"localTime,omitempty"`  // Sender clock at the publish in Unix microseconds.
+
+	Kind    string          `json:"kind,omitempty"`    // Application payload kind, see PublishPayload.
+	Payload json.RawMessage `json:"payload,omitempty"` // Application payload as JSON.
+}
+
+// ParseMessage decodes a structured message received by an update callback.
+// It reports false for plain payloads such as the one sent by Update.
+func ParseMessage(data string) (Message, bool) {
+	return decodeMessage(data)
+}
+
+func encodeMessage(m Message) string {
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// decodeMessage parses data as a structured Message, reporting false for
+// plain payloads.
+func decodeMessage(data string) (Message, bool) {
+	if strings.HasPrefix(data, codecPrefix) {
+		return decodeEnvelope(data)
+	}
+	if !strings.HasPrefix(data, "{") {
+		return Message{}, false
+	}
+	return decodeJSON(data)
+}
+
+// skippedField discards a JSON value without decoding it.
+type skippedField struct{}
+
+func (*skippedField) UnmarshalJSON([]byte) error { return nil }
+
+// decodeHeader works like decodeMessage but leaves out the rules of
+// batches, answered policies and application payloads, which the processing
+// loop doesn't need to route or filter a message. Callbacks get them from
+// ParseMessage, so messages discarded on the way are never decoded in full.
+// Messages of a registered codec are decoded in full.
+func decodeHeader(data string) (Message, bool) {
+	if !strings.HasPrefix(data, "{") {
+		return decodeMessage(data)
+	}
+	var h struct {
+		Message
+		Operations skippedField `json:"operations"`
+		Policy     skippedField `json:"policy"`
+		Payload    skippedField `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(data), &h); err != nil || h.Type == "" {
+		return Message{}, false
+	}
+	return h.Message, true
+}
+
+// decodeJSON decodes a JSON message. It is split from decodeMessage so that
+// plain messages don't pay for the Message json.Unmarshal makes escape.
+func decodeJSON(data string) (Message, bool) {
+	var m Message
+	if err := json.Unmarshal([]byte(data), &m); err != nil || m.Type == "" {
+		return m, false
+	}
+	return m, true
+}
+
+// publishMessage encodes and publishes m on the watcher channel.
+func (w *Watcher) publishMessage(m Message) error {
+	return w.publishLocked(func() error {
+		if w.downgrade(m) {
+			return w.publishOrQueue(w.options.LocalID)
+		}
+		if w.options.Timestamps {
+			w.stamp(&m)
+		}
+		msg, err := w.encode(m)
+		if err != nil {
+			return err
+		}
+		return w.publishOrQueue(msg)
+	})
+}
+
+// handleControlMessage processes protocol messages. It returns true when the
+// message has been consumed and must not reach the update callback.
+func (w *Watcher) handleControlMessage(m Message) bool {
+	if m.Type == MessageTypeProbe {
+		if m.ID == w.options.LocalID {
+			w.probeReceived(m.Nonce)
+		}
+		return true
+	}
+	if m.Type == MessageTypeHello {
+		if m.ID != w.options.LocalID {
+			w.helloReceived(m)
+		}
+		return true
+	}
+	if m.Type == MessageTypeBye {
+		w.forgetPeer(m.ID)
+		return true
+	}
+	if m.Type == MessageTypeStatus {
+		w.answerStatus(m)
+		return true
+	}
+	if m.Type == MessageTypeAck || m.Type == MessageTypePolicy {
+		w.replyReceived(m)
+		return true
+	}
+	if m.Type == MessageTypePolicyRequest {
+		w.answerPolicyRequest(m)
+		return true
+	}
+	if w.options.IgnoreSelf && m.ID == w.options.LocalID {
+		return true
+	}
+	if !w.inGroups(m.Groups) {
+		return true
+	}
+
+	switch m.Type {
+	case MessageTypePrepare:
+		if w.options.prepareCallback != nil {
+			w.dispatchJob(job{msg: encodeMessage(m), control: m.Type})
+		}
+		return true
+	case MessageTypeCommit:
+		// without a commit handler a commit is an ordinary update
+		if w.options.commitCallback != nil {
+			w.dispatchJob(job{msg: encodeMessage(m), control: m.Type})
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package rediswatcher
+
+import "context"
+
+// Handler handles a received update message.
+type Handler func(msg string) error
+
+// Middleware wraps a Handler, e.g. to log, measure, verify or filter updates
+// before they reach the update callback. A middleware that does not call
+// next drops the message. The innermost handler runs the update callback,
+// including any retries, and returns its final error.
+type Middleware func(next Handler) Handler
+
+// handler builds the middleware chain around handleUpdate.
+func (w *Watcher) handler(ctx context.Context) Handler {
+	h := Handler(func(msg string) error {
+		return w.handleUpdate(ctx, msg)
+	})
+	for i := len(w.options.middleware) - 1; i >= 0; i-- {
+		h = w.options.middleware[i](h)
+	}
+	return h
+}
@@ -0,0 +1,72 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestMiddleware(t *testing.T) {
+	var trace []string
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(msg string) error {
+				trace = append(trace, name)
+				return next(msg)
+			}
+		}
+	}
+	dropSelf := func(next Handler) Handler {
+		return func(msg string) error {
+			if msg == "node1" {
+				return nil
+			}
+			return next(msg)
+		}
+	}
+
+	w := &Watcher{closed: make(chan struct{})}
+	WithMiddleware(tag("outer"), tag("inner"), dropSelf)(&w.options)
+	w.SetUpdateCallback(func(msg string) {
+		trace = append(trace, "callback:"+msg)
+	})
+
+	w.runCallback("node2")
+	if fmt.Sprint(trace) != "[outer inner callback:node2]" {
+		t.Fatalf("Middleware should wrap the callback in order, got %v", trace)
+	}
+
+	trace = nil
+	w.runCallback("node1")
+	if fmt.Sprint(trace) != "[outer inner]" {
+		t.Fatalf("Middleware should be able to drop messages, got %v", trace)
+	}
+}
+
+func TestMessageFilter(t *testing.T) {
+	w := &Watcher{
+		closed:      make(chan struct{}),
+		messagesIn:  make(chan redis.Message),
+		callbackSet: make(chan struct{}, 1),
+	}
+	w.options.SquashTimeoutLong = time.Second
+	MessageFilter(func(msg string) bool { return msg != "node3" })(&w.options)
+
+	ch := make(chan string, 2)
+	w.SetUpdateCallback(func(msg string) { ch <- msg })
+	w.messageInProcessor()
+	defer close(w.closed)
+
+	w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte("node3")}
+	w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte("node2")}
+	select {
+	case res := <-ch:
+		if res != "node2" {
+			t.Fatalf("Filtered message reached the callback: %s", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Accepted message was not delivered")
+	}
+}
@@ -0,0 +1,79 @@
+package rediswatcher
+
+import "errors"
+
+// ChannelWatcher is a persist.Watcher for one channel of a shared Watcher,
+// so several enforcers with different models or policies use the same
+// connections and goroutines:
+//
+//	w, _ := rediswatcher.NewWatcher(addr)
+//	users, _ := w.ForChannel("/casbin/users", nil)
+//	usersEnforcer.SetWatcher(users)
+type ChannelWatcher struct {
+	w        *Watcher
+	channel  string
+	filter   func(msg string) bool
+	onUpdate func() // called by Update, see Manager
+}
+
+// ForChannel returns a ChannelWatcher publishing and receiving updates on
+// channel, which the watcher subscribes to unless it only publishes. When
+// filter is set, only messages it accepts reach the callback of the
+// ChannelWatcher.
+func (w *Watcher) ForChannel(channel string, filter func(msg string) bool) (*ChannelWatcher, error) {
+	return w.forChannel(w.prefixed(channel), filter)
+}
+
+func (w *Watcher) forChannel(channel string, filter func(msg string) bool) (*ChannelWatcher, error) {
+	if w.isClosed() {
+		return nil, ErrClosed
+	}
+	if channel == w.channel() {
+		return nil, errors.New("rediswatcher: the watcher channel cannot be shared")
+	}
+
+	if w.messagesIn != nil {
+		if w.subDone != nil {
+			if err := w.subscribeChannel(channel); err != nil {
+				return nil, err
+			}
+		} else {
+			w.addChannel(channel)
+		}
+	}
+	return &ChannelWatcher{w: w, channel: channel, filter: filter}, nil
+}
+
+// SetUpdateCallback sets the callback for the updates of the channel.
+func (c *ChannelWatcher) SetUpdateCallback(callback func(string)) error {
+	if callback == nil {
+		c.w.setChannelCallback(c.channel, nil)
+		return nil
+	}
+	c.w.setChannelCallback(c.channel, func(msg string) error {
+		if c.filter == nil || c.filter(msg) {
+			callback(msg)
+		}
+		return nil
+	})
+	return nil
+}
+
+// Update publishes an update on the channel.
+func (c *ChannelWatcher) Update() error {
+	if c.onUpdate != nil {
+		c.onUpdate()
+	}
+	return c.w.updateOn(c.channel)
+}
+
+// Close stops delivering the updates of the channel and leaves it. The
+// shared watcher keeps running.
+func (c *ChannelWatcher) Close() {
+	c.w.setChannelCallback(c.channel, nil)
+	if c.w.subDone == nil {
+		c.w.removeChannel(c.channel)
+	} else if !c.w.isClosed() {
+		c.w.unsubscribeChannel(c.channel)
+	}
+}
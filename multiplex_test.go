@@ -0,0 +1,52 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/garyburd/redigo/redis"
+)
+
+var _ persist.Watcher = &ChannelWatcher{}
+
+func TestForChannel(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), ManualStart(true))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	w.messagesIn = make(chan redis.Message)
+
+	if _, err := w.ForChannel("/casbin", nil); err == nil {
+		t.Fatal("The watcher channel cannot be shared")
+	}
+	users, err := w.ForChannel("/casbin/users", func(msg string) bool { return msg != "skip" })
+	if err != nil {
+		t.Fatalf("Failed watcher.ForChannel(): %v", err)
+	}
+	received := make(chan string, 2)
+	users.SetUpdateCallback(func(msg string) { received <- msg })
+
+	w.messageInProcessor()
+	w.messagesIn <- redis.Message{Channel: "/casbin/users", Data: []byte("skip")}
+	w.messagesIn <- redis.Message{Channel: "/casbin/users", Data: []byte("node2")}
+	select {
+	case msg := <-received:
+		if msg != "node2" {
+			t.Fatalf("Filtered messages should not be delivered, got %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Update was not delivered to the channel watcher")
+	}
+
+	if err := users.Update(); err != nil {
+		t.Fatalf("Failed ChannelWatcher.Update(): %v", err)
+	}
+	if calls := pub.calls("PUBLISH"); len(calls) != 1 || calls[0][0] != "/casbin/users" {
+		t.Fatalf("Update should be published on the channel, got %v", calls)
+	}
+}
@@ -0,0 +1,69 @@
+package rediswatcher
+
+import (
+	"fmt"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func (w *Watcher) startChannelCheck() {
+	if w.options.channelCheckInterval <= 0 {
+		return
+	}
+
+	w.spawn(func() {
+		delay := defaultChannelCheckDelay
+		if w.options.channelCheckInterval < delay {
+			delay = w.options.channelCheckInterval
+		}
+		next := w.clock().After(delay)
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-next:
+				if err := w.checkChannel(); err != nil {
+					w.reportError(err)
+				}
+				next = w.clock().After(w.options.channelCheckInterval)
+			}
+		}
+	})
+}
+
+// subscriberCount returns the number of clients subscribed to the channel.
+func (w *Watcher) subscriberCount() (int64, error) {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+
+	c, err := w.publisher()
+	if err != nil {
+		return 0, err
+	}
+	values, err := redis.Values(c.Do("PUBSUB", "NUMSUB", w.channel()))
+	if err != nil {
+		return 0, err
+	}
+	if len(values) != 2 {
+		return 0, fmt.Errorf("rediswatcher: unexpected PUBSUB NUMSUB reply %v", values)
+	}
+	return redis.Int64(values[1], nil)
+}
+
+// checkChannel returns an error when fewer peers than configured listen on
+// the channel. A subscribing watcher does not count itself.
+func (w *Watcher) checkChannel() error {
+	count, err := w.subscriberCount()
+	if err != nil {
+		return err
+	}
+	peers := count
+	if w.messagesIn != nil {
+		peers--
+	}
+	if peers < w.options.channelCheckMinPeers {
+		return fmt.Errorf("rediswatcher: channel %q has %d subscribers, expected at least %d peers; check the channel name",
+			w.channel(), count, w.options.channelCheckMinPeers)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChannelCheck(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		ChannelCheck(time.Hour, 1))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	pub.Command("PUBSUB", "NUMSUB", "/casbin").Expect([]interface{}{[]byte("/casbin"), int64(0)})
+	if err := w.checkChannel(); err == nil {
+		t.Fatal("Channel without subscribers should be reported")
+	}
+
+	pub.Command("PUBSUB", "NUMSUB", "/casbin").Expect([]interface{}{[]byte("/casbin"), int64(2)})
+	if err := w.checkChannel(); err != nil {
+		t.Fatalf("Channel with subscribers reported: %v", err)
+	}
+}
@@ -1,6 +1,11 @@
 package rediswatcher
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
@@ -8,19 +13,150 @@ import (
 
 type WatcherOptions struct {
 	Channel                     string
+	ChannelPrefix               string // Namespace, e.g. "prod:", put in front of every channel.
 	PubConn                     redis.Conn
 	SubConn                     redis.Conn
+	QueueConn                   redis.Conn
 	Password                    string
 	Protocol                    string
+	TLSConfig                   *tls.Config   // TLS of the Redis connections, plain TCP when nil.
+	DialTimeout                 time.Duration // Time to establish a Redis connection, 0 for no limit.
+	PublishIdleTimeout          time.Duration // Idle time after which the publish connection is re-dialed, 0 for never.
+	ReconnectStrategy           RetryStrategy // Waits between reconnects, the resubscribe threshold when nil.
+	PublishRetryStrategy        RetryStrategy // Waits between StrictDelivery re-publishes, StrictDeliveryDelay when nil.
+	ConnectRetryTimeout         time.Duration // How long the constructor retries the initial connection, 0 for no retries.
+	ConnectRetryStrategy        RetryStrategy // Waits between those retries.
+	Clock                       Clock         // Time source of timers and backoffs, the system clock when nil.
+	Synchronous                 bool          // Run callbacks inline and spawn no goroutines, for tests.
 	IgnoreSelf                  bool
 	LocalID                     string
 	RecordMetrics               func(*WatcherMetrics)
 	SquashMessages              bool
 	SquashTimeoutShort          time.Duration
 	SquashTimeoutLong           time.Duration
-	callbackPending             bool
+	SquashMaxDelay              time.Duration // Deliver squashed messages at the latest this long after the first.
+	PublishQueue                string        // Redis list holding messages that failed to publish.
+	PublishQueueAddr            string        // Redis target for the publish queue, defaults to the watcher address.
+	DeadLetterList              string        // Redis list receiving messages whose callback failed.
+	CallbackRetries             int           // Extra attempts for a failed update callback.
+	StrictDelivery              bool          // Fail publishes that reach no subscriber.
+	PublishDryRun               bool          // Log messages instead of publishing them.
+	ReceiveDryRun               bool          // Log received updates instead of running callbacks.
+	ManualStart                 bool          // Wait for Start or Run instead of starting in the constructor.
+	LazyConnect                 bool          // Dial Redis on first use instead of in the constructor.
+	PreflightCheck              bool          // Check the ACL permissions for the channels in the constructor.
+	PublishOnly                 bool          // Never subscribe, only publish updates.
+	SubscribeOnly               bool          // Never publish, only receive updates.
+	SubscriptionShards          int           // Connections the subscribed channels are spread over.
+	FederatedServers            []string      // Further Redis servers whose updates are received as well.
+	InstanceGroups              []string      // Groups of this instance, e.g. "canary", see UpdateForGroups.
+	TrustedPublishers           []string      // LocalIDs whose messages are accepted, empty accepts all.
+	Timestamps                  bool          // Stamp structured messages with the Redis server time.
+	PublishFormat               string        // Codec of published messages, see RegisterCodec; JSON by default.
+	DrainTimeout                time.Duration // Time Close gives running callbacks to finish.
+	EarlyBufferSize             int           // Messages kept until an update callback is set.
+	CallbackWorkers             int           // Goroutines running update callbacks, 0 runs them inline.
+	CallbackQueueSize           int           // Updates waiting for a callback worker.
+	ReceiveBufferSize           int           // Messages read from Redis ahead of the processing loop.
+	ReceiveBatch                int           // Queued updates a callback worker merges into one run, 0 for none.
+	SocketReadBuffer            int           // SO_RCVBUF of the Redis connections in bytes, 0 for the OS default.
+	SocketWriteBuffer           int           // SO_SNDBUF of the Redis connections in bytes, 0 for the OS default.
+	CallbackTimeout             time.Duration // Time an update callback may run, 0 for no limit.
+	OrderedDelivery             bool          // Run callbacks one at a time in arrival order.
+	OverflowPolicy              OverflowPolicy
+	OverflowSpillList           string        // Key of the Redis list holding, as JSON, the updates a full callback queue spilled; drained in order into the queue as it frees up.
+	DebounceWindow              time.Duration // Collapse updates received within this window into one callback.
+	PublishCoalesce             time.Duration // Collapse Update calls within this window into one publish.
+	PublishRate                 float64       // Publishes allowed per second, 0 for no limit.
+	PublishBurst                int           // Publishes allowed at once above PublishRate.
+	ReloadJitter                time.Duration // Upper bound of a random delay before callbacks run.
+	ReloadInterval              time.Duration // Run the update callback at least this often, 0 never.
+	ExpvarName                  string        // expvar variable exporting the watcher counters.
+	Logger                      Logger        // Log output, stdout by default.
+	LeveledLogger               LeveledLogger // Structured log output with levels, replaces Logger.
+	ErrorHistorySize            int           // Recent errors kept for Errors.
+	PublishDelay                time.Duration // Wait between the adapter write and the publish.
+	StrictDeliveryRetries       int
+	StrictDeliveryDelay         time.Duration
+	CallbackRetryBackoff        time.Duration
+	CallbackRetryMaxBackoff     time.Duration
+	Outbox                      Outbox
+	OutboxInterval              time.Duration
 	resubscribeThreshold        time.Duration   // Threshold for watcher to try resubscribe after error.
 	subscriptionFailureCallback func(err error) // Callback on subscription failure.
+	prepareCallback             func(version string)
+	commitCallback              func(version string)
+	dryRunLogger                func(channel, message string)
+	receiveDryRunLogger         func(channel, message string)
+	beforePublish               func() error
+	waitForLSN                  func(lsn string) error
+	channelCheckInterval        time.Duration
+	channelCheckMinPeers        int64
+	latencyProbeInterval        time.Duration
+	metricsSink                 MetricsSink
+	metricsInterval             time.Duration
+	statsChannel                string
+	statsInterval               time.Duration
+	auditPath                   string
+	auditMaxSize                int64
+	auditMaxBackups             int
+	auditStream                 string
+	auditStreamMaxLen           int64
+	statusChannel               string
+	presenceKey                 string
+	policyLockKey               string
+	policyProvider              func() ([]byte, error)
+	snapshotKey                 string
+	snapshotTTL                 time.Duration
+	maintenance                 []MaintenanceWindow
+	webhooks                    []webhook
+	scheduleKey                 string
+	scheduleInterval            time.Duration
+	warmUp                      func(snapshot []byte) error
+	warmUpReplay                time.Duration
+	leaderKey                   string
+	leaderTTL                   time.Duration
+	onLeaderChange              func(leader bool)
+	delegateKey                 string
+	delegateTTL                 time.Duration
+	delegateLoad                func() ([]byte, error)
+	delegateApply               func(policy []byte) error
+	presenceInterval            time.Duration
+	dualChannel                 string
+	dualConvert                 func(msg string) string
+	domains                     []string
+	patterns                    []string
+	channels                    []string
+	channelResolver             func(tenantID string) string
+	shardDialer                 func(addr string) (redis.Conn, error)
+	resyncSignals               []os.Signal
+	helloVersion                string
+	helloCallback               func(Message)
+	middleware                  []Middleware
+	messageFilter               func(msg string) bool
+	panicHandler                func(msg string, recovered interface{})
+	contextFactory              func() context.Context
+	faults                      *FaultInjector
+	crashHandler                func(err error, crashes int64)
+	overflowCallback            func(dropped string)
+	slowCallbackThreshold       time.Duration
+	slowCallback                func(msg string, elapsed time.Duration)
+	onConnect                   func()
+	onDisconnect                func(err error)
+	onSubscribed                func()
+	onResubscribed              func()
+	staleThreshold              time.Duration
+	staleCallback               func(disconnected time.Duration)
+	staleRecoveredCallback      func()
+	err                         error // of the first invalid setter, returned by the constructors
+}
+
+// invalid records err of an invalid setter for the constructors and
+// ApplyOptions to return. The first one is kept.
+func (options *WatcherOptions) invalid(err error) {
+	if options.err == nil {
+		options.err = err
+	}
 }
 
 type WatcherOption func(*WatcherOptions)
@@ -55,6 +191,14 @@ func WithRedisPubConnection(connection redis.Conn) WatcherOption {
 	}
 }
 
+// WithRedisQueueConnection sets the connection used to store messages that
+// failed to publish, see PublishQueue.
+func WithRedisQueueConnection(connection redis.Conn) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.QueueConn = connection
+	}
+}
+
 func LocalID(id string) WatcherOption {
 	return func(options *WatcherOptions) {
 		options.LocalID = id
@@ -103,11 +247,825 @@ func SquashTimeoutLong(d time.Duration) WatcherOption {
 	}
 }
 
+// SquashMaxDelay bounds how long squashing may hold messages back: however
+// many keep arriving, the callback runs at most d after the first of them.
+func SquashMaxDelay(d time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.SquashMaxDelay = d
+	}
+}
+
+// PublishQueue enables store-and-forward publishing: messages that fail to
+// publish are appended to the Redis list key and re-published, in order,
+// once publishing works again.
+func PublishQueue(key string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishQueue = key
+	}
+}
+
+// PublishQueueAddr sets a separate Redis target ("host:port") for the
+// publish queue, so messages can still be stored while the main endpoint is
+// unreachable.
+func PublishQueueAddr(addr string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishQueueAddr = addr
+	}
+}
+
+// OutboxRelay publishes committed entries from outbox every interval, see
+// Watcher.UpdateInTx.
+func OutboxRelay(outbox Outbox, interval time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.Outbox = outbox
+		if interval > 0 {
+			options.OutboxInterval = interval
+		}
+	}
+}
+
+// TwoPhaseCallbacks registers the handlers for the prepare/commit protocol,
+// see Watcher.Prepare and Watcher.Commit. prepare should stage the given
+// policy version and commit should atomically switch to it. They run on the
+// callback workers like the update callback.
+func TwoPhaseCallbacks(prepare, commit func(version string)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.prepareCallback = prepare
+		options.commitCallback = commit
+	}
+}
+
+// DeadLetterList pushes messages whose update callback returned an error or
+// panicked to the Redis list key, see Watcher.DeadLetters.
+func DeadLetterList(key string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.DeadLetterList = key
+	}
+}
+
+// CallbackRetry retries a failing update callback up to retries times,
+// waiting backoff before the first retry and doubling it up to maxBackoff
+// (0 for no limit). Messages that still fail go to the DeadLetterList.
+func CallbackRetry(retries int, backoff, maxBackoff time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.CallbackRetries = retries
+		options.CallbackRetryBackoff = backoff
+		options.CallbackRetryMaxBackoff = maxBackoff
+	}
+}
+
+// StalenessHandler calls onStale once the subscription has been down for
+// longer than threshold, letting the application switch the enforcer into a
+// fail-safe mode. onRecovered, if set, is called when the subscription is
+// re-established after that.
+func StalenessHandler(threshold time.Duration, onStale func(disconnected time.Duration), onRecovered func()) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.staleThreshold = threshold
+		options.staleCallback = onStale
+		options.staleRecoveredCallback = onRecovered
+	}
+}
+
+// StrictDelivery makes Update return ErrNoSubscribers when no client
+// received the message, re-publishing up to retries times after delay first.
+// This catches misconfigured channels early.
+func StrictDelivery(retries int, delay time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.StrictDelivery = true
+		options.StrictDeliveryRetries = retries
+		options.StrictDeliveryDelay = delay
+	}
+}
+
+// PublishDryRun logs every message that would be published, with its
+// channel, instead of sending it to Redis. logger defaults to the Logger.
+func PublishDryRun(logger func(channel, message string)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishDryRun = true
+		options.dryRunLogger = logger
+	}
+}
+
+// ChannelCheck queries PUBSUB NUMSUB shortly after startup and then every
+// interval, reporting an error through the SubscriptionFailureCallback when
+// fewer than minPeers other clients are subscribed to the channel. This
+// catches typos in channel names.
+func ChannelCheck(interval time.Duration, minPeers int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.channelCheckInterval = interval
+		options.channelCheckMinPeers = int64(minPeers)
+	}
+}
+
+// LatencyProbe publishes a probe every interval and records the time until
+// the watcher's own subscription receives it as ProbeLatencyMetric. Probes
+// not received within the interval are reported as errors.
+func LatencyProbe(interval time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.latencyProbeInterval = interval
+	}
+}
+
+// PublishDelay makes Update and Commit wait d before publishing, so peers
+// reading from replicated databases see the change once they reload.
+func PublishDelay(d time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishDelay = d
+	}
+}
+
+// BeforePublish sets a hook run by Update and Commit before publishing, e.g.
+// to wait until replicas have applied the write. An error aborts the publish.
+func BeforePublish(hook func() error) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.beforePublish = hook
+	}
+}
+
+// WaitForLSN sets a hook called before the update callback for messages
+// published with UpdateWithLSN; it should block until the local database
+// replica has reached lsn, ruling out reloads that still see old data.
+func WaitForLSN(wait func(lsn string) error) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.waitForLSN = wait
+	}
+}
+
+// ManualStart keeps the constructor from starting the subscription and the
+// other background goroutines; call Watcher.Start or Watcher.Run instead.
+func ManualStart(manual bool) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ManualStart = manual
+	}
+}
+
+// LazyConnect defers dialing Redis until the subscription starts or the first
+// message is published, so a watcher can be constructed while Redis is down.
+func LazyConnect(lazy bool) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.LazyConnect = lazy
+	}
+}
+
+// DrainTimeout sets how long Close waits for running update callbacks to
+// finish before closing the connections.
+func DrainTimeout(d time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.DrainTimeout = d
+	}
+}
+
+// ResyncOnSignal makes the watcher call Resync whenever the process receives
+// one of signals, SIGHUP when none are given. On platforms without SIGHUP,
+// such as js and plan9, it does nothing without signals.
+func ResyncOnSignal(signals ...os.Signal) WatcherOption {
+	return func(options *WatcherOptions) {
+		if len(signals) == 0 {
+			signals = defaultResyncSignals
+		}
+		options.resyncSignals = signals
+	}
+}
+
+// Hello makes the watcher announce itself with a hello message carrying its
+// LocalID, version and capabilities whenever it (re)subscribes, answer the
+// hello of joining watchers and say goodbye on Close. onHello, if set,
+// receives the hello messages of other watchers so fleets can track
+// membership and the formats in use, see also Peers. While a peer lacks a
+// capability, batches and snapshot updates are published as plain updates.
+func Hello(version string, onHello func(Message)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.helloVersion = version
+		options.helloCallback = onHello
+	}
+}
+
+// EarlyBufferSize sets how many messages received before SetUpdateCallback
+// are kept and replayed once a callback is set, 0 drops them.
+func EarlyBufferSize(size int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.EarlyBufferSize = size
+	}
+}
+
+// WithMiddleware wraps the handling of received updates, the first
+// middleware being the outermost.
+func WithMiddleware(middleware ...Middleware) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.middleware = append(options.middleware, middleware...)
+	}
+}
+
+// MessageFilter sets a predicate deciding whether a received message reaches
+// the update callback. It runs before squashing, retries and middleware, so
+// it should be cheap; ParseMessage gives access to structured messages.
+func MessageFilter(filter func(msg string) bool) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.messageFilter = filter
+	}
+}
+
+// CallbackWorkers sets how many workers run update callbacks, one by
+// default. Workers keep a slow LoadPolicy from holding up reading from Redis;
+// 0 runs callbacks inline in the receive loop. With more than one worker
+// callbacks may run concurrently and out of order.
+func CallbackWorkers(workers int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.CallbackWorkers = workers
+	}
+}
+
+// CallbackQueueSize sets how many updates may wait for a callback worker
+// before receiving blocks. Updates still queued on Close are dropped.
+func CallbackQueueSize(size int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.CallbackQueueSize = size
+	}
+}
+
+// ReceiveBufferSize lets the subscription read up to size messages from
+// Redis ahead of the processing loop, absorbing bursts at the cost of
+// memory. By default every message is handed over before the next is read.
+func ReceiveBufferSize(size int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ReceiveBufferSize = size
+	}
+}
+
+// ReceiveBatch makes each of the CallbackWorkers take up to max updates
+// waiting in the callback queue at once and run the callbacks once for them, like a
+// Debounce that never waits: the update callbacks get the last message, the
+// batch callback all of them. Routed channel messages are never merged.
+func ReceiveBatch(max int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ReceiveBatch = max
+	}
+}
+
+// SocketBuffers sets the kernel receive and send buffer sizes of the Redis
+// connections in bytes, 0 keeps the OS default for either.
+func SocketBuffers(read, write int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.SocketReadBuffer = read
+		options.SocketWriteBuffer = write
+	}
+}
+
+// CallbackContext sets the factory of the context the callbacks derive
+// theirs from, e.g. to carry tenant info, a logger or a deadline into the
+// LoadPolicy they run. It is called once per update; the CallbackTimeout and
+// Close still cancel the derived context.
+func CallbackContext(factory func() context.Context) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.contextFactory = factory
+	}
+}
+
+// PanicHandler sets a hook called with the message and the recovered value
+// when an update callback or middleware panics. Panics are recovered and
+// reported to the SubscriptionFailureCallback either way.
+func PanicHandler(handler func(msg string, recovered interface{})) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.panicHandler = handler
+	}
+}
+
+// CrashHandler sets a hook called when a background goroutine of the watcher
+// panics and is restarted, with the total number of crashes so far.
+func CrashHandler(handler func(err error, crashes int64)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.crashHandler = handler
+	}
+}
+
+// CallbackTimeout limits how long an update callback may run. The context
+// given to a SetUpdateCallbackWithContext callback is cancelled on expiry, and
+// delivery continues without waiting for a callback that ignores it.
+func CallbackTimeout(timeout time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.CallbackTimeout = timeout
+	}
+}
+
+// OrderedDelivery runs update callbacks strictly one at a time in arrival
+// order, for callbacks applying deltas to the model. It uses a single worker
+// whatever CallbackWorkers says, and a callback past its CallbackTimeout still
+// has to return before the next one starts.
+func OrderedDelivery(ordered bool) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.OrderedDelivery = ordered
+	}
+}
+
+// QueueOverflow sets what happens when the CallbackQueueSize is exceeded,
+// blocking by default. onDrop, if not nil, is called with every dropped update.
+func QueueOverflow(policy OverflowPolicy, onDrop func(dropped string)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.OverflowPolicy = policy
+		options.overflowCallback = onDrop
+	}
+}
+
+// Debounce collapses bursts of updates into a single callback with the last
+// of them. Unlike SquashMessages the window starts with the first update and
+// is not extended by later ones, so a steady stream still triggers a callback
+// every window.
+func Debounce(window time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.DebounceWindow = window
+	}
+}
+
+// PublishCoalesce buffers Update calls for window and publishes a single
+// message for all of them, for code mutating many rules in a loop. Publish
+// errors are then reported to the SubscriptionFailureCallback.
+func PublishCoalesce(window time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishCoalesce = window
+	}
+}
+
+// PublishRateLimit limits publishes to perSecond on average, allowing bursts
+// of up to burst messages. Publishes over the limit wait for their turn.
+func PublishRateLimit(perSecond float64, burst int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishRate = perSecond
+		options.PublishBurst = burst
+	}
+}
+
+// ReloadJitter delays every callback by a random time up to max, spreading
+// the policy reloads of many instances receiving the same message.
+func ReloadJitter(max time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ReloadJitter = max
+	}
+}
+
+// SlowCallback calls warn with the message and the time taken whenever an
+// update callback runs longer than threshold, to spot policy reloads that are
+// becoming a bottleneck.
+func SlowCallback(threshold time.Duration, warn func(msg string, elapsed time.Duration)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.slowCallbackThreshold = threshold
+		options.slowCallback = warn
+	}
+}
+
+// Expvar exports published, received, errors and reconnects counters and the
+// last publish and receive times as the expvar variable name, served on
+// /debug/vars.
+func Expvar(name string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ExpvarName = name
+	}
+}
+
+// WithLogger sends the log output of the watcher, such as subscription
+// failures, to logger instead of stdout. NoopLogger silences it.
+func WithLogger(logger Logger) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.Logger = logger
+	}
+}
+
+// WithLeveledLogger logs connection events, retries and message handling at
+// debug, info, warn or error level to logger, which then also receives what
+// would otherwise go to the Logger.
+func WithLeveledLogger(logger LeveledLogger) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.LeveledLogger = logger
+	}
+}
+
+// OnConnect sets a hook called each time the subscription connection to
+// Redis has been established.
+func OnConnect(hook func()) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.onConnect = hook
+	}
+}
+
+// OnDisconnect sets a hook called when an active subscription ends, with the
+// error that ended it, nil when leaving on request.
+func OnDisconnect(hook func(err error)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.onDisconnect = hook
+	}
+}
+
+// OnSubscribed sets a hook called when the watcher first subscribed to the
+// channel.
+func OnSubscribed(hook func()) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.onSubscribed = hook
+	}
+}
+
+// OnResubscribed sets a hook called whenever the watcher subscribed again
+// after losing its subscription, a good moment to resync the policy.
+func OnResubscribed(hook func()) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.onResubscribed = hook
+	}
+}
+
+// ErrorHistorySize sets how many recent errors Errors returns, 0 keeps none.
+func ErrorHistorySize(size int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ErrorHistorySize = size
+	}
+}
+
+// WithMetricsSink sends every metric to sink, in addition to a RecordMetrics
+// hook, and reports the queue and subscription gauges every interval.
+func WithMetricsSink(sink MetricsSink, interval time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.metricsSink = sink
+		options.metricsInterval = interval
+	}
+}
+
+// PublishStats publishes a StatsReport of the watcher on channel every
+// interval, so a central collector can follow every watcher of a fleet.
+func PublishStats(channel string, interval time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.statsChannel = channel
+		options.statsInterval = interval
+	}
+}
+
+// AuditLog appends every published and received message with its time and
+// outcome to the file at path as JSON lines. The file is rotated once it
+// reaches maxSize bytes, keeping maxBackups older files as path.1, path.2 and
+// so on; a maxSize of 0 never rotates.
+func AuditLog(path string, maxSize int64, maxBackups int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.auditPath = path
+		options.auditMaxSize = maxSize
+		options.auditMaxBackups = maxBackups
+	}
+}
+
+// AuditStream mirrors every published update with its sender, host and type
+// into the Redis stream at key, read back by AuditTrail and ReplayAudit. The
+// stream is trimmed to about maxLen entries, 0 keeps every entry.
+func AuditStream(key string, maxLen int64) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.auditStream = key
+		options.auditStreamMaxLen = maxLen
+	}
+}
+
+// StatusChannel makes the watcher answer status requests, see
+// RequestStatus, with a StatusResponse published on channel.
+func StatusChannel(channel string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.statusChannel = channel
+	}
+}
+
+// Domains subscribes the watcher to the channels of domains in addition to
+// the watcher channel, receiving their UpdateForDomain updates. Updates of
+// other domains are not received.
+func Domains(domains ...string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.domains = domains
+	}
+}
+
+// SubscribePatterns also subscribes the watcher to the channels matching the
+// glob patterns, e.g. "/casbin/*" for the updates of every domain. A channel
+// matching the watcher channel as well delivers its messages twice.
+func SubscribePatterns(patterns ...string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.patterns = patterns
+	}
+}
+
+// Channels subscribes the watcher to further channels. Their messages go to
+// the update callback, or to the callback set by SetChannelCallback.
+func Channels(channels ...string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.channels = channels
+	}
+}
+
+// ChannelPrefix puts every channel of the watcher, including those of
+// domains and patterns, behind prefix, e.g. "prod:" or "staging:", so
+// environments sharing a Redis server do not see each other's updates.
+func ChannelPrefix(prefix string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ChannelPrefix = prefix
+	}
+}
+
+// ChannelResolver sets the function mapping a tenant, or domain, to its
+// channel for UpdateForDomain, Domains and SubscribeDomain, replacing the
+// default of appending it to the watcher channel. A ChannelPrefix is put in
+// front of the resolved channel.
+func ChannelResolver(resolve func(tenantID string) string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.channelResolver = resolve
+	}
+}
+
+// PublishOnly makes NewWatcher return a watcher that only publishes, like
+// NewPublishWatcher, without dialing a subscription connection or starting
+// the subscription goroutines. Meant for admin tools and CLIs.
+func PublishOnly(publishOnly bool) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishOnly = publishOnly
+	}
+}
+
+// SubscribeOnly makes the watcher receive-only, e.g. for read-only replicas
+// that must never announce changes: no publish connection is dialed and Update
+// and the other publishing methods fail with ErrSubscribeOnly. Features
+// writing to Redis, such as the DeadLetterList, are unavailable as well.
+func SubscribeOnly(subscribeOnly bool) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.SubscribeOnly = subscribeOnly
+	}
+}
+
+// SubscriptionShards spreads the subscribed channels over n connections,
+// each with its own receive goroutine, to stay below per-connection limits
+// when watching many channels. Channels are assigned by hash; the watcher
+// channel and patterns stay on the first connection.
+func SubscriptionShards(n int) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.SubscriptionShards = n
+	}
+}
+
+// FederatedServers subscribes the channels and patterns on further,
+// independent Redis servers as well, merging their updates with those of the
+// watcher address, e.g. while publishers migrate from one broker to another.
+// Updates are still published on the watcher address only.
+func FederatedServers(addrs ...string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.FederatedServers = addrs
+	}
+}
+
+// InstanceGroups puts the watcher in groups, so updates published with
+// UpdateForGroups for one of them reach it. Updates for other groups are
+// ignored; untargeted updates are always received.
+func InstanceGroups(groups ...string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.InstanceGroups = groups
+	}
+}
+
+// DualPublish publishes every update on the watcher channel on channel as
+// well, e.g. the old one while watchers are migrated to a new channel name.
+// convert, if not nil, rewrites the message for that channel, so a new
+// payload format can be introduced the same way. Pass channel to Channels to
+// keep receiving updates published there by watchers not yet migrated.
+func DualPublish(channel string, convert func(msg string) string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.dualChannel = channel
+		options.dualConvert = convert
+	}
+}
+
+// ReceiveDryRun receives, decodes and meters updates as usual, running the
+// middleware, but logs them with their channel instead of invoking the
+// callbacks, to soak-test new message formats in production. A structured
+// message that cannot be decoded fails like a callback would. logger
+// defaults to the Logger.
+func ReceiveDryRun(logger func(channel, message string)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ReceiveDryRun = true
+		options.receiveDryRunLogger = logger
+	}
+}
+
+// PresenceRegistry makes the watcher keep a heartbeat entry with its
+// LocalID, host, Hello version and last-seen time in the Redis hash key,
+// refreshed every interval and removed on Close, so Presence can tell
+// whether every enforcer is listening.
+func PresenceRegistry(key string, interval time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.presenceKey = key
+		options.presenceInterval = interval
+	}
+}
+
+// LeaderElection elects one leader among the watchers sharing key, holding
+// it for ttl and renewing it every third of ttl. Only the leader publishes
+// the updates of Update and similar methods, the others drop them, so
+// replicas all reacting to the same admin event send one notification.
+// onLeaderChange, if set, is called when the watcher gains or loses
+// leadership, e.g. to enable auto-save on the leader only; see IsLeader.
+func LeaderElection(key string, ttl time.Duration, onLeaderChange func(leader bool)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.leaderKey = key
+		options.leaderTTL = ttl
+		options.onLeaderChange = onLeaderChange
+	}
+}
+
+// PolicyLockKey sets the Redis key of the lock taken by AcquirePolicyLock,
+// by default the watcher channel followed by ":lock".
+func PolicyLockKey(key string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.policyLockKey = key
+	}
+}
+
+// DelegatedReload makes the watchers sharing key elect a loader, like
+// LeaderElection does with ttl, so that a change costs one database reload
+// instead of one per node. The loader answers every update, including its
+// own Update, with load, e.g. LoadPolicy followed by serializing the model,
+// and broadcasts the result as a snapshot; the others hand the snapshots
+// they receive to apply and don't touch the database. load and apply run in
+// place of the update callbacks, with their queue, CallbackTimeout and
+// panic recovery. A node reloads with load itself while no loader holds
+// key, on Resync, when a snapshot expired and when the loader had to send
+// a plain update because a peer lacks snapshots, see Hello. Snapshots
+// are stored under key followed by ":snapshot" unless PolicySnapshots sets
+// another key. ttl must be positive.
+func DelegatedReload(key string, ttl time.Duration, load func() ([]byte, error), apply func(policy []byte) error) WatcherOption {
+	return func(options *WatcherOptions) {
+		if ttl <= 0 {
+			options.invalid(fmt.Errorf("rediswatcher: DelegatedReload needs a positive ttl, got %v", ttl))
+			return
+		}
+		options.delegateKey = key
+		options.delegateTTL = ttl
+		options.delegateLoad = load
+		options.delegateApply = apply
+		if options.snapshotKey == "" {
+			options.snapshotKey = key + ":snapshot"
+		}
+	}
+}
+
+// PolicySnapshots stores the snapshots of UpdateWithSnapshot under key
+// followed by their version, each kept for ttl. With 0 only the latest one
+// is kept, the previous one for another minute.
+func PolicySnapshots(key string, ttl time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.snapshotKey = key
+		options.snapshotTTL = ttl
+	}
+}
+
+// PolicyProvider lets the watcher answer FetchPolicy requests of its peers
+// with the policy returned by provider, serialized the way the requesting
+// nodes expect, e.g. the rules of the enforcer model.
+func PolicyProvider(provider func() ([]byte, error)) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.policyProvider = provider
+	}
+}
+
+// WarmUp brings a starting watcher up to date before it is Ready: load
+// receives the LatestSnapshot and, without one, the updates of the last
+// replay in the AuditStream run the update callback, see ReplayAudit. A
+// failed warm-up is reported; the watcher becomes Ready regardless.
+func WarmUp(load func(snapshot []byte) error, replay time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.warmUp = load
+		options.warmUpReplay = replay
+	}
+}
+
+// PeriodicReload runs the update callback with a MessageTypeReload message
+// whenever interval passed without a successful one, bounding staleness
+// even if every notification is lost. The reload goes through squashing,
+// debouncing and pausing like a received update. It is disabled by default.
+func PeriodicReload(interval time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ReloadInterval = interval
+	}
+}
+
+// MaintenanceWindows defers callbacks during windows, see
+// ScheduleMaintenance.
+func MaintenanceWindows(windows ...MaintenanceWindow) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.maintenance = windows
+	}
+}
+
+// TrustedPublishers makes the watcher ignore messages from senders whose
+// LocalID is not in ids, limiting the damage a rogue client with access to
+// the channel can do. The watcher's own messages are always accepted. Sender
+// IDs are not authenticated; combine the allow-list with Redis ACLs.
+func TrustedPublishers(ids ...string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.TrustedPublishers = ids
+	}
+}
+
+// PreflightCheck makes the constructor verify with ACL DRYRUN that the
+// Redis user may publish on the watcher channel and subscribe to its
+// channels and patterns, failing with ErrNoPermission instead of silently
+// never receiving updates. Servers without ACL DRYRUN are probed with a
+// publish; servers without ACLs pass. It has no effect with LazyConnect.
+func PreflightCheck(check bool) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PreflightCheck = check
+	}
+}
+
+// Timestamps stamps every structured message with the time of the Redis
+// server, as well as the sender clock, so receivers can tell its age with
+// MessageAge even if host clocks are skewed. It costs a TIME round trip per
+// message.
+func Timestamps(stamp bool) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.Timestamps = stamp
+	}
+}
+
+// PublishFormat encodes the structured messages of Update and similar
+// methods with the codec registered for format, see RegisterCodec, while
+// messages in every registered format keep being received.
+func PublishFormat(format string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishFormat = format
+	}
+}
+
+// Scheduler keeps the updates of ScheduleUpdate in the Redis sorted set key
+// and checks every interval for due ones to publish. Every watcher with the
+// same key may run the loop, each update is published once.
+func Scheduler(key string, interval time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.scheduleKey = key
+		options.scheduleInterval = interval
+	}
+}
+
+// Webhook POSTs every received update as a JSON Event to url, e.g. to feed
+// audit systems or chat rooms. A non-empty secret signs the body with
+// HMAC-SHA256 in the X-Rediswatcher-Signature header as "sha256=<hex>".
+// Failed deliveries are retried with backoff; the option can be repeated.
+func Webhook(url, secret string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.webhooks = append(options.webhooks, webhook{url: url, secret: secret})
+	}
+}
+
+// TLSConfig connects to Redis over TLS with config.
+func TLSConfig(config *tls.Config) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.TLSConfig = config
+	}
+}
+
+// DialTimeout limits the time to establish a Redis connection.
+func DialTimeout(d time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.DialTimeout = d
+	}
+}
+
+// PublishIdleTimeout re-dials the publish connection before its next
+// command once it has been idle for d, for networks whose NATs or load
+// balancers silently drop quiet flows and would fail the first Update after
+// a pause. It has no effect on a connection given by WithRedisPubConnection.
+func PublishIdleTimeout(d time.Duration) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishIdleTimeout = d
+	}
+}
+
+// ReconnectStrategy sets the waits between the attempts to restore a lost
+// subscription, in place of the constant ResubscribeThreshold.
+func ReconnectStrategy(strategy RetryStrategy) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ReconnectStrategy = strategy
+	}
+}
+
+// PublishRetryStrategy sets the waits between the re-publishes of
+// StrictDelivery, in place of its constant delay.
+func PublishRetryStrategy(strategy RetryStrategy) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.PublishRetryStrategy = strategy
+	}
+}
+
+// ConnectRetry makes the constructor keep retrying the initial connection
+// for up to timeout instead of failing at once, for deployments where Redis
+// may come up after the application. The waits follow strategy, or an
+// exponential backoff from 100ms to 5s when it is nil.
+func ConnectRetry(timeout time.Duration, strategy RetryStrategy) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.ConnectRetryTimeout = timeout
+		options.ConnectRetryStrategy = strategy
+	}
+}
+
 // IsCallbackPending
 func IsCallbackPending(w *Watcher, shouldClear bool) bool {
-	r := w.options.callbackPending
 	if shouldClear {
-		w.options.callbackPending = false
+		return atomic.SwapInt32(&w.squashed, 0) == 1
 	}
-	return r
+	return atomic.LoadInt32(&w.squashed) == 1
 }
@@ -70,3 +70,58 @@ func (o *WatcherOptions) optionBuilder(setters ...WatcherOption) {
 		setter(o)
 	}
 }
+
+func TestPublishDryRun(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+
+	var channel, message string
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), PublishDryRun(func(c, m string) {
+			channel, message = c, m
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	publish := pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+	if pub.Stats(publish) != 0 {
+		t.Fatal("Dry run must not publish")
+	}
+	if channel != "/casbin" || message != "node1" {
+		t.Fatalf("Dry run should log '/casbin' 'node1', logged '%s' '%s' instead", channel, message)
+	}
+}
+
+func TestPublishDelay(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+
+	hookErr := fmt.Errorf("replica lagging")
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), PublishDelay(20*time.Millisecond), BeforePublish(func() error {
+			return hookErr
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	startTime := time.Now()
+	if err := w.Update(); err != hookErr {
+		t.Fatalf("Update should return the hook error, got %v", err)
+	}
+	if time.Since(startTime) < 20*time.Millisecond {
+		t.Fatal("Update did not wait for the publish delay")
+	}
+
+	hookErr = nil
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+}
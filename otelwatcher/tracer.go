@@ -0,0 +1,118 @@
+// Package otelwatcher records the work of a rediswatcher.Watcher as
+// OpenTelemetry spans.
+//
+//	t := otelwatcher.NewTracer(otelwatcher.WithTracerProvider(tp))
+//	w, _ := rediswatcher.NewWatcher(addr, rediswatcher.RecordMetrics(t.Record))
+package otelwatcher
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+const instrumentationName = "github.com/lutomas/casbin-redis-watcher/v2/otelwatcher"
+
+// Tracer turns watcher metrics into spans.
+type Tracer struct {
+	tracer trace.Tracer
+	spans  map[string]string
+}
+
+// Option configures a Tracer.
+type Option func(*config)
+
+type config struct {
+	provider trace.TracerProvider
+	all      bool
+}
+
+// WithTracerProvider sets the provider of the tracer, the global one by
+// default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = provider
+	}
+}
+
+// WithAllOperations also traces connection handling such as dials, AUTH
+// and SUBSCRIBE, not just publishing, receiving and callbacks.
+func WithAllOperations() Option {
+	return func(c *config) {
+		c.all = true
+	}
+}
+
+// NewTracer creates a Tracer.
+func NewTracer(opts ...Option) *Tracer {
+	c := config{provider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	t := &Tracer{
+		tracer: c.provider.Tracer(instrumentationName),
+		spans: map[string]string{
+			rediswatcher.PubSubPublishMetric: "rediswatcher.publish",
+			rediswatcher.PubSubReceiveMetric: "rediswatcher.receive",
+			rediswatcher.CallbackMetric:      "rediswatcher.callback",
+		},
+	}
+	if c.all {
+		for _, name := range []string{
+			rediswatcher.RedisDialMetric, rediswatcher.RedisDoAuthMetric, rediswatcher.RedisCloseMetric,
+			rediswatcher.PubSubSubscribeMetric, rediswatcher.PubSubUnsubscribeMetric,
+			rediswatcher.PublishQueuePushMetric, rediswatcher.PublishQueueFlushMetric,
+			rediswatcher.OutboxRelayMetric, rediswatcher.DeadLetterMetric, rediswatcher.CallbackRetryMetric,
+		} {
+			t.spans[name] = "rediswatcher." + name
+		}
+	}
+	return t
+}
+
+// Record emits a span for m, backdated by its latency. Pass it to
+// rediswatcher.RecordMetrics.
+func (t *Tracer) Record(m *rediswatcher.WatcherMetrics) {
+	name, ok := t.spans[m.Name]
+	if !ok {
+		return
+	}
+	if m.Name == rediswatcher.PubSubReceiveMetric && m.Error == nil && m.MessageSize == 0 {
+		return // subscription confirmations, not messages
+	}
+
+	end := time.Now()
+	start := end.Add(-time.Duration(m.LatencyMs * float64(time.Millisecond)))
+	kind := trace.SpanKindInternal
+	switch m.Name {
+	case rediswatcher.PubSubPublishMetric:
+		kind = trace.SpanKindProducer
+	case rediswatcher.PubSubReceiveMetric:
+		kind = trace.SpanKindConsumer
+	}
+
+	_, span := t.tracer.Start(context.Background(), name, trace.WithTimestamp(start), trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", m.Channel),
+			attribute.String("rediswatcher.local_id", m.LocalID),
+		))
+	if m.MessageSize > 0 {
+		span.SetAttributes(attribute.Int64("messaging.message.body.size", m.MessageSize))
+	}
+	if m.Receivers >= 0 && m.Name == rediswatcher.PubSubPublishMetric && m.Error == nil {
+		span.SetAttributes(attribute.Int64("rediswatcher.receivers", m.Receivers))
+	}
+	if m.Error != nil {
+		span.RecordError(m.Error)
+		span.SetStatus(codes.Error, m.Error.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
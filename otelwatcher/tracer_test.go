@@ -0,0 +1,33 @@
+package otelwatcher
+
+import (
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := NewTracer(WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+
+	tracer.Record(&rediswatcher.WatcherMetrics{Name: rediswatcher.PubSubPublishMetric, LatencyMs: 3, Channel: "/casbin", Receivers: 2})
+	tracer.Record(&rediswatcher.WatcherMetrics{Name: rediswatcher.PubSubReceiveMetric}) // subscription confirmation
+	tracer.Record(&rediswatcher.WatcherMetrics{Name: rediswatcher.RedisDialMetric})
+	tracer.Record(&rediswatcher.WatcherMetrics{Name: rediswatcher.CallbackMetric, Error: errors.New("load failed")})
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected publish and callback spans, got %d", len(spans))
+	}
+	if spans[0].Name() != "rediswatcher.publish" || spans[0].EndTime().Sub(spans[0].StartTime()) <= 0 {
+		t.Fatalf("Unexpected publish span %s from %v to %v", spans[0].Name(), spans[0].StartTime(), spans[0].EndTime())
+	}
+	if spans[1].Name() != "rediswatcher.callback" || spans[1].Status().Code != codes.Error {
+		t.Fatalf("Failed callback should be an error span, got %s %v", spans[1].Name(), spans[1].Status())
+	}
+}
@@ -0,0 +1,98 @@
+package rediswatcher
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEntry is a watcher message waiting to be published from an outbox.
+type OutboxEntry struct {
+	ID      string
+	Message string
+}
+
+// Outbox stores watcher messages written in the same transaction as the
+// policy change (the transactional outbox pattern), so peers are only told
+// to reload once the change is committed and visible.
+type Outbox interface {
+	// Pending returns up to limit committed entries, oldest first.
+	Pending(limit int) ([]OutboxEntry, error)
+	// Remove deletes entries that have been published.
+	Remove(ids ...string) error
+}
+
+const defaultOutboxBatchSize = 100
+
+// UpdateInTx builds the update message and hands it to enqueue, which should
+// write it to the outbox as part of the adapter transaction. The outbox relay
+// publishes it after the transaction commits.
+//
+//	Example:
+//			err := w.UpdateInTx(func(e rediswatcher.OutboxEntry) error {
+//				_, err := tx.Exec("INSERT INTO casbin_outbox (id, message) VALUES (?, ?)", e.ID, e.Message)
+//				return err
+//			})
+func (w *Watcher) UpdateInTx(enqueue func(OutboxEntry) error) error {
+	return enqueue(OutboxEntry{
+		ID:      uuid.New().String(),
+		Message: w.options.LocalID,
+	})
+}
+
+func (w *Watcher) startOutboxRelay() {
+	if w.options.Outbox == nil {
+		return
+	}
+
+	w.spawn(func() {
+		ticker := w.clock().NewTicker(w.options.OutboxInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+				if err := w.relayOutbox(); err != nil {
+					w.reportError(err)
+				}
+			}
+		}
+	})
+}
+
+// relayOutbox publishes pending outbox entries and removes the ones that
+// were sent.
+func (w *Watcher) relayOutbox() error {
+	startTime := time.Now()
+	entries, err := w.options.Outbox.Pending(defaultOutboxBatchSize)
+	if err != nil {
+		if w.options.RecordMetrics != nil {
+			w.options.RecordMetrics(w.createMetrics(OutboxRelayMetric, startTime, err))
+		}
+		return err
+	}
+
+	msgs := make([]string, len(entries))
+	for i, e := range entries {
+		msgs[i] = e.Message
+	}
+	w.pubMu.Lock()
+	sent, err := w.publishOrQueueAll(nil, msgs)
+	w.pubMu.Unlock()
+
+	var published []string
+	for _, e := range entries[:sent] {
+		published = append(published, e.ID)
+	}
+
+	if len(published) > 0 {
+		if rmErr := w.options.Outbox.Remove(published...); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}
+	if w.options.RecordMetrics != nil {
+		w.options.RecordMetrics(w.createMetrics(OutboxRelayMetric, startTime, err))
+	}
+	return err
+}
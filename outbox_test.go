@@ -0,0 +1,100 @@
+package rediswatcher
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testOutbox struct {
+	entries []OutboxEntry
+	err     error // returned by Pending
+}
+
+func (o *testOutbox) Pending(limit int) ([]OutboxEntry, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(o.entries) > limit {
+		return o.entries[:limit], nil
+	}
+	return o.entries, nil
+}
+
+func (o *testOutbox) Remove(ids ...string) error {
+	for _, id := range ids {
+		for i, e := range o.entries {
+			if e.ID == id {
+				o.entries = append(o.entries[:i], o.entries[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func TestOutboxRelay(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	outbox := &testOutbox{}
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), OutboxRelay(outbox, time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.UpdateInTx(func(e OutboxEntry) error {
+		outbox.entries = append(outbox.entries, e)
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed watcher.UpdateInTx(): %v", err)
+	}
+	if len(outbox.entries) != 1 || outbox.entries[0].Message != "node1" || outbox.entries[0].ID == "" {
+		t.Fatalf("Unexpected outbox entries: %v", outbox.entries)
+	}
+
+	publish := pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+	if err := w.relayOutbox(); err != nil {
+		t.Fatalf("Failed to relay outbox: %v", err)
+	}
+	if pub.Stats(publish) != 1 {
+		t.Fatal("Outbox entry was not published")
+	}
+	if len(outbox.entries) != 0 {
+		t.Fatalf("Published entries should be removed, %d left", len(outbox.entries))
+	}
+}
+
+func TestOutboxRelayReportsErrors(t *testing.T) {
+	failure := errors.New("outbox unavailable")
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(NewTestConn()), WithRedisSubConnection(NewTestConn()),
+		ManualStart(true), OutboxRelay(&testOutbox{err: failure}, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	reported := make(chan error, 16)
+	w.SetErrorCallback(func(err error) {
+		select {
+		case reported <- err:
+		default:
+		}
+	})
+	if err := w.Start(); err != nil {
+		t.Fatalf("Failed watcher.Start(): %v", err)
+	}
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case err := <-reported:
+			if err == failure {
+				return
+			}
+		case <-timeout:
+			t.Fatal("The relay should report outbox errors")
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package rediswatcher
+
+import (
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestSubscribePatterns(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		SubscribePatterns("/casbin/*"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	w.messagesIn = make(chan redis.Message, 1)
+
+	sub.Clear()
+	sub.Command("SUBSCRIBE", "/casbin").Expect([]interface{}{[]byte("subscribe"), []byte("/casbin"), []byte("1")})
+	sub.Command("PSUBSCRIBE", "/casbin/*").Expect([]interface{}{[]byte("pmessage"), []byte("/casbin/*"), []byte("/casbin/tenant1"), []byte("node2")})
+	w.subscribeOnce()
+
+	select {
+	case msg := <-w.messagesIn:
+		if msg.Channel != "/casbin/tenant1" || string(msg.Data) != "node2" {
+			t.Fatalf("Unexpected message %+v", msg)
+		}
+	default:
+		t.Fatal("Pattern messages should be received")
+	}
+}
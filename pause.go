@@ -0,0 +1,75 @@
+package rediswatcher
+
+// pauseState holds the updates missed while paused, guarded by pauseMu.
+type pauseState struct {
+	paused bool
+	quiet  bool // inside a maintenance window
+	missed int
+	last   job
+}
+
+// Pause stops invoking the update callback, e.g. during a bulk data
+// migration. Updates keep being received and counted; Resume catches up.
+func (w *Watcher) Pause() {
+	w.pauseMu.Lock()
+	w.pause.paused = true
+	w.pauseMu.Unlock()
+}
+
+// Resume invokes the update callback again. When updates were missed while
+// paused the last of them is handed to the callback workers, and the number
+// of missed updates is returned. Inside a maintenance window the catch-up waits
+// for the window to end.
+func (w *Watcher) Resume() int {
+	return w.unpause(func(p *pauseState) { p.paused = false })
+}
+
+// unpause applies clear and catches up once nothing holds callbacks back
+// any more, returning the number of missed updates.
+func (w *Watcher) unpause(clear func(*pauseState)) int {
+	w.pauseMu.Lock()
+	clear(&w.pause)
+	missed, last := w.pause.missed, w.pause.last
+	if w.pause.paused || w.pause.quiet {
+		w.pauseMu.Unlock()
+		return missed
+	}
+	w.pause = pauseState{}
+	w.pauseMu.Unlock()
+
+	if missed > 0 && w.hasCallback() {
+		w.dispatchJob(last)
+	}
+	return missed
+}
+
+// Paused reports whether callbacks are paused, by Pause or a maintenance
+// window.
+func (w *Watcher) Paused() bool {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+	return w.pause.paused || w.pause.quiet
+}
+
+// deliver hands a received update to the callback unless paused.
+func (w *Watcher) deliver(data string) {
+	w.deliverJob(singleJob(data))
+}
+
+// deliverJob works like deliver, keeping the Delivery of j.
+func (w *Watcher) deliverJob(j job) {
+	w.pauseMu.Lock()
+	if w.pause.paused || w.pause.quiet {
+		w.pause.missed++
+		w.pause.last = j
+		w.pauseMu.Unlock()
+		return
+	}
+	w.pauseMu.Unlock()
+
+	if w.debounceWindow() > 0 {
+		w.debounce(j.msg, j.delivery)
+		return
+	}
+	w.dispatchJob(j)
+}
@@ -0,0 +1,77 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPauseResume(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	var received []string
+	w.SetUpdateCallback(func(msg string) {
+		received = append(received, msg)
+	})
+
+	w.Pause()
+	if !w.Paused() {
+		t.Fatal("Watcher should be paused")
+	}
+	w.deliver("node2")
+	w.deliver("node3")
+	if len(received) != 0 {
+		t.Fatalf("No callbacks expected while paused, received %v", received)
+	}
+
+	if missed := w.Resume(); missed != 2 {
+		t.Fatalf("Resume should report 2 missed updates, reported %d", missed)
+	}
+	if len(received) != 1 || received[0] != "node3" {
+		t.Fatalf("Resume should catch up once with the last update, received %v", received)
+	}
+
+	w.deliver("node4")
+	if len(received) != 2 {
+		t.Fatal("Callbacks should run again after Resume")
+	}
+	if missed := w.Resume(); missed != 0 {
+		t.Fatalf("Nothing missed, Resume reported %d", missed)
+	}
+}
+
+func TestResumeQueuesCatchUp(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	CallbackWorkers(1)(&w.options)
+	CallbackQueueSize(1)(&w.options)
+	w.startCallbackWorkers()
+	defer close(w.closed)
+
+	release := make(chan struct{})
+	received := make(chan string, 1)
+	w.SetUpdateCallback(func(msg string) {
+		<-release
+		received <- msg
+	})
+
+	w.Pause()
+	w.deliver("node2")
+	returned := make(chan int)
+	go func() { returned <- w.Resume() }()
+	select {
+	case missed := <-returned:
+		if missed != 1 {
+			t.Fatalf("Resume should report 1 missed update, reported %d", missed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Resume should not wait for the catch-up callback")
+	}
+
+	close(release)
+	select {
+	case msg := <-received:
+		if msg != "node2" {
+			t.Fatalf("The catch-up should run with node2, received %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The catch-up should run on the workers")
+	}
+}
@@ -0,0 +1,25 @@
+package rediswatcher
+
+import "context"
+
+// publishPayload publishes raw, the JSON of an application payload, as a
+// message of kind.
+func (w *Watcher) publishPayload(kind string, raw []byte) error {
+	if w.options.SubscribeOnly {
+		return ErrSubscribeOnly
+	}
+	return w.publishMessage(Message{Type: MessageTypePayload, ID: w.options.LocalID, Kind: kind, Payload: raw})
+}
+
+// addPayloadCallback registers callback for the payload messages of kind,
+// like AddCallback. Its failures count as callback failures, so they are
+// retried and dead-lettered as configured.
+func (w *Watcher) addPayloadCallback(kind string, callback func(ctx context.Context, raw []byte) error) CallbackHandle {
+	return w.addCallback(func(ctx context.Context, msg string) error {
+		m, ok := decodeMessage(msg)
+		if !ok || m.Type != MessageTypePayload || m.Kind != kind {
+			return nil
+		}
+		return callback(ctx, m.Payload)
+	})
+}
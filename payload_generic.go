@@ -0,0 +1,36 @@
+//go:build go1.18
+// +build go1.18
+
+package rediswatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PublishPayload publishes payload, encoded as JSON, to the watchers that
+// registered kind with RegisterPayload. Like every message it also reaches
+// their update callback and AddCallback callbacks, which can tell it apart
+// with ParseMessage.
+func PublishPayload[T any](w *Watcher, kind string, payload T) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("rediswatcher: encoding %s payload: %v", kind, err)
+	}
+	return w.publishPayload(kind, raw)
+}
+
+// RegisterPayload calls callback with the decoded payload of every message
+// of kind published with PublishPayload, so callbacks get typed values
+// instead of decoding strings. A payload that does not decode into T fails
+// like a failing callback. Remove the callback with RemoveCallback.
+func RegisterPayload[T any](w *Watcher, kind string, callback func(ctx context.Context, payload T) error) CallbackHandle {
+	return w.addPayloadCallback(kind, func(ctx context.Context, raw []byte) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("rediswatcher: decoding %s payload: %v", kind, err)
+		}
+		return callback(ctx, payload)
+	})
+}
@@ -0,0 +1,51 @@
+//go:build go1.18
+// +build go1.18
+
+package rediswatcher
+
+import (
+	"context"
+	"testing"
+)
+
+type roleChange struct {
+	Role  string   `json:"role"`
+	Users []string `json:"users"`
+}
+
+func TestTypedPayload(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(NewTestConn()), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := PublishPayload(w, "roles", roleChange{Role: "admin", Users: []string{"alice"}}); err != nil {
+		t.Fatalf("Failed PublishPayload(): %v", err)
+	}
+	msg := pub.calls("PUBLISH")[0][1].(string)
+	if msg != `{"type":"payload","id":"node1","kind":"roles","payload":{"role":"admin","users":["alice"]}}` {
+		t.Fatalf("Unexpected payload message %s", msg)
+	}
+
+	var got []roleChange
+	RegisterPayload(w, "roles", func(_ context.Context, c roleChange) error {
+		got = append(got, c)
+		return nil
+	})
+	callback := w.getCallback()
+	if err := callback(context.Background(), msg); err != nil {
+		t.Fatalf("Failed payload callback: %v", err)
+	}
+	callback(context.Background(), `{"type":"payload","id":"node2","kind":"other","payload":{}}`)
+	callback(context.Background(), "node2")
+	if len(got) != 1 || got[0].Role != "admin" || got[0].Users[0] != "alice" {
+		t.Fatalf("Only payloads of the registered kind should be delivered, got %+v", got)
+	}
+
+	if err := callback(context.Background(), `{"type":"payload","id":"node2","kind":"roles","payload":{"role":1}}`); err == nil {
+		t.Fatal("A payload not decoding into the type should fail")
+	}
+}
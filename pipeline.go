@@ -0,0 +1,184 @@
+package rediswatcher
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// queueFlushBatch bounds the messages taken from the PublishQueue per
+// pipelined round trip.
+const queueFlushBatch = 100
+
+// sendPipelined sends PUBLISH for the n channel and message pairs of next
+// before reading the replies, recording a PubSubPublishMetric for each. It
+// returns how many were acknowledged, in order.
+func (w *Watcher) sendPipelined(c redis.Conn, n int, next func(i int) (channel, msg string)) (int, error) {
+	startTime := time.Now()
+	msgs := make([]string, n)
+	var err error
+	for i := 0; i < n && err == nil; i++ {
+		var channel string
+		channel, msgs[i] = next(i)
+		err = c.Send("PUBLISH", channel, msgs[i])
+	}
+	if err == nil {
+		err = c.Flush()
+	}
+
+	var acked int
+	for ; acked < n && err == nil; acked++ {
+		var receivers int64
+		if receivers, err = redis.Int64(c.Receive()); err != nil {
+			break
+		}
+		w.countPublished()
+		if w.options.RecordMetrics != nil {
+			watcherMetrics := w.createMetrics(PubSubPublishMetric, startTime, nil)
+			watcherMetrics.MessageSize = int64(len(msgs[acked]))
+			watcherMetrics.Receivers = receivers
+			w.options.RecordMetrics(watcherMetrics)
+		}
+	}
+	w.setPublishFailing(err != nil)
+	if err != nil && w.options.RecordMetrics != nil {
+		w.options.RecordMetrics(w.createMetrics(PubSubPublishMetric, startTime, err))
+	}
+	return acked, err
+}
+
+// canPipeline reports whether publishes may go out together. StrictDelivery,
+// a PublishRateLimit, DualPublish, injected faults, dry runs and the
+// deadline of UpdateWithContext decide message by message.
+func (w *Watcher) canPipeline() bool {
+	o := &w.options
+	return !o.StrictDelivery && o.PublishRate <= 0 && o.dualChannel == "" &&
+		o.faults == nil && !o.PublishDryRun && w.pubDeadline.IsZero()
+}
+
+// publishBurst publishes msgs in one pipelined round trip where possible,
+// one by one otherwise, and returns how many went out before the first
+// failure. Message i goes to channels[i], or to the publish channel when
+// channels is nil. Callers must hold pubMu.
+func (w *Watcher) publishBurst(channels, msgs []string) (int, error) {
+	if len(msgs) < 2 || !w.canPipeline() {
+		for i, msg := range msgs {
+			if err := w.publishOn(channels, i, msg, w.publish); err != nil {
+				return i, err
+			}
+		}
+		return len(msgs), nil
+	}
+
+	c, err := w.publisher()
+	if err != nil {
+		w.setPublishFailing(true)
+		return 0, wrapError(ErrPublishFailed, err)
+	}
+	channel := w.publishChannel()
+	acked, err := w.sendPipelined(c, len(msgs), func(i int) (string, string) {
+		if channels != nil {
+			return channels[i], msgs[i]
+		}
+		return channel, msgs[i]
+	})
+	return acked, wrapError(ErrPublishFailed, err)
+}
+
+// publishOrQueueAll works like publishOrQueue for several messages,
+// pipelining their publishes. Messages left over by a failed burst go
+// through publishOrQueue one by one, so they are queued or reported as
+// usual. Channels are those of publishBurst. It returns how many were
+// handled. Callers must hold pubMu.
+func (w *Watcher) publishOrQueueAll(channels, msgs []string) (int, error) {
+	if w.options.SubscribeOnly {
+		return 0, ErrSubscribeOnly
+	}
+	if len(msgs) < 2 {
+		for i, msg := range msgs {
+			if err := w.publishOn(channels, i, msg, w.publishOrQueue); err != nil {
+				return i, err
+			}
+		}
+		return len(msgs), nil
+	}
+
+	var sent int
+	err := w.flushQueue()
+	if err == nil {
+		sent, err = w.publishBurst(channels, msgs)
+	}
+	for _, msg := range msgs[:sent] {
+		w.recordAudit(msg)
+		w.auditMessage("publish", msg, nil)
+	}
+	if err == nil {
+		return sent, nil
+	}
+	for i := sent; i < len(msgs); i++ {
+		if err := w.publishOn(channels, i, msgs[i], w.publishOrQueue); err != nil {
+			return i, err
+		}
+	}
+	return len(msgs), nil
+}
+
+// publishOn runs publish for msg on channels[i], or on the publish channel
+// when channels is nil. The watcher channel is published on as usual, so it
+// gets the DualPublish too. Callers must hold pubMu.
+func (w *Watcher) publishOn(channels []string, i int, msg string, publish func(string) error) error {
+	if channels != nil {
+		if channels[i] != w.channel() {
+			w.pubChannel = channels[i]
+		}
+		defer func() { w.pubChannel = "" }()
+	}
+	return publish(msg)
+}
+
+// popQueued takes up to max messages from the head of the PublishQueue, the
+// LPOPs for the queued ones in one pipelined round trip.
+func (w *Watcher) popQueued(c redis.Conn, max int) ([]string, error) {
+	queued, err := redis.Int(c.Do("LLEN", w.options.PublishQueue))
+	if err != nil || queued == 0 {
+		return nil, err
+	}
+	if queued < max {
+		max = queued
+	}
+	for i := 0; i < max; i++ {
+		if err := c.Send("LPOP", w.options.PublishQueue); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	var msgs []string
+	for i := 0; i < max; i++ {
+		msg, recvErr := redis.String(c.Receive())
+		switch {
+		case recvErr == redis.ErrNil:
+		case recvErr != nil:
+			if err == nil {
+				err = recvErr
+			}
+		default:
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, err
+}
+
+// requeue puts msgs back at the head of the PublishQueue, in order.
+func (w *Watcher) requeue(c redis.Conn, msgs []string) {
+	if len(msgs) == 0 {
+		return
+	}
+	args := []interface{}{w.options.PublishQueue}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		args = append(args, msgs[i])
+	}
+	c.Do("LPUSH", args...)
+}
@@ -0,0 +1,166 @@
+package rediswatcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// pipelineConn counts how a connection is used.
+type pipelineConn struct {
+	redis.Conn
+	mu                 sync.Mutex
+	do, sends, flushes int
+}
+
+func (c *pipelineConn) Do(command string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	c.do++
+	c.mu.Unlock()
+	return c.Conn.Do(command, args...)
+}
+
+func (c *pipelineConn) Send(command string, args ...interface{}) error {
+	c.mu.Lock()
+	c.sends++
+	c.mu.Unlock()
+	return c.Conn.Send(command, args...)
+}
+
+func (c *pipelineConn) Flush() error {
+	c.mu.Lock()
+	c.flushes++
+	c.mu.Unlock()
+	return c.Conn.Flush()
+}
+
+func TestPublishBurst(t *testing.T) {
+	b := NewMemoryBroker()
+	sub, err := newWatcher("", WithMemoryBroker(b), LocalID("node2"))
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer sub.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sub.WaitUntilSubscribed(ctx); err != nil {
+		t.Fatalf("WaitUntilSubscribed failed: %v", err)
+	}
+	var mu sync.Mutex
+	var got []string
+	sub.SetUpdateCallback(func(msg string) {
+		mu.Lock()
+		got = append(got, msg)
+		mu.Unlock()
+	})
+
+	pub := &pipelineConn{Conn: b.Conn()}
+	var sizes []int64
+	w, err := newPublishWatcher("", WithRedisPubConnection(pub), WithRedisSubConnection(b.Conn()),
+		RecordMetrics(func(m *WatcherMetrics) {
+			if m.Name == PubSubPublishMetric {
+				sizes = append(sizes, m.MessageSize)
+			}
+		}))
+	if err != nil {
+		t.Fatalf("NewPublishWatcher failed: %v", err)
+	}
+	defer w.Close()
+
+	w.pubMu.Lock()
+	sent, err := w.publishBurst(nil, []string{"a", "b", "cc"})
+	w.pubMu.Unlock()
+	if err != nil || sent != 3 {
+		t.Fatalf("Expected 3 messages published, got %d: %v", sent, err)
+	}
+	if pub.do != 0 || pub.sends != 3 || pub.flushes != 1 {
+		t.Fatalf("Expected one pipelined round trip, got %d Do, %d Send and %d Flush", pub.do, pub.sends, pub.flushes)
+	}
+	if fmt.Sprint(sizes) != "[1 1 2]" {
+		t.Fatalf("Expected a metric per message, got sizes %v", sizes)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 3 updates, got %d", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got[0] != "a" || got[1] != "b" || got[2] != "cc" {
+		t.Fatalf("Updates out of order: %v", got)
+	}
+}
+
+func TestPublishBurstOneByOne(t *testing.T) {
+	b := NewMemoryBroker()
+	pub := &pipelineConn{Conn: b.Conn()}
+	w, err := newPublishWatcher("", WithRedisPubConnection(pub), WithRedisSubConnection(b.Conn()),
+		PublishRateLimit(1000, 10))
+	if err != nil {
+		t.Fatalf("NewPublishWatcher failed: %v", err)
+	}
+	defer w.Close()
+
+	w.pubMu.Lock()
+	sent, err := w.publishBurst(nil, []string{"a", "b"})
+	w.pubMu.Unlock()
+	if err != nil || sent != 2 {
+		t.Fatalf("Expected 2 messages published, got %d: %v", sent, err)
+	}
+	if pub.do != 2 || pub.sends != 0 {
+		t.Fatalf("A rate limit must publish one by one, got %d Do and %d Send", pub.do, pub.sends)
+	}
+}
+
+func TestFlushQueuePipelined(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	queue := NewTestConn()
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		WithRedisQueueConnection(queue), PublishQueue("casbin:queue"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	queue.Command("LLEN", "casbin:queue").Expect(int64(2))
+	queue.Command("LPOP", "casbin:queue").Expect([]byte("a")).Expect([]byte("b"))
+	first := pub.Command("PUBLISH", "/casbin", "a").Expect(int64(1))
+	second := pub.Command("PUBLISH", "/casbin", "b").ExpectError(redis.ErrPoolExhausted)
+	requeue := queue.Command("LPUSH", "casbin:queue", "b").Expect(int64(1))
+
+	w.pubMu.Lock()
+	err = w.flushQueue()
+	w.pubMu.Unlock()
+	if err == nil {
+		t.Fatal("Expected the publish error")
+	}
+	if pub.Stats(first) != 1 || pub.Stats(second) != 1 {
+		t.Fatal("Both queued messages should have been sent")
+	}
+	if queue.Stats(requeue) != 1 {
+		t.Fatal("The failed message should be put back")
+	}
+
+	// an empty queue is not popped
+	queue.Command("LLEN", "casbin:queue").Expect(int64(0))
+	pop := queue.Command("LPOP", "casbin:queue")
+	w.pubMu.Lock()
+	err = w.flushQueue()
+	w.pubMu.Unlock()
+	if err != nil || queue.Stats(pop) != 2 {
+		t.Fatalf("An empty queue should not be popped, got %d LPOP: %v", queue.Stats(pop), err)
+	}
+}
@@ -0,0 +1,68 @@
+package rediswatcher
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// FetchPolicy asks the peers for the current policy, e.g. when a node
+// starts or detects a gap while the database is struggling, and returns
+// the first answer with the ID of the peer that sent it. Peers answer when
+// they have a PolicyProvider; without any, FetchPolicy waits until ctx is
+// done. Answers travel on the watcher channel followed by ":rpc", which the
+// watcher subscribes to on first use.
+func (w *Watcher) FetchPolicy(ctx context.Context) ([]byte, string, error) {
+	if w.subDone == nil {
+		return nil, "", errors.New("rediswatcher: FetchPolicy needs a subscribing watcher")
+	}
+
+	reply := w.channel() + ":rpc"
+	if err := w.subscribeChannel(reply); err != nil {
+		return nil, "", err
+	}
+	nonce := uuid.New().String()
+	received := w.awaitReplies(nonce, 1)
+	defer w.stopReplies(nonce)
+
+	if err := w.publishMessage(Message{Type: MessageTypePolicyRequest, ID: w.options.LocalID, Nonce: nonce, Reply: reply}); err != nil {
+		return nil, "", err
+	}
+	select {
+	case m := <-received:
+		return m.Policy, m.ID, nil
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	case <-w.closed:
+		return nil, "", ErrClosed
+	}
+}
+
+// answerPolicyRequest sends the policy of the PolicyProvider to the peer
+// asking for it. The provider runs in the background, so a slow one does not
+// hold up received updates.
+func (w *Watcher) answerPolicyRequest(m Message) {
+	if w.options.policyProvider == nil || m.ID == w.options.LocalID || m.Reply == "" {
+		return
+	}
+
+	w.spawn(func() {
+		policy, err := w.options.policyProvider()
+		if err != nil {
+			w.reportError(err)
+			return
+		}
+		answer := encodeMessage(Message{Type: MessageTypePolicy, ID: w.options.LocalID, Nonce: m.Nonce, Policy: policy})
+
+		w.pubMu.Lock()
+		defer w.pubMu.Unlock()
+		c, err := w.publisher()
+		if err == nil {
+			_, err = c.Do("PUBLISH", m.Reply, answer)
+		}
+		if err != nil {
+			w.reportError(err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package rediswatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFetchPolicy(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), PolicyProvider(func() ([]byte, error) {
+			return []byte("p, alice, data1, read"), nil
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	w.subDone = make(chan struct{})
+
+	w.handleControlMessage(Message{Type: MessageTypePolicyRequest, ID: "node2", Nonce: "n1", Reply: "/casbin:rpc"})
+	var calls [][]interface{}
+	for deadline := time.Now().Add(time.Second); len(calls) == 0 && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		calls = pub.calls("PUBLISH")
+	}
+	if len(calls) != 1 || calls[0][0] != "/casbin:rpc" {
+		t.Fatalf("Policy request should be answered, got %v", calls)
+	}
+	if m, _ := decodeMessage(calls[0][1].(string)); m.Type != MessageTypePolicy || m.Nonce != "n1" || string(m.Policy) != "p, alice, data1, read" {
+		t.Fatalf("Answer should carry the policy, got %v", calls[0][1])
+	}
+
+	type result struct {
+		policy []byte
+		peer   string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		policy, peer, err := w.FetchPolicy(context.Background())
+		done <- result{policy, peer, err}
+	}()
+	var request Message
+	for deadline := time.Now().Add(time.Second); request.Nonce == "" && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if calls := pub.calls("PUBLISH"); len(calls) == 2 {
+			request, _ = decodeMessage(calls[1][1].(string))
+		}
+	}
+	if request.Type != MessageTypePolicyRequest || request.Reply != "/casbin:rpc" {
+		t.Fatalf("FetchPolicy should publish a policy request, got %+v", request)
+	}
+	w.handleControlMessage(Message{Type: MessageTypePolicy, ID: "node3", Nonce: request.Nonce, Policy: []byte("p, bob, data2, write")})
+	select {
+	case r := <-done:
+		if r.err != nil || r.peer != "node3" || string(r.policy) != "p, bob, data2, write" {
+			t.Fatalf("FetchPolicy should return the answer of the peer, got %q from %s, %v", r.policy, r.peer, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("FetchPolicy should return the first answer")
+	}
+}
@@ -0,0 +1,106 @@
+package rediswatcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/google/uuid"
+)
+
+// policyLockRetry is the pause between attempts to take a held policy lock.
+const policyLockRetry = 50 * time.Millisecond
+
+// PolicyLock is a cluster-wide lock serializing policy writes, taken with
+// AcquirePolicyLock.
+type PolicyLock struct {
+	w     *Watcher
+	key   string
+	token string
+}
+
+// AcquirePolicyLock takes the lock at the PolicyLockKey for ttl, waiting
+// until it is free or ctx is done. Holding it around SavePolicy and the
+// following Update keeps nodes from overwriting each other's changes:
+//
+//	lock, err := w.AcquirePolicyLock(ctx, 10*time.Second)
+//	if err != nil {
+//		return err
+//	}
+//	defer lock.Release()
+//	if err := enforcer.SavePolicy(); err != nil {
+//		return err
+//	}
+//	return w.Update()
+//
+// The lock lives on the single Redis server of the watcher; ttl bounds how
+// long a crashed holder blocks the others.
+func (w *Watcher) AcquirePolicyLock(ctx context.Context, ttl time.Duration) (*PolicyLock, error) {
+	key := w.options.policyLockKey
+	if key == "" {
+		key = w.channel() + ":lock"
+	}
+	l := &PolicyLock{w: w, key: key, token: uuid.New().String()}
+
+	for {
+		ok, err := l.tryAcquire(ttl)
+		if err != nil || ok {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-w.closed:
+			return nil, ErrClosed
+		case <-time.After(policyLockRetry):
+		}
+	}
+}
+
+func (l *PolicyLock) tryAcquire(ttl time.Duration) (bool, error) {
+	l.w.pubMu.Lock()
+	defer l.w.pubMu.Unlock()
+	c, err := l.w.publisher()
+	if err != nil {
+		return false, err
+	}
+	reply, err := redis.String(c.Do("SET", l.key, l.token, "NX", "PX", int64(ttl/time.Millisecond)))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	return reply == "OK", err
+}
+
+// Release frees the lock. It returns ErrLockLost when the ttl ran out
+// before, in which case another node may have written policies meanwhile.
+func (l *PolicyLock) Release() error {
+	l.w.pubMu.Lock()
+	defer l.w.pubMu.Unlock()
+	c, err := l.w.publisher()
+	if err != nil {
+		return err
+	}
+	released, err := redis.Bool(releaseScript.Do(c, l.key, l.token))
+	if err == nil && !released {
+		return ErrLockLost
+	}
+	return err
+}
+
+// WithPolicyLock runs save, typically SavePolicy, holding the policy lock
+// and publishes an update when it succeeds, before releasing the lock.
+func (w *Watcher) WithPolicyLock(ctx context.Context, ttl time.Duration, save func() error) error {
+	l, err := w.AcquirePolicyLock(ctx, ttl)
+	if err != nil {
+		return err
+	}
+	if err := save(); err != nil {
+		l.Release()
+		return err
+	}
+	err = w.Update()
+	if releaseErr := l.Release(); err == nil {
+		err = releaseErr
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package rediswatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPolicyLock(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	pub.GenericCommand("SET").Expect(nil).Expect("OK")
+	pub.GenericCommand("EVALSHA").Expect(int64(1))
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	saved := false
+	err = w.WithPolicyLock(context.Background(), 5*time.Second, func() error {
+		saved = true
+		return nil
+	})
+	if err != nil || !saved {
+		t.Fatalf("Failed watcher.WithPolicyLock(): %v", err)
+	}
+	sets := pub.calls("SET")
+	if len(sets) != 2 || sets[0][0] != "/casbin:lock" || sets[0][3] != "PX" || sets[0][4] != int64(5000) {
+		t.Fatalf("The lock should be retried until free, got %v", sets)
+	}
+	if len(pub.calls("PUBLISH")) != 1 || len(pub.calls("EVALSHA")) != 1 {
+		t.Fatal("The update should be published before the lock is released")
+	}
+
+	lock, err := w.AcquirePolicyLock(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("Failed watcher.AcquirePolicyLock(): %v", err)
+	}
+	pub.GenericCommand("EVALSHA").Expect(int64(0))
+	if err := lock.Release(); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("Releasing an expired lock should fail with ErrLockLost, got %v", err)
+	}
+
+	pub.GenericCommand("SET").Expect(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := w.AcquirePolicyLock(ctx, time.Second); err != context.DeadlineExceeded {
+		t.Fatalf("Waiting for a held lock should end with ctx, got %v", err)
+	}
+}
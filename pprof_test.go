@@ -0,0 +1,30 @@
+package rediswatcher
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+func TestSpawnLabels(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	w.options.Channel = "/casbin"
+	w.options.LocalID = "node1"
+
+	started := make(chan struct{})
+	w.spawn(func() {
+		close(started)
+		<-w.closed
+	})
+	<-started
+
+	var profile bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&profile, 1)
+	close(w.closed)
+	w.wg.Wait()
+
+	if !strings.Contains(profile.String(), `"rediswatcher":"/casbin"`) || !strings.Contains(profile.String(), `"localId":"node1"`) {
+		t.Fatalf("Watcher goroutines should be labelled:\n%s", profile.String())
+	}
+}
@@ -0,0 +1,77 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/google/uuid"
+)
+
+// preflight checks that the Redis user may use the channels of the watcher,
+// see PreflightCheck. It runs before the watcher subscribes, so a
+// subscribe-only watcher can still use its subscription connection.
+func (w *Watcher) preflight() error {
+	c := w.getSubConn()
+	if !w.options.SubscribeOnly {
+		w.pubMu.Lock()
+		defer w.pubMu.Unlock()
+		var err error
+		if c, err = w.publisher(); err != nil {
+			return err
+		}
+	}
+
+	user, err := redis.String(c.Do("ACL", "WHOAMI"))
+	if isUnknownCommand(err) {
+		return nil // no ACLs, every user may do everything
+	}
+	if err != nil {
+		return err
+	}
+
+	var checks [][]interface{}
+	if !w.options.SubscribeOnly {
+		checks = append(checks, []interface{}{"PUBLISH", w.channel(), "preflight"})
+	}
+	if w.messagesIn != nil {
+		for _, channel := range w.channels() {
+			checks = append(checks, []interface{}{"SUBSCRIBE", channel})
+		}
+		for _, pattern := range w.patterns() {
+			checks = append(checks, []interface{}{"PSUBSCRIBE", pattern})
+		}
+	}
+	for _, check := range checks {
+		reply, err := redis.String(c.Do("ACL", append([]interface{}{"DRYRUN", user}, check...)...))
+		if isUnknownCommand(err) {
+			return w.probePublish(c)
+		}
+		if err != nil {
+			return err
+		}
+		if reply != "OK" {
+			return wrapError(ErrNoPermission, fmt.Errorf("%s %v: %s", check[0], check[1], reply))
+		}
+	}
+	return nil
+}
+
+// probePublish publishes a probe, which watchers ignore, to find out
+// whether the user may publish on servers without ACL DRYRUN.
+func (w *Watcher) probePublish(c redis.Conn) error {
+	if w.options.SubscribeOnly {
+		return nil
+	}
+	probe := encodeMessage(Message{Type: MessageTypeProbe, ID: w.options.LocalID, Nonce: uuid.New().String()})
+	_, err := c.Do("PUBLISH", w.channel(), probe)
+	if err != nil && strings.HasPrefix(err.Error(), "NOPERM") {
+		return wrapError(ErrNoPermission, err)
+	}
+	return err
+}
+
+func isUnknownCommand(err error) bool {
+	redisErr, ok := err.(redis.Error)
+	return ok && strings.Contains(strings.ToLower(redisErr.Error()), "unknown")
+}
@@ -0,0 +1,44 @@
+package rediswatcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestPreflightCheck(t *testing.T) {
+	newWatcher := func(pub *testConn) error {
+		_, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(NewTestConn()),
+			PreflightCheck(true), ManualStart(true))
+		return err
+	}
+
+	pub := NewTestConn()
+	pub.Command("ACL", "WHOAMI").Expect("casbin")
+	pub.Command("ACL", "DRYRUN", "casbin", "PUBLISH", "/casbin", "preflight").Expect("OK")
+	if err := newWatcher(pub); err != nil {
+		t.Fatalf("Permitted user should pass, got %v", err)
+	}
+
+	pub.Command("ACL", "DRYRUN", "casbin", "PUBLISH", "/casbin", "preflight").
+		Expect("This user has no permissions to access the '/casbin' channel")
+	if err := newWatcher(pub); !errors.Is(err, ErrNoPermission) {
+		t.Fatalf("Missing permission should fail with ErrNoPermission, got %v", err)
+	}
+
+	pub = NewTestConn()
+	pub.Command("ACL", "WHOAMI").ExpectError(redis.Error("ERR unknown command 'ACL'"))
+	if err := newWatcher(pub); err != nil {
+		t.Fatalf("Servers without ACLs should pass, got %v", err)
+	}
+
+	pub = NewTestConn()
+	pub.Command("ACL", "WHOAMI").Expect("casbin")
+	pub.GenericCommand("ACL").ExpectError(redis.Error("ERR unknown subcommand 'DRYRUN'"))
+	pub.Command("ACL", "WHOAMI").Expect("casbin")
+	pub.GenericCommand("PUBLISH").ExpectError(redis.Error("NOPERM this user has no permissions to access one of the channels"))
+	if err := newWatcher(pub); !errors.Is(err, ErrNoPermission) {
+		t.Fatalf("Failed probe publish should fail with ErrNoPermission, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// PresenceEntry is the heartbeat a watcher keeps in the PresenceRegistry.
+type PresenceEntry struct {
+	ID       string    `json:"id"`
+	Host     string    `json:"host"`
+	Version  string    `json:"version,omitempty"`
+	Channel  string    `json:"channel"`
+	LastSeen time.Time `json:"lastSeen"`
+	Alive    bool      `json:"-"` // Seen within three heartbeat intervals.
+}
+
+var errNoPresence = errors.New("rediswatcher: no PresenceRegistry configured")
+
+func (w *Watcher) startPresence() {
+	if w.options.presenceKey == "" || w.options.presenceInterval <= 0 {
+		return
+	}
+
+	w.spawn(func() {
+		ticker := w.clock().NewTicker(w.options.presenceInterval)
+		defer ticker.Stop()
+		for {
+			if err := w.heartbeat(); err != nil {
+				w.reportError(err)
+			}
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+			}
+		}
+	})
+}
+
+// heartbeat stores the PresenceEntry of the watcher.
+func (w *Watcher) heartbeat() error {
+	host, _ := os.Hostname()
+	b, err := json.Marshal(PresenceEntry{
+		ID:       w.options.LocalID,
+		Host:     host,
+		Version:  w.options.helloVersion,
+		Channel:  w.channel(),
+		LastSeen: w.clock().Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("HSET", w.options.presenceKey, w.options.LocalID, string(b))
+	return err
+}
+
+// leavePresence removes the entry of the watcher when it closes.
+func (w *Watcher) leavePresence() {
+	if w.options.presenceKey == "" {
+		return
+	}
+
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	if w.pubConn == nil {
+		return
+	}
+	if _, err := w.pubConn.Do("HDEL", w.options.presenceKey, w.options.LocalID); err != nil {
+		w.logEvent(levelWarn, "removing presence entry failed", "error", err)
+	}
+}
+
+// Presence lists the watchers registered in the PresenceRegistry, ordered by
+// ID. Entries not refreshed within three heartbeat intervals are not Alive;
+// their watcher crashed or lost its connection, see PruneDead.
+func (w *Watcher) Presence() ([]PresenceEntry, error) {
+	if w.options.presenceKey == "" {
+		return nil, errNoPresence
+	}
+
+	w.pubMu.Lock()
+	c, err := w.publisher()
+	var values map[string]string
+	if err == nil {
+		values, err = redis.StringMap(c.Do("HGETALL", w.options.presenceKey))
+	}
+	w.pubMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := w.clock().Now().Add(-3 * w.options.presenceInterval)
+	entries := make([]PresenceEntry, 0, len(values))
+	for id, value := range values {
+		e := PresenceEntry{ID: id}
+		// a malformed entry is listed as dead so PruneDead removes it
+		json.Unmarshal([]byte(value), &e)
+		e.Alive = e.LastSeen.After(deadline)
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// PruneDead removes the entries of watchers that are no longer Alive and
+// returns how many were removed.
+func (w *Watcher) PruneDead() (int, error) {
+	entries, err := w.Presence()
+	if err != nil {
+		return 0, err
+	}
+	args := []interface{}{w.options.presenceKey}
+	for _, e := range entries {
+		if !e.Alive {
+			args = append(args, e.ID)
+		}
+	}
+	if len(args) == 1 {
+		return 0, nil
+	}
+
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return 0, err
+	}
+	return redis.Int(c.Do("HDEL", args...))
+}
@@ -0,0 +1,49 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPresenceRegistry(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("HSET").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), Hello("1.2.0", nil), PresenceRegistry("casbin:watchers", time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	if err := w.heartbeat(); err != nil {
+		t.Fatalf("Failed heartbeat: %v", err)
+	}
+	calls := pub.calls("HSET")
+	if len(calls) == 0 || calls[0][0] != "casbin:watchers" || calls[0][1] != "node1" {
+		t.Fatalf("Heartbeat should store the entry of the watcher, got %v", calls)
+	}
+
+	alive, _ := json.Marshal(PresenceEntry{ID: "node1", Version: "1.2.0", LastSeen: time.Now()})
+	dead, _ := json.Marshal(PresenceEntry{ID: "node2", LastSeen: time.Now().Add(-time.Hour)})
+	pub.Command("HGETALL", "casbin:watchers").
+		Expect([]interface{}{[]byte("node2"), dead, []byte("node1"), alive})
+	entries, err := w.Presence()
+	if err != nil {
+		t.Fatalf("Failed watcher.Presence(): %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != "node1" || !entries[0].Alive || entries[0].Version != "1.2.0" || entries[1].Alive {
+		t.Fatalf("Presence should list live and dead watchers, got %+v", entries)
+	}
+
+	pub.Command("HDEL", "casbin:watchers", "node2").Expect(int64(1))
+	if n, err := w.PruneDead(); err != nil || n != 1 {
+		t.Fatalf("PruneDead should remove the dead watcher, got %d, %v", n, err)
+	}
+
+	pub.Command("HDEL", "casbin:watchers", "node1").Expect(int64(1))
+	w.Close()
+	if calls := pub.calls("HDEL"); len(calls) != 2 || calls[1][1] != "node1" {
+		t.Fatalf("Close should remove the entry of the watcher, got %v", calls)
+	}
+}
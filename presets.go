@@ -0,0 +1,82 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Names of the presets selectable with Preset.
+const (
+	// PresetResilient favours riding out Redis and callback failures:
+	// quick resubscribes, callback retries, a larger queue collapsing
+	// bursts, debounced reloads and a periodic reload as a safety net.
+	PresetResilient = "resilient"
+	// PresetLowLatency runs callbacks as soon as updates arrive: no
+	// squashing or debouncing, several workers and fast resubscribes.
+	PresetLowLatency = "low-latency"
+	// PresetStrictConsistency never skips an update: callbacks run one at a
+	// time in order with retries, publishes must reach a subscriber and
+	// Close waits longer for running callbacks.
+	PresetStrictConsistency = "strict-consistency"
+)
+
+var presets = map[string][]WatcherOption{
+	PresetResilient: {
+		ResubscribeThreshold(time.Second),
+		CallbackRetry(5, 200*time.Millisecond, 10*time.Second),
+		CallbackQueueSize(256),
+		QueueOverflow(OverflowCollapse, nil),
+		Debounce(250 * time.Millisecond),
+		PeriodicReload(10 * time.Minute),
+		DrainTimeout(15 * time.Second),
+	},
+	PresetLowLatency: {
+		ResubscribeThreshold(100 * time.Millisecond),
+		SquashMessages(false),
+		Debounce(0),
+		CallbackWorkers(4),
+		QueueOverflow(OverflowDropOldest, nil),
+	},
+	PresetStrictConsistency: {
+		SquashMessages(false),
+		Debounce(0),
+		OrderedDelivery(true),
+		CallbackRetry(10, 100*time.Millisecond, 5*time.Second),
+		StrictDelivery(3, 100*time.Millisecond),
+		QueueOverflow(OverflowBlock, nil),
+		DrainTimeout(30 * time.Second),
+	},
+}
+
+// Preset applies the options of a named preset, see the Preset constants.
+// Options after it override single settings:
+//
+//	rediswatcher.NewWatcher(addr,
+//		rediswatcher.Preset(rediswatcher.PresetResilient),
+//		rediswatcher.Debounce(time.Second))
+//
+// The constructors fail on an unknown name; Config.Validate reports it
+// before.
+func Preset(name string) WatcherOption {
+	setters, ok := presets[name]
+	return func(options *WatcherOptions) {
+		if !ok {
+			options.invalid(fmt.Errorf("rediswatcher: unknown preset %q", name))
+			return
+		}
+		for _, setter := range setters {
+			setter(options)
+		}
+	}
+}
+
+// Presets lists the names of the presets.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,43 @@
+package rediswatcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreset(t *testing.T) {
+	var options WatcherOptions
+	for _, setter := range []WatcherOption{Preset(PresetStrictConsistency), CallbackRetry(1, 0, 0)} {
+		setter(&options)
+	}
+	if !options.OrderedDelivery || !options.StrictDelivery || options.DrainTimeout != 30*time.Second {
+		t.Fatalf("The preset should be applied, got %+v", options)
+	}
+	if options.CallbackRetries != 1 {
+		t.Fatalf("Later options should override the preset, got %d retries", options.CallbackRetries)
+	}
+
+	if names := Presets(); len(names) != 3 || names[0] != PresetLowLatency {
+		t.Fatalf("Unexpected presets %v", names)
+	}
+	_, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(NewTestConn()), Preset("fast"))
+	if err == nil || !strings.Contains(err.Error(), `unknown preset "fast"`) {
+		t.Fatalf("An unknown preset should fail the constructor, got %v", err)
+	}
+}
+
+func TestConfigPreset(t *testing.T) {
+	err := Config{Addr: "127.0.0.1:6379", Preset: "fast"}.Validate()
+	if err == nil || !strings.Contains(err.Error(), `preset "fast" is unknown`) {
+		t.Fatalf("An unknown preset should be reported, got %v", err)
+	}
+
+	var options WatcherOptions
+	for _, setter := range (Config{Preset: PresetResilient, DebounceWindow: time.Second}).setters() {
+		setter(&options)
+	}
+	if options.CallbackRetries != 5 || options.DebounceWindow != time.Second {
+		t.Fatalf("The config fields should override the preset, got %+v", options)
+	}
+}
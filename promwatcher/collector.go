@@ -0,0 +1,112 @@
+// Package promwatcher exposes the metrics of a rediswatcher.Watcher to
+// Prometheus.
+//
+//	c := promwatcher.NewCollector("casbin")
+//	prometheus.MustRegister(c)
+//	w, _ := rediswatcher.NewWatcher(addr, rediswatcher.RecordMetrics(c.Record))
+package promwatcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+// Collector is a prometheus.Collector fed by the RecordMetrics hook of one
+// or more watchers.
+type Collector struct {
+	published       prometheus.Counter
+	publishFailures prometheus.Counter
+	received        prometheus.Counter
+	reconnects      prometheus.Counter
+	callbackErrors  prometheus.Counter
+	callbackLatency prometheus.Histogram
+	redisLatency    *prometheus.HistogramVec
+
+	mu         sync.Mutex
+	subscribed map[string]bool // watchers, by LocalID, that subscribed once
+}
+
+// NewCollector creates a Collector with metric names prefixed by namespace.
+func NewCollector(namespace string) *Collector {
+	counter := func(name, help string) prometheus.Counter {
+		return prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "watcher", Name: name, Help: help,
+		})
+	}
+	return &Collector{
+		published:       counter("messages_published_total", "Messages published by the watcher."),
+		publishFailures: counter("publish_failures_total", "Publishes that failed."),
+		received:        counter("messages_received_total", "Messages received from the channel."),
+		reconnects:      counter("reconnects_total", "Subscriptions re-established after the first one."),
+		callbackErrors:  counter("callback_errors_total", "Update callbacks that returned an error."),
+		callbackLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "watcher", Name: "callback_duration_seconds",
+			Help: "Time taken by update callbacks.",
+		}),
+		redisLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "watcher", Name: "redis_operation_duration_seconds",
+			Help: "Time taken by Redis operations.",
+		}, []string{"operation"}),
+		subscribed: make(map[string]bool),
+	}
+}
+
+// Record updates the metrics from m. Pass it to rediswatcher.RecordMetrics.
+func (c *Collector) Record(m *rediswatcher.WatcherMetrics) {
+	latency := time.Duration(m.LatencyMs * float64(time.Millisecond)).Seconds()
+
+	switch m.Name {
+	case rediswatcher.CallbackMetric:
+		c.callbackLatency.Observe(latency)
+		if m.Error != nil {
+			c.callbackErrors.Inc()
+		}
+		return
+	case rediswatcher.PubSubPublishMetric:
+		if m.Error != nil {
+			c.publishFailures.Inc()
+		} else {
+			c.published.Inc()
+		}
+	case rediswatcher.PubSubReceiveMetric:
+		if m.Error == nil && m.MessageSize > 0 {
+			c.received.Inc()
+		}
+	case rediswatcher.PubSubSubscribeMetric:
+		if m.Error == nil {
+			c.mu.Lock()
+			if c.subscribed[m.LocalID] {
+				c.reconnects.Inc()
+			}
+			c.subscribed[m.LocalID] = true
+			c.mu.Unlock()
+		}
+	}
+	c.redisLatency.WithLabelValues(m.Name).Observe(latency)
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.published.Describe(ch)
+	c.publishFailures.Describe(ch)
+	c.received.Describe(ch)
+	c.reconnects.Describe(ch)
+	c.callbackErrors.Describe(ch)
+	c.callbackLatency.Describe(ch)
+	c.redisLatency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.published.Collect(ch)
+	c.publishFailures.Collect(ch)
+	c.received.Collect(ch)
+	c.reconnects.Collect(ch)
+	c.callbackErrors.Collect(ch)
+	c.callbackLatency.Collect(ch)
+	c.redisLatency.Collect(ch)
+}
@@ -0,0 +1,41 @@
+package promwatcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("casbin")
+
+	for _, m := range []*rediswatcher.WatcherMetrics{
+		{Name: rediswatcher.PubSubPublishMetric, LatencyMs: 2},
+		{Name: rediswatcher.PubSubPublishMetric, Error: errors.New("connection refused")},
+		{Name: rediswatcher.PubSubReceiveMetric, MessageSize: 5},
+		{Name: rediswatcher.PubSubReceiveMetric}, // subscription confirmation
+		{Name: rediswatcher.PubSubSubscribeMetric, LocalID: "node1"},
+		{Name: rediswatcher.PubSubSubscribeMetric, LocalID: "node1"},
+		{Name: rediswatcher.CallbackMetric, LatencyMs: 100, Error: errors.New("load failed")},
+	} {
+		c.Record(m)
+	}
+
+	for name, want := range map[string]float64{
+		"published":       testutil.ToFloat64(c.published),
+		"publishFailures": testutil.ToFloat64(c.publishFailures),
+		"received":        testutil.ToFloat64(c.received),
+		"reconnects":      testutil.ToFloat64(c.reconnects),
+		"callbackErrors":  testutil.ToFloat64(c.callbackErrors),
+	} {
+		if want != 1 {
+			t.Errorf("%s should be 1, got %v", name, want)
+		}
+	}
+	if n := testutil.CollectAndCount(c); n != 9 {
+		t.Fatalf("Collector should export 9 series, got %d", n)
+	}
+}
@@ -0,0 +1,86 @@
+package rediswatcher
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// queueConnection returns the connection used for the publish queue, dialing
+// PublishQueueAddr (or the watcher address) when no usable one exists.
+func (w *Watcher) queueConnection() (redis.Conn, error) {
+	if w.options.QueueConn != nil {
+		return w.options.QueueConn, nil
+	}
+	if w.queueConn != nil && w.queueConn.Err() == nil {
+		return w.queueConn, nil
+	}
+	if w.queueConn != nil {
+		w.queueConn.Close()
+		w.queueConn = nil
+	}
+
+	addr := w.options.PublishQueueAddr
+	if addr == "" {
+		addr = w.addr
+	}
+	c, err := w.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	w.queueConn = *c
+	return w.queueConn, nil
+}
+
+// enqueue appends msg to the publish queue.
+func (w *Watcher) enqueue(msg string) error {
+	startTime := time.Now()
+	c, err := w.queueConnection()
+	if err == nil {
+		_, err = c.Do("RPUSH", w.options.PublishQueue, msg)
+	}
+	if w.options.RecordMetrics != nil {
+		w.options.RecordMetrics(w.createMetrics(PublishQueuePushMetric, startTime, err))
+	}
+	return err
+}
+
+// flushQueue publishes every queued message in order, pipelining up to
+// queueFlushBatch at a time. Messages that fail to publish are put back at
+// the head of the queue and the publish error is returned; an unreachable
+// queue only shows up in the metrics. Callers must hold pubMu.
+func (w *Watcher) flushQueue() error {
+	if w.options.PublishQueue == "" {
+		return nil
+	}
+
+	startTime := time.Now()
+	c, err := w.queueConnection()
+	for err == nil {
+		var msgs []string
+		msgs, err = w.popQueued(c, queueFlushBatch)
+		if sent, pubErr := w.publishBurst(nil, msgs); pubErr != nil {
+			w.requeue(c, msgs[sent:])
+			if w.options.RecordMetrics != nil {
+				w.options.RecordMetrics(w.createMetrics(PublishQueueFlushMetric, startTime, pubErr))
+			}
+			return pubErr
+		}
+		if len(msgs) < queueFlushBatch {
+			break
+		}
+	}
+	if w.options.RecordMetrics != nil {
+		w.options.RecordMetrics(w.createMetrics(PublishQueueFlushMetric, startTime, err))
+	}
+	return nil
+}
+
+// flushPublishQueue forwards queued messages once the connection has been
+// re-established.
+func (w *Watcher) flushPublishQueue() {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+
+	w.flushQueue()
+}
@@ -0,0 +1,115 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPublishQueue(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	queue := NewTestConn()
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		WithRedisQueueConnection(queue), PublishQueue("casbin:queue"), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	// publishing fails, the message must be stored
+	pub.Command("PUBLISH", "/casbin", "node1").ExpectError(fmt.Errorf("connection refused"))
+	queue.Command("LLEN", "casbin:queue").Expect(int64(0))
+	push := queue.Command("RPUSH", "casbin:queue", "node1").Expect(int64(1))
+
+	if err := w.Update(); err != nil {
+		t.Fatalf("Update should succeed when the message is queued: %v", err)
+	}
+	if queue.Stats(push) != 1 {
+		t.Fatal("Failed publish was not stored in the queue")
+	}
+
+	// publishing works again, the queued message is forwarded first
+	publish := pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+	queue.Command("LLEN", "casbin:queue").Expect(int64(1)).Expect(int64(0))
+	queue.Command("LPOP", "casbin:queue").Expect([]byte("node1"))
+
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+	// one failed attempt plus the queued and the new message
+	if n := pub.Stats(publish); n != 3 {
+		t.Fatalf("Queued and new message should both be published, got %d publishes", n)
+	}
+}
+
+func TestPublishQueueDisabled(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	pub.Command("PUBLISH", "/casbin", "node1").ExpectError(fmt.Errorf("connection refused"))
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.Update(); err == nil {
+		t.Fatal("Update should fail without a publish queue")
+	}
+}
+
+func TestStrictDelivery(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), StrictDelivery(1, time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	publish := pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(0))
+	if err := w.Update(); err != ErrNoSubscribers {
+		t.Fatalf("Update should fail with ErrNoSubscribers, got %v", err)
+	}
+	if n := pub.Stats(publish); n != 2 {
+		t.Fatalf("Update should retry once, published %d times", n)
+	}
+
+	pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(0)).Expect(int64(3))
+	if err := w.Update(); err != nil {
+		t.Fatalf("Update should succeed once subscribers appear: %v", err)
+	}
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.Update(); err != nil {
+				t.Errorf("Failed watcher.Update(): %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(pub.calls("PUBLISH")); n != 20 {
+		t.Fatalf("Expected 20 publishes, got %d", n)
+	}
+}
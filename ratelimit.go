@@ -0,0 +1,60 @@
+package rediswatcher
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket limits the publish rate, refilling rate tokens per second up
+// to burst.
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// take removes a token and returns zero, or returns how long to wait for
+// the next one.
+func (b *tokenBucket) take(rate float64, burst int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// waitPublishToken blocks until the PublishRateLimit allows another publish.
+func (w *Watcher) waitPublishToken() error {
+	if w.options.PublishRate <= 0 {
+		return nil
+	}
+
+	burst := w.options.PublishBurst
+	if burst < 1 {
+		burst = 1
+	}
+	for {
+		wait := w.limiter.take(w.options.PublishRate, burst, w.clock().Now())
+		if wait == 0 {
+			return nil
+		}
+		select {
+		case <-w.closed:
+			return ErrClosed
+		case <-w.clock().After(wait):
+		}
+	}
+}
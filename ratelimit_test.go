@@ -0,0 +1,46 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var b tokenBucket
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if wait := b.take(10, 3, now); wait != 0 {
+			t.Fatalf("Burst of 3 should pass, publish %d has to wait %v", i+1, wait)
+		}
+	}
+	if wait := b.take(10, 3, now); wait != 100*time.Millisecond {
+		t.Fatalf("Publish over the burst should wait 100ms, got %v", wait)
+	}
+	if wait := b.take(10, 3, now.Add(100*time.Millisecond)); wait != 0 {
+		t.Fatalf("Token should be refilled after 100ms, got %v", wait)
+	}
+}
+
+func TestPublishRateLimit(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		PublishRateLimit(50, 1))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := w.Update(); err != nil {
+			t.Fatalf("Failed watcher.Update(): %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("3 publishes at 50/s should take about 40ms, took %v", elapsed)
+	}
+}
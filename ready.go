@@ -0,0 +1,69 @@
+package rediswatcher
+
+import (
+	"context"
+	"time"
+)
+
+// Ready returns a channel closed once the watcher first confirmed its
+// subscription, so startup can wait until policy changes are heard. For a
+// publish-only watcher it is closed from the start.
+func (w *Watcher) Ready() <-chan struct{} {
+	w.readyOnce.Do(w.initReady)
+	return w.ready
+}
+
+// WaitUntilSubscribed blocks until the watcher is Ready, ctx is done or the
+// watcher is closed.
+func (w *Watcher) WaitUntilSubscribed(ctx context.Context) error {
+	select {
+	case <-w.Ready():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.closed:
+		return ErrClosed
+	}
+}
+
+func (w *Watcher) initReady() {
+	w.ready = make(chan struct{})
+	if w.messagesIn == nil {
+		close(w.ready)
+	}
+}
+
+// markReady runs the WarmUp and closes the Ready channel after the first
+// subscription.
+func (w *Watcher) markReady() {
+	w.readyOnce.Do(w.initReady)
+	w.readyClose.Do(func() {
+		if err := w.warmUp(); err != nil {
+			w.reportError(err)
+		}
+		if w.messagesIn != nil {
+			close(w.ready)
+		}
+	})
+}
+
+// warmUp loads the latest snapshot or replays recent updates, see WarmUp.
+func (w *Watcher) warmUp() error {
+	if w.options.warmUp == nil {
+		return nil
+	}
+
+	snapshot, err := w.LatestSnapshot()
+	if err == nil {
+		return w.options.warmUp(snapshot)
+	}
+	if err != ErrNoSnapshot {
+		return err
+	}
+	if w.options.warmUpReplay <= 0 || w.options.auditStream == "" {
+		return nil
+	}
+	// stream IDs carry the server time, a skewed local clock would shift the window
+	_, err = w.ReplayAudit(w.serverNow().Add(-w.options.warmUpReplay), time.Time{})
+	return err
+}
@@ -0,0 +1,72 @@
+package rediswatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestReady(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{}), messagesIn: make(chan redis.Message)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := w.WaitUntilSubscribed(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Watcher should not be ready before subscribing, got %v", err)
+	}
+
+	w.markReady()
+	w.markReady()
+	if err := w.WaitUntilSubscribed(context.Background()); err != nil {
+		t.Fatalf("Watcher should be ready once subscribed, got %v", err)
+	}
+
+	publisher := &Watcher{closed: make(chan struct{})}
+	select {
+	case <-publisher.Ready():
+	default:
+		t.Fatal("Publish-only watcher should be ready at once")
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	var loaded string
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		PolicySnapshots("casbin:snapshot", 0), AuditStream("casbin:audit", 0), WarmUp(func(snapshot []byte) error {
+			loaded = string(snapshot)
+			return nil
+		}, time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	w.messagesIn = make(chan redis.Message)
+
+	pub.Command("GET", "casbin:snapshot").Expect([]byte("casbin:snapshot:1"))
+	pub.Command("GET", "casbin:snapshot:1").Expect([]byte("p, alice, data1, read"))
+	w.markReady()
+	select {
+	case <-w.Ready():
+	default:
+		t.Fatal("Watcher should be ready after the warm-up")
+	}
+	if loaded != "p, alice, data1, read" {
+		t.Fatalf("Warm-up should load the latest snapshot, got %q", loaded)
+	}
+
+	pub.Command("GET", "casbin:snapshot").Expect(nil)
+	pub.GenericCommand("XRANGE").Expect([]interface{}{
+		[]interface{}{[]byte("1-0"), []interface{}{[]byte("message"), []byte("node2")}},
+	})
+	var replayed []string
+	w.SetUpdateCallback(func(msg string) {
+		replayed = append(replayed, msg)
+	})
+	if err := w.warmUp(); err != nil || len(replayed) != 1 || replayed[0] != "node2" {
+		t.Fatalf("Without a snapshot the warm-up should replay the audit stream, got %v, %v", replayed, err)
+	}
+}
@@ -0,0 +1,114 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// BenchmarkReceive measures a message from the subscription to the update
+// callback.
+func BenchmarkReceive(b *testing.B) {
+	w, err := newWatcher("", WithMemoryBroker(NewMemoryBroker()), Channel("/bench"))
+	if err != nil {
+		b.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.Close()
+	var n int64
+	done := make(chan struct{})
+	w.SetUpdateCallback(func(string) {
+		if atomic.AddInt64(&n, 1) == int64(b.N) {
+			close(done)
+		}
+	})
+
+	msg := redis.Message{Channel: "/bench", Data: []byte("tenant1")}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.received(time.Now(), msg)
+	}
+	<-done
+}
+
+// BenchmarkAcceptMessage measures the checks of the processing loop alone.
+func BenchmarkAcceptMessage(b *testing.B) {
+	w, err := newWatcher("", WithMemoryBroker(NewMemoryBroker()), Channel("/bench"), Synchronous(true))
+	if err != nil {
+		b.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.Close()
+	w.SetUpdateCallback(func(string) {})
+
+	msg := redis.Message{Channel: "/bench", Data: []byte("tenant1")}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.acceptMessage(msg, false)
+	}
+}
+
+type discardLeveledLogger struct{}
+
+func (discardLeveledLogger) Debug(string, ...interface{}) {}
+func (discardLeveledLogger) Info(string, ...interface{})  {}
+func (discardLeveledLogger) Warn(string, ...interface{})  {}
+func (discardLeveledLogger) Error(string, ...interface{}) {}
+
+// BenchmarkLogEvent measures the debug log of every received message.
+func BenchmarkLogEvent(b *testing.B) {
+	w := &Watcher{closed: make(chan struct{})}
+	WithLeveledLogger(discardLeveledLogger{})(&w.options)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.logEvent(levelDebug, "message received", "size", 7)
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	data := encodeMessage(Message{
+		Type:       MessageTypeBatch,
+		ID:         "node1",
+		Groups:     []string{"eu"},
+		Operations: []Operation{{Op: OperationAdd, Sec: "p", Ptype: "p", Rules: [][]string{{"alice", "data1", "read"}}}},
+		Payload:    []byte(`{"tenant":"t1"}`),
+	})
+	m, ok := decodeHeader(data)
+	if !ok || m.Type != MessageTypeBatch || m.ID != "node1" || len(m.Groups) != 1 {
+		t.Fatalf("Expected the routing fields, got %+v", m)
+	}
+	if m.Operations != nil || m.Payload != nil {
+		t.Fatalf("Rules and payloads should be left out, got %+v", m)
+	}
+	if full, _ := ParseMessage(data); len(full.Operations) != 1 {
+		t.Fatalf("ParseMessage should decode the rules, got %+v", full)
+	}
+	if _, ok := decodeHeader("node1"); ok {
+		t.Fatal("A plain message should not decode")
+	}
+}
+
+// BenchmarkIgnoreSelfBatch measures discarding an own batch message.
+func BenchmarkIgnoreSelfBatch(b *testing.B) {
+	w, err := newWatcher("", WithMemoryBroker(NewMemoryBroker()), Channel("/bench"), LocalID("node1"),
+		IgnoreSelf(true), Synchronous(true))
+	if err != nil {
+		b.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.Close()
+	w.SetUpdateCallback(func(string) {})
+
+	ops := make([]Operation, 100)
+	for i := range ops {
+		ops[i] = Operation{Op: OperationAdd, Sec: "p", Ptype: "p", Rules: [][]string{{"alice", "data1", "read"}}}
+	}
+	msg := redis.Message{Channel: "/bench", Data: []byte(encodeMessage(Message{Type: MessageTypeBatch, ID: "node1", Operations: ops}))}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.acceptMessage(msg, false)
+	}
+}
@@ -0,0 +1,198 @@
+package rediswatchertest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+// Proxy forwards TCP connections to a Redis server, real or mini, and can
+// cut them to simulate a network partition. Watchers connected to Addr lose
+// their connections on Cut and can't reconnect until Restore.
+type Proxy struct {
+	target string
+	ln     net.Listener
+
+	mu    sync.Mutex
+	down  bool
+	conns map[net.Conn]bool
+}
+
+// NewProxy starts a proxy to the server at target. It is closed at the end
+// of the test.
+func NewProxy(t testing.TB, target string) *Proxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("rediswatchertest: starting proxy: %v", err)
+	}
+	p := &Proxy{target: target, ln: ln, conns: make(map[net.Conn]bool)}
+	go p.serve()
+	t.Cleanup(p.close)
+	return p
+}
+
+// Addr is the address watchers connect to instead of the server.
+func (p *Proxy) Addr() string {
+	return p.ln.Addr().String()
+}
+
+// Cut closes every proxied connection and refuses new ones until Restore.
+func (p *Proxy) Cut() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = true
+	for c := range p.conns {
+		c.Close()
+		delete(p.conns, c)
+	}
+}
+
+// Restore accepts connections again after Cut.
+func (p *Proxy) Restore() {
+	p.mu.Lock()
+	p.down = false
+	p.mu.Unlock()
+}
+
+func (p *Proxy) close() {
+	p.ln.Close()
+	p.Cut()
+}
+
+func (p *Proxy) serve() {
+	for {
+		client, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		go p.forward(client)
+	}
+}
+
+// forward pipes client to a new connection to the target, unless the proxy
+// is down.
+func (p *Proxy) forward(client net.Conn) {
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		client.Close()
+		return
+	}
+
+	p.mu.Lock()
+	if p.down {
+		p.mu.Unlock()
+		client.Close()
+		server.Close()
+		return
+	}
+	p.conns[client] = true
+	p.conns[server] = true
+	p.mu.Unlock()
+
+	go pipe(server, client)
+	pipe(client, server)
+}
+
+// pipe copies from src to dst until either fails, then closes both.
+func pipe(dst, src net.Conn) {
+	io.Copy(dst, src)
+	dst.Close()
+	src.Close()
+}
+
+// Step is one action or assertion of a Scenario.
+type Step struct {
+	Name string
+	Run  func(t *testing.T)
+}
+
+// Scenario scripts a failover sequence step by step, e.g.
+//
+//	rediswatchertest.Scenario{
+//		rediswatchertest.Cut(p),
+//		rediswatchertest.Update(w1, 3),
+//		rediswatchertest.Restore(p),
+//		rediswatchertest.ExpectUpdates(r, "node1", "node1", "node1"),
+//	}.Run(t)
+//
+// Each step runs as a subtest and the scenario stops at the first failing
+// one.
+type Scenario []Step
+
+// Run runs the steps of s in order.
+func (s Scenario) Run(t *testing.T) {
+	t.Helper()
+	for i, step := range s {
+		if !t.Run(fmt.Sprintf("%02d-%s", i+1, step.Name), step.Run) {
+			t.Fatalf("rediswatchertest: scenario stopped at step %d, %s", i+1, step.Name)
+		}
+	}
+}
+
+// Do is a Step running f.
+func Do(name string, f func(t *testing.T)) Step {
+	return Step{Name: name, Run: f}
+}
+
+// Cut partitions the watchers behind p from the server.
+func Cut(p *Proxy) Step {
+	return Do("Cut", func(*testing.T) { p.Cut() })
+}
+
+// Restore ends the partition started by Cut.
+func Restore(p *Proxy) Step {
+	return Do("Restore", func(*testing.T) { p.Restore() })
+}
+
+// Sleep waits for d.
+func Sleep(d time.Duration) Step {
+	return Do("Sleep", func(*testing.T) { time.Sleep(d) })
+}
+
+// Update calls w.Update n times and fails when one returns an error.
+func Update(w *rediswatcher.Watcher, n int) Step {
+	return Do("Update", func(t *testing.T) {
+		for i := 0; i < n; i++ {
+			if err := w.Update(); err != nil {
+				t.Fatalf("Update %d failed: %v", i+1, err)
+			}
+		}
+	})
+}
+
+// WaitSubscribed waits until w is subscribed again.
+func WaitSubscribed(w *rediswatcher.Watcher) Step {
+	return Do("WaitSubscribed", func(t *testing.T) { waitState(t, w, true) })
+}
+
+// WaitDisconnected waits until w noticed it lost its subscription.
+func WaitDisconnected(w *rediswatcher.Watcher) Step {
+	return Do("WaitDisconnected", func(t *testing.T) { waitState(t, w, false) })
+}
+
+// ExpectUpdates waits for r to receive want, in order.
+func ExpectUpdates(r *Recorder, want ...string) Step {
+	return Do("ExpectUpdates", func(t *testing.T) {
+		for i, msg := range want {
+			if got := r.WaitForMessage(t, conformanceTimeout); got != msg {
+				t.Fatalf("update %d: expected %q, got %q", i+1, msg, got)
+			}
+		}
+	})
+}
+
+// ExpectNoUpdates fails when r receives an update within d.
+func ExpectNoUpdates(r *Recorder, d time.Duration) Step {
+	return Do("ExpectNoUpdates", func(t *testing.T) {
+		time.Sleep(d)
+		if msgs := r.Messages(); len(msgs) > 0 {
+			t.Fatalf("expected no updates, got %q", msgs)
+		}
+	})
+}
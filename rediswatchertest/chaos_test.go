@@ -0,0 +1,39 @@
+package rediswatchertest
+
+import (
+	"testing"
+	"time"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+func TestScenarioCatchUp(t *testing.T) {
+	s := NewServer(t)
+	p := NewProxy(t, s.Addr())
+
+	// the publisher reaches Redis through the proxy and queues what it
+	// can't publish directly on the server
+	ew, err := rediswatcher.NewWatcher(p.Addr(),
+		rediswatcher.LocalID("node1"),
+		rediswatcher.PublishQueue("chaos:queue"),
+		rediswatcher.PublishQueueAddr(s.Addr()),
+		rediswatcher.ResubscribeThreshold(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("creating watcher: %v", err)
+	}
+	w1 := ew.(*rediswatcher.Watcher)
+	t.Cleanup(w1.Close)
+	w2 := s.NewWatcher(t, rediswatcher.LocalID("node2"))
+	r := Record(w2)
+
+	Scenario{
+		WaitSubscribed(w1),
+		Cut(p),
+		WaitDisconnected(w1),
+		Update(w1, 3),
+		ExpectNoUpdates(r, 50*time.Millisecond),
+		Restore(p),
+		WaitSubscribed(w1),
+		ExpectUpdates(r, "node1", "node1", "node1"),
+	}.Run(t)
+}
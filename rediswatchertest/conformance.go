@@ -0,0 +1,189 @@
+package rediswatchertest
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+// Backend connects watchers to the Pub/Sub implementation under test: it
+// returns the address and options of one more watcher, e.g. connections
+// from a custom redis.Conn adapter given with WithRedisPubConnection and
+// WithRedisSubConnection. All watchers of a run must reach the same server.
+type Backend func(t testing.TB) (addr string, options []rediswatcher.WatcherOption)
+
+// conformanceTimeout bounds every wait of the suite.
+const conformanceTimeout = 5 * time.Second
+
+// RunConformance checks that watchers on backend behave like they do on
+// Redis: delivery, ordering, IgnoreSelf, groups, resubscribing and
+// deduplication. Each check runs as a subtest on its own channel.
+func RunConformance(t *testing.T, backend Backend) {
+	c := conformance{backend: backend}
+	t.Run("Delivery", c.delivery)
+	t.Run("Ordering", c.ordering)
+	t.Run("IgnoreSelf", c.ignoreSelf)
+	t.Run("Groups", c.groups)
+	t.Run("Resubscribe", c.resubscribe)
+	t.Run("Dedup", c.dedup)
+}
+
+type conformance struct {
+	backend Backend
+}
+
+// watcher creates a subscribed watcher named id on the channel of the
+// subtest, closed at its end.
+func (c conformance) watcher(t *testing.T, id string, options ...rediswatcher.WatcherOption) *rediswatcher.Watcher {
+	t.Helper()
+	addr, backend := c.backend(t)
+	options = append(append(backend, rediswatcher.Channel("/conformance/"+t.Name()), rediswatcher.LocalID(id)), options...)
+	ew, err := rediswatcher.NewWatcher(addr, options...)
+	if err != nil {
+		t.Fatalf("creating watcher %s: %v", id, err)
+	}
+	w := ew.(*rediswatcher.Watcher)
+	t.Cleanup(w.Close)
+	waitSubscribed(t, w)
+	return w
+}
+
+func waitSubscribed(t *testing.T, w *rediswatcher.Watcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), conformanceTimeout)
+	defer cancel()
+	if err := w.WaitUntilSubscribed(ctx); err != nil {
+		t.Fatalf("watcher not subscribed: %v", err)
+	}
+}
+
+// waitState waits until w is subscribed or not.
+func waitState(t *testing.T, w *rediswatcher.Watcher, subscribed bool) {
+	t.Helper()
+	deadline := time.Now().Add(conformanceTimeout)
+	for w.Stats().Subscribed != subscribed {
+		if time.Now().After(deadline) {
+			t.Fatalf("watcher still subscribed=%v", !subscribed)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (c conformance) delivery(t *testing.T) {
+	w1 := c.watcher(t, "node1")
+	r := Record(c.watcher(t, "node2"))
+
+	if err := w1.Update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if msg := r.WaitForMessage(t, conformanceTimeout); msg != "node1" {
+		t.Fatalf("Expected the update of node1, got %q", msg)
+	}
+}
+
+func (c conformance) ordering(t *testing.T) {
+	w1 := c.watcher(t, "node1")
+	r := Record(c.watcher(t, "node2", rediswatcher.OrderedDelivery(true)))
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		if err := w1.UpdateWithLSN(strconv.Itoa(i)); err != nil {
+			t.Fatalf("Update %d failed: %v", i, err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		m, _ := rediswatcher.ParseMessage(r.WaitForMessage(t, conformanceTimeout))
+		if m.LSN != strconv.Itoa(i) {
+			t.Fatalf("Update %d arrived out of order, got LSN %q", i, m.LSN)
+		}
+	}
+}
+
+func (c conformance) ignoreSelf(t *testing.T) {
+	w1 := c.watcher(t, "node1", rediswatcher.IgnoreSelf(true))
+	self := Record(w1)
+	r := Record(c.watcher(t, "node2"))
+
+	if err := w1.Update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	r.WaitForMessage(t, conformanceTimeout)
+	// the peer got it, so the sender had its chance too
+	time.Sleep(50 * time.Millisecond)
+	if msgs := self.Messages(); len(msgs) != 0 {
+		t.Fatalf("IgnoreSelf should skip the own update, got %q", msgs)
+	}
+}
+
+func (c conformance) groups(t *testing.T) {
+	w1 := c.watcher(t, "node1")
+	canary := Record(c.watcher(t, "node2", rediswatcher.InstanceGroups("canary")))
+	other := Record(c.watcher(t, "node3", rediswatcher.InstanceGroups("eu")))
+
+	if err := w1.UpdateForGroups("canary"); err != nil {
+		t.Fatalf("UpdateForGroups failed: %v", err)
+	}
+	if err := w1.Update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if m, _ := rediswatcher.ParseMessage(canary.WaitForMessage(t, conformanceTimeout)); len(m.Groups) != 1 {
+		t.Fatalf("The canary watcher should get the group update first, got %+v", m)
+	}
+	if msg := other.WaitForMessage(t, conformanceTimeout); msg != "node1" {
+		t.Fatalf("Other groups should only get the broadcast, got %q", msg)
+	}
+}
+
+func (c conformance) resubscribe(t *testing.T) {
+	w1 := c.watcher(t, "node1")
+	w2 := c.watcher(t, "node2")
+	r := Record(w2)
+
+	if err := w2.Unsubscribe(); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+	waitState(t, w2, false)
+	if err := w2.Resubscribe(""); err != nil {
+		t.Fatalf("Resubscribe failed: %v", err)
+	}
+	waitState(t, w2, true)
+
+	if err := w1.Update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if msg := r.WaitForMessage(t, conformanceTimeout); msg != "node1" {
+		t.Fatalf("Expected the update after resubscribing, got %q", msg)
+	}
+}
+
+func (c conformance) dedup(t *testing.T) {
+	w1 := c.watcher(t, "node1")
+	w2 := c.watcher(t, "node2", rediswatcher.Debounce(100*time.Millisecond))
+	batches := make(chan []string, 4)
+	w2.SetBatchCallback(func(msgs []string) error {
+		batches <- msgs
+		return nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := w1.Update(); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	select {
+	case msgs := <-batches:
+		if len(msgs) != 1 || msgs[0] != "node1" {
+			t.Fatalf("A burst should be delivered once, deduplicated, got %q", msgs)
+		}
+	case <-time.After(conformanceTimeout):
+		t.Fatal("The burst was not delivered")
+	}
+	select {
+	case msgs := <-batches:
+		t.Fatalf("The burst should be delivered once, got %q again", msgs)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
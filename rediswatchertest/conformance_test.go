@@ -0,0 +1,22 @@
+package rediswatchertest
+
+import (
+	"testing"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		b := rediswatcher.NewMemoryBroker()
+		RunConformance(t, func(testing.TB) (string, []rediswatcher.WatcherOption) {
+			return "", []rediswatcher.WatcherOption{rediswatcher.WithMemoryBroker(b)}
+		})
+	})
+	t.Run("Miniredis", func(t *testing.T) {
+		s := NewServer(t)
+		RunConformance(t, func(testing.TB) (string, []rediswatcher.WatcherOption) {
+			return s.Addr(), nil
+		})
+	})
+}
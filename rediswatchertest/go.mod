@@ -0,0 +1,18 @@
+module github.com/lutomas/casbin-redis-watcher/v2/rediswatchertest
+
+go 1.25.0
+
+require (
+	github.com/alicebob/miniredis/v2 v2.39.0
+	github.com/casbin/casbin/v2 v2.1.0
+	github.com/lutomas/casbin-redis-watcher/v2 v2.0.0
+)
+
+require (
+	github.com/Knetic/govaluate v3.0.1-0.20171022003610-9aa49832a739+incompatible // indirect
+	github.com/garyburd/redigo v1.6.0 // indirect
+	github.com/google/uuid v1.1.1 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+)
+
+replace github.com/lutomas/casbin-redis-watcher/v2 => ../
@@ -0,0 +1,118 @@
+// Package rediswatchertest runs watchers against an in-process miniredis
+// server, for fast and hermetic integration tests.
+//
+//	s := rediswatchertest.NewServer(t)
+//	w := s.NewWatcher(t)
+//	r := rediswatchertest.Record(w)
+//	s.TriggerUpdate(w, "node2")
+//	r.WaitForMessage(t, time.Second)
+//
+// Tests that only care about what is published can use a Spy instead, and
+// failover sequences can be scripted as a Scenario against a Proxy.
+package rediswatchertest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+// Server is a miniredis server closed at the end of the test.
+type Server struct {
+	*miniredis.Miniredis
+}
+
+// NewServer starts a miniredis server for t.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("rediswatchertest: starting miniredis: %v", err)
+	}
+	t.Cleanup(m.Close)
+	return &Server{Miniredis: m}
+}
+
+// NewWatcher creates a watcher connected to s and waits until it is
+// subscribed. It is closed at the end of the test.
+func (s *Server) NewWatcher(t testing.TB, options ...rediswatcher.WatcherOption) *rediswatcher.Watcher {
+	t.Helper()
+	ew, err := rediswatcher.NewWatcher(s.Addr(), options...)
+	if err != nil {
+		t.Fatalf("rediswatchertest: creating watcher: %v", err)
+	}
+	w := ew.(*rediswatcher.Watcher)
+	t.Cleanup(w.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.WaitUntilSubscribed(ctx); err != nil {
+		t.Fatalf("rediswatchertest: watcher not subscribed: %v", err)
+	}
+	return w
+}
+
+// TriggerUpdate publishes msg on the channel of w as another instance
+// would, and returns the number of subscribers that received it.
+func (s *Server) TriggerUpdate(w *rediswatcher.Watcher, msg string) int {
+	return s.Publish(w.GetWatcherOptions().Channel, msg)
+}
+
+// Recorder collects the updates a watcher receives.
+type Recorder struct {
+	mu       sync.Mutex
+	messages []string
+	received chan struct{}
+}
+
+// Record registers a Recorder on w with AddCallback, alongside its update
+// callback.
+func Record(w *rediswatcher.Watcher) *Recorder {
+	r := &Recorder{received: make(chan struct{}, 1)}
+	w.AddCallback(func(msg string) {
+		r.mu.Lock()
+		r.messages = append(r.messages, msg)
+		r.mu.Unlock()
+		select {
+		case r.received <- struct{}{}:
+		default:
+		}
+	})
+	return r
+}
+
+// Messages returns the updates received and not yet taken by WaitForMessage.
+func (r *Recorder) Messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+// WaitForMessage waits up to timeout for the next update not returned by an
+// earlier call and returns it, failing t when none arrives.
+func (r *Recorder) WaitForMessage(t testing.TB, timeout time.Duration) string {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		r.mu.Lock()
+		if len(r.messages) > 0 {
+			msg := r.messages[0]
+			r.messages = r.messages[1:]
+			r.mu.Unlock()
+			return msg
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-r.received:
+		case <-deadline:
+			t.Fatalf("rediswatchertest: no update received within %v", timeout)
+			return ""
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package rediswatchertest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	rediswatcher "github.com/lutomas/casbin-redis-watcher/v2"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer(t)
+	w1 := s.NewWatcher(t, rediswatcher.LocalID("node1"))
+	w2 := s.NewWatcher(t, rediswatcher.LocalID("node2"))
+	r := Record(w2)
+
+	if n := s.TriggerUpdate(w2, "node3"); n != 2 {
+		t.Fatalf("Both watchers should be subscribed, got %d", n)
+	}
+	if msg := r.WaitForMessage(t, time.Second); msg != "node3" {
+		t.Fatalf("Expected 'node3', got '%s'", msg)
+	}
+
+	if err := w1.Update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if msg := r.WaitForMessage(t, time.Second); msg != "node1" {
+		t.Fatalf("Expected 'node1', got '%s'", msg)
+	}
+	if res := fmt.Sprint(r.Messages()); res != "[]" {
+		t.Fatalf("Waited messages should be consumed, got %s", res)
+	}
+}
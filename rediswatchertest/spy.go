@@ -0,0 +1,131 @@
+package rediswatchertest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/casbin/casbin/v2/persist"
+)
+
+var _ persist.Watcher = (*Spy)(nil)
+
+// Call is a publishing method called on a Spy.
+type Call struct {
+	Method string
+	Args   []string
+}
+
+// String formats c like a call, e.g. "UpdateForDomain(tenant1)".
+func (c Call) String() string {
+	return c.Method + "(" + strings.Join(c.Args, ", ") + ")"
+}
+
+// Spy is a persist.Watcher recording the updates it is asked to publish, so
+// tests can assert that saving a policy publishes exactly the expected
+// notifications. Besides Update it records the UpdateForGroups,
+// UpdateForDomain and UpdateWithLSN methods of rediswatcher.Watcher.
+type Spy struct {
+	mu       sync.Mutex
+	calls    []Call
+	callback func(string)
+	err      error
+}
+
+// NewSpy creates a Spy without recorded calls.
+func NewSpy() *Spy {
+	return &Spy{}
+}
+
+// SetUpdateCallback keeps callback for Trigger.
+func (s *Spy) SetUpdateCallback(callback func(string)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callback = callback
+	return nil
+}
+
+// Update records the call.
+func (s *Spy) Update() error {
+	return s.record("Update")
+}
+
+// UpdateForGroups records the call.
+func (s *Spy) UpdateForGroups(groups ...string) error {
+	return s.record("UpdateForGroups", groups...)
+}
+
+// UpdateForDomain records the call.
+func (s *Spy) UpdateForDomain(domain string) error {
+	return s.record("UpdateForDomain", domain)
+}
+
+// UpdateWithLSN records the call.
+func (s *Spy) UpdateWithLSN(lsn string) error {
+	return s.record("UpdateWithLSN", lsn)
+}
+
+// Close does nothing.
+func (s *Spy) Close() {}
+
+// FailWith makes the update methods return err, nil to succeed again. The
+// failed calls are recorded too.
+func (s *Spy) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Trigger invokes the update callback with msg, as an update published by
+// another instance would.
+func (s *Spy) Trigger(msg string) {
+	s.mu.Lock()
+	callback := s.callback
+	s.mu.Unlock()
+	if callback != nil {
+		callback(msg)
+	}
+}
+
+// Calls returns the recorded calls in order.
+func (s *Spy) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Reset forgets the recorded calls.
+func (s *Spy) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+}
+
+// AssertCalls fails t unless exactly the calls want, in their String form,
+// were recorded in that order.
+func (s *Spy) AssertCalls(t testing.TB, want ...string) {
+	t.Helper()
+	var got []string
+	for _, c := range s.Calls() {
+		got = append(got, c.String())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("rediswatchertest: expected calls %q, got %q", want, got)
+	}
+}
+
+// AssertUpdates fails t unless n calls were recorded.
+func (s *Spy) AssertUpdates(t testing.TB, n int) {
+	t.Helper()
+	if calls := s.Calls(); len(calls) != n {
+		t.Fatalf("rediswatchertest: expected %d updates, got %d: %v", n, len(calls), calls)
+	}
+}
+
+func (s *Spy) record(method string, args ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{Method: method, Args: append([]string(nil), args...)})
+	return s.err
+}
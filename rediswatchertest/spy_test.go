@@ -0,0 +1,38 @@
+package rediswatchertest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestSpy(t *testing.T) {
+	e, err := casbin.NewEnforcer("../examples/rbac_model.conf", "../examples/rbac_policy.csv")
+	if err != nil {
+		t.Fatalf("Failed to create enforcer: %v", err)
+	}
+	s := NewSpy()
+	e.SetWatcher(s)
+
+	if _, err := e.AddPolicy("alice", "data3", "read"); err != nil {
+		t.Fatalf("AddPolicy failed: %v", err)
+	}
+	s.UpdateForDomain("tenant1")
+	s.AssertCalls(t, "Update()", "UpdateForDomain(tenant1)")
+
+	s.Reset()
+	s.FailWith(errors.New("redis down"))
+	if err := s.UpdateForGroups("canary", "eu"); err == nil {
+		t.Fatal("The update should fail")
+	}
+	s.AssertCalls(t, "UpdateForGroups(canary, eu)")
+	s.AssertUpdates(t, 1)
+
+	loaded := ""
+	s.SetUpdateCallback(func(msg string) { loaded = msg })
+	s.Trigger("node2")
+	if loaded != "node2" {
+		t.Fatalf("Trigger should run the callback, got '%s'", loaded)
+	}
+}
@@ -0,0 +1,86 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Watcher{}
+)
+
+// Register makes w available under name to Get, e.g. one watcher per
+// logical cluster shared across a codebase without passing it through every
+// constructor. A closed watcher drops out of the registry by itself. It is an
+// error to register a closed watcher or a name already taken.
+func Register(name string, w *Watcher) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if w.isClosed() {
+		return ErrClosed
+	}
+	if _, ok := registry[name]; ok {
+		return fmt.Errorf("rediswatcher: watcher %q already registered", name)
+	}
+	registry[name] = w
+	return nil
+}
+
+// Get returns the watcher registered under name.
+func Get(name string) (*Watcher, bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	w, ok := registry[name]
+	return w, ok
+}
+
+// Unregister removes name from the registry without closing its watcher and
+// reports whether it was registered.
+func Unregister(name string) bool {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	_, ok := registry[name]
+	delete(registry, name)
+	return ok
+}
+
+// Registered lists the registered names in order.
+func Registered() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CloseRegistered closes every registered watcher, e.g. on process
+// shutdown, emptying the registry.
+func CloseRegistered() {
+	registryMu.Lock()
+	watchers := make([]*Watcher, 0, len(registry))
+	for _, w := range registry {
+		watchers = append(watchers, w)
+	}
+	registryMu.Unlock()
+
+	for _, w := range watchers {
+		w.Close()
+	}
+}
+
+// unregisterWatcher removes the names w is registered under, called when
+// it closes.
+func unregisterWatcher(w *Watcher) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for name, registered := range registry {
+		if registered == w {
+			delete(registry, name)
+		}
+	}
+}
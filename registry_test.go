@@ -0,0 +1,39 @@
+package rediswatcher
+
+import "testing"
+
+func TestRegistry(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	if err := Register("main", w); err != nil {
+		t.Fatalf("Failed Register(): %v", err)
+	}
+	if err := Register("main", &Watcher{closed: make(chan struct{})}); err == nil {
+		t.Fatal("A name should only be registered once")
+	}
+	if got, ok := Get("main"); !ok || got != w {
+		t.Fatalf("Get should return the registered watcher, got %v, %v", got, ok)
+	}
+	if names := Registered(); len(names) != 1 || names[0] != "main" {
+		t.Fatalf("Unexpected registered names %v", names)
+	}
+
+	CloseRegistered()
+	if !w.isClosed() {
+		t.Fatal("CloseRegistered should close the watchers")
+	}
+	if _, ok := Get("main"); ok {
+		t.Fatal("A closed watcher should leave the registry")
+	}
+	if err := Register("main", w); err != ErrClosed {
+		t.Fatalf("A closed watcher should not be registered, got %v", err)
+	}
+
+	other := &Watcher{closed: make(chan struct{})}
+	Register("other", other)
+	if !Unregister("other") || Unregister("other") {
+		t.Fatal("Unregister should report whether the name was registered")
+	}
+	if other.isClosed() {
+		t.Fatal("Unregister should not close the watcher")
+	}
+}
@@ -0,0 +1,54 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func (w *Watcher) startPeriodicReload() {
+	if w.options.ReloadInterval <= 0 {
+		return
+	}
+
+	w.spawn(func() {
+		// checking more often than the interval keeps the bound close
+		ticker := w.clock().NewTicker(w.options.ReloadInterval / 4)
+		defer ticker.Stop()
+		lastRun := w.clock().Now()
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+				if w.reloadDue(lastRun) {
+					w.reload()
+					lastRun = w.clock().Now()
+				}
+			}
+		}
+	})
+}
+
+// reloadDue reports whether neither a callback succeeded nor a periodic
+// reload was issued, at lastRun, for the ReloadInterval.
+func (w *Watcher) reloadDue(lastRun time.Time) bool {
+	if last := time.Unix(0, atomic.LoadInt64(&w.counters.lastReload)); last.After(lastRun) {
+		lastRun = last
+	}
+	return w.clock().Now().Sub(lastRun) >= w.options.ReloadInterval
+}
+
+// reload hands a MessageTypeReload message to the processor as a local
+// one, which TrustedPublishers doesn't apply to.
+func (w *Watcher) reload() {
+	msg := redis.Message{
+		Channel: w.channel(),
+		Data:    []byte(encodeMessage(Message{Type: MessageTypeReload, ID: "periodic"})),
+	}
+	select {
+	case w.localIn <- msg:
+	case <-w.closed:
+	}
+}
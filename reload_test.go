@@ -0,0 +1,35 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestPeriodicReload(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{}), localIn: make(chan redis.Message, 1)}
+	w.options.Channel = "/casbin"
+	PeriodicReload(time.Minute)(&w.options)
+
+	if w.reloadDue(time.Now()) {
+		t.Fatal("Reload should not be due before the interval passed")
+	}
+	if !w.reloadDue(time.Now().Add(-time.Hour)) {
+		t.Fatal("Reload should be due once the interval passed")
+	}
+	atomic.StoreInt64(&w.counters.lastReload, time.Now().UnixNano())
+	if w.reloadDue(time.Now().Add(-time.Hour)) {
+		t.Fatal("A recent callback should postpone the reload")
+	}
+
+	w.reload()
+	msg := <-w.localIn
+	if m, ok := decodeMessage(string(msg.Data)); !ok || m.Type != MessageTypeReload || msg.Channel != "/casbin" {
+		t.Fatalf("Reload should hand a reload message to the processor, got %+v", msg)
+	}
+	if w.handleControlMessage(Message{Type: MessageTypeReload, ID: "periodic"}) {
+		t.Fatal("Reload message should reach the update callback")
+	}
+}
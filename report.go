@@ -0,0 +1,59 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ShutdownReport describes what was still in flight when a watcher closed,
+// so operators can log what a node left undone when it went down.
+type ShutdownReport struct {
+	ClosedAt           time.Time
+	Duration           time.Duration // Time Close took, including the drains.
+	QueuedUpdates      int           // Updates left in the callback queue.
+	EarlyMessages      int           // Messages that arrived before any callback was set.
+	DebouncedUpdates   int           // Updates still waiting for the Debounce window.
+	MissedWhilePaused  int           // Updates received while paused and never caught up.
+	CancelledCallbacks int32         // Callbacks still running when the drain timeout expired.
+	LastMessage        string        // Last message an update callback handled successfully.
+	LastReload         time.Time     // Time of that callback.
+	Err                error         // Error closing the connections, as returned by Closer.
+}
+
+// CloseWithReport closes the watcher like Close and returns what was still
+// in flight. Later calls return the report of the first one.
+func (w *Watcher) CloseWithReport() ShutdownReport {
+	w.shutdown()
+	return w.report
+}
+
+// setLastMessage remembers data as the last message handled successfully.
+func (w *Watcher) setLastMessage(data string) {
+	if last, _ := w.lastMessage.Load().(string); last == data {
+		return // storing would allocate again for the same message
+	}
+	w.lastMessage.Store(data)
+}
+
+// shutdownReport takes the report at the end of shutdown, which started at
+// startTime.
+func (w *Watcher) shutdownReport(startTime time.Time) ShutdownReport {
+	r := ShutdownReport{
+		ClosedAt:           time.Now(),
+		Duration:           time.Since(startTime),
+		QueuedUpdates:      len(w.jobs),
+		EarlyMessages:      w.earlyMessages(),
+		CancelledCallbacks: atomic.LoadInt32(&w.inflight),
+		LastReload:         loadTime(&w.counters.lastReload),
+		Err:                w.closeErr,
+	}
+	r.LastMessage, _ = w.lastMessage.Load().(string)
+
+	w.debounced.mu.Lock()
+	r.DebouncedUpdates = len(w.debounced.batch)
+	w.debounced.mu.Unlock()
+	w.pauseMu.Lock()
+	r.MissedWhilePaused = w.pause.missed
+	w.pauseMu.Unlock()
+	return r
+}
@@ -0,0 +1,39 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShutdownReport(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	CallbackWorkers(1)(&w.options)
+	CallbackQueueSize(4)(&w.options)
+	DrainTimeout(20 * time.Millisecond)(&w.options)
+	w.startCallbackWorkers()
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 4)
+	w.SetUpdateCallback(func(msg string) {
+		if msg == "node3" {
+			started <- struct{}{}
+			<-release
+		}
+	})
+	w.runCallback("node2")
+	w.dispatch("node3")
+	<-started
+	w.dispatch("node4")
+
+	r := w.CloseWithReport()
+	if r.LastMessage != "node2" || r.LastReload.IsZero() {
+		t.Fatalf("The last handled message should be reported, got %+v", r)
+	}
+	if r.CancelledCallbacks != 1 || r.QueuedUpdates != 1 {
+		t.Fatalf("The running and queued updates should be reported, got %+v", r)
+	}
+	if again := w.CloseWithReport(); again.ClosedAt != r.ClosedAt {
+		t.Fatal("Closing again should return the first report")
+	}
+}
@@ -0,0 +1,58 @@
+package rediswatcher
+
+import (
+	"os"
+	"os/signal"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Resync forces an immediate resubscribe and a full reload: the update
+// callback is invoked locally as if an update had been received. It gives
+// operators a standard way to kick a watcher that seems stuck.
+func (w *Watcher) Resync() error {
+	if w.isClosed() {
+		return ErrClosed
+	}
+
+	if w.subDone != nil {
+		w.subMu.Lock()
+		if w.isSubscribed() {
+			// the subscription loop resubscribes once the UNSUBSCRIBE is confirmed
+			w.unsubscribe(redis.PubSubConn{Conn: w.subConn})
+		}
+		w.subMu.Unlock()
+	}
+	select {
+	case w.resubscribe <- struct{}{}:
+	default:
+	}
+
+	switch {
+	case w.options.delegateKey != "":
+		w.delegatedResync()
+	case w.hasCallback():
+		w.runJob(singleJob(w.options.LocalID))
+	}
+	return nil
+}
+
+func (w *Watcher) startSignalHandler() {
+	if len(w.options.resyncSignals) == 0 {
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, w.options.resyncSignals...)
+	w.spawn(func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-signals:
+				w.Resync()
+			}
+		}
+	})
+}
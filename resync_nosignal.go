@@ -0,0 +1,10 @@
+//go:build js || wasip1 || plan9
+// +build js wasip1 plan9
+
+package rediswatcher
+
+import "os"
+
+// defaultResyncSignals is empty where there is no SIGHUP, so ResyncOnSignal
+// without signals does nothing.
+var defaultResyncSignals []os.Signal
@@ -0,0 +1,12 @@
+//go:build !js && !wasip1 && !plan9
+// +build !js,!wasip1,!plan9
+
+package rediswatcher
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultResyncSignals are the signals of ResyncOnSignal when none are given.
+var defaultResyncSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,37 @@
+//go:build !windows && !js && !wasip1 && !plan9
+// +build !windows,!js,!wasip1,!plan9
+
+package rediswatcher
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestResyncOnSignal(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), ResyncOnSignal())
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	ch := make(chan string, 1)
+	w.SetUpdateCallback(func(msg string) {
+		ch <- msg
+	})
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+	select {
+	case res := <-ch:
+		if res != "node1" {
+			t.Fatalf("Resync should reload with 'node1', received '%s' instead", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SIGHUP did not trigger a resync")
+	}
+}
@@ -0,0 +1,138 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryStrategy decides how long the watcher waits before retrying a failed
+// operation, see ReconnectStrategy and PublishRetryStrategy. NextDelay gets
+// the number of retries waited for since the last success, starting at 0,
+// and Reset is called after each success. A strategy may be shared by
+// several subscription loops, so it must be safe for concurrent use.
+type RetryStrategy interface {
+	NextDelay(attempt int) time.Duration
+	Reset()
+}
+
+// backoffFunc is a RetryStrategy depending on the attempt only.
+type backoffFunc func(attempt int) time.Duration
+
+func (f backoffFunc) NextDelay(attempt int) time.Duration { return f(attempt) }
+
+func (f backoffFunc) Reset() {}
+
+// ConstantBackoff waits d before every retry.
+func ConstantBackoff(d time.Duration) RetryStrategy {
+	return backoffFunc(func(int) time.Duration { return d })
+}
+
+// ExponentialBackoff waits initial before the first retry and doubles the
+// delay after each, up to max when max is positive.
+func ExponentialBackoff(initial, max time.Duration) RetryStrategy {
+	return backoffFunc(func(attempt int) time.Duration {
+		d := initial
+		for i := 0; i < attempt && (max <= 0 || d < max); i++ {
+			d *= 2
+		}
+		return capDelay(d, max)
+	})
+}
+
+// FibonacciBackoff grows the delay along the Fibonacci sequence times
+// initial, up to max when max is positive. It backs off more gently than
+// ExponentialBackoff.
+func FibonacciBackoff(initial, max time.Duration) RetryStrategy {
+	return backoffFunc(func(attempt int) time.Duration {
+		a, b := initial, initial
+		for i := 0; i < attempt && (max <= 0 || a < max); i++ {
+			a, b = b, a+b
+		}
+		return capDelay(a, max)
+	})
+}
+
+func capDelay(d, max time.Duration) time.Duration {
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// reconnectDelay is the wait of a subscription loop before its attempt-th
+// reconnect, the resubscribe threshold without a ReconnectStrategy.
+func (w *Watcher) reconnectDelay(attempt int) time.Duration {
+	if s := w.options.ReconnectStrategy; s != nil {
+		return s.NextDelay(attempt)
+	}
+	return w.resubscribeThreshold()
+}
+
+// publishRetryDelay is the wait before the attempt-th StrictDelivery
+// re-publish, the StrictDeliveryDelay without a PublishRetryStrategy.
+func (w *Watcher) publishRetryDelay(attempt int) time.Duration {
+	if s := w.options.PublishRetryStrategy; s != nil {
+		return s.NextDelay(attempt)
+	}
+	return w.options.StrictDeliveryDelay
+}
+
+// publishLocked runs publish holding pubMu. With StrictDelivery a publish
+// reaching no subscriber runs again after the backoff, until the retries
+// run out; pubMu is not held during the backoff, so other publishes are not
+// held up, and Close ends it with ErrClosed.
+func (w *Watcher) publishLocked(publish func() error) error {
+	for attempt := 0; ; attempt++ {
+		w.pubMu.Lock()
+		err := publish()
+		w.pubMu.Unlock()
+		if err != ErrNoSubscribers || attempt >= w.options.StrictDeliveryRetries {
+			if err == nil && attempt > 0 {
+				resetRetry(w.options.PublishRetryStrategy)
+			}
+			return err
+		}
+
+		w.logEvent(levelDebug, "no subscribers received the message, retrying", "attempt", attempt+1)
+		w.notifyError(ErrNoSubscribers)
+		select {
+		case <-w.closed:
+			return ErrClosed
+		case <-w.clock().After(w.publishRetryDelay(attempt)):
+		}
+	}
+}
+
+// resetRetry resets s after a success, when set.
+func resetRetry(s RetryStrategy) {
+	if s != nil {
+		s.Reset()
+	}
+}
+
+// connectWithRetry makes the initial connection of the constructors,
+// retrying failures for the ConnectRetryTimeout.
+func (w *Watcher) connectWithRetry(addr string) error {
+	err := w.connect(addr)
+	timeout := w.options.ConnectRetryTimeout
+	if err == nil || timeout <= 0 {
+		return err
+	}
+
+	strategy := w.options.ConnectRetryStrategy
+	if strategy == nil {
+		strategy = ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+	}
+	deadline := w.clock().Now().Add(timeout)
+	for attempt := 0; err != nil; attempt++ {
+		delay := strategy.NextDelay(attempt)
+		if w.clock().Now().Add(delay).After(deadline) {
+			return fmt.Errorf("rediswatcher: redis unavailable after %v: %v", timeout, err)
+		}
+		w.logEvent(levelWarn, "connecting to redis failed, retrying", "attempt", attempt+1, "error", err)
+		<-w.clock().After(delay)
+		err = w.connect(addr)
+	}
+	resetRetry(strategy)
+	return nil
+}
@@ -0,0 +1,140 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafaeljusto/redigomock"
+)
+
+func delays(s RetryStrategy, n int) string {
+	var d []time.Duration
+	for i := 0; i < n; i++ {
+		d = append(d, s.NextDelay(i))
+	}
+	return fmt.Sprint(d)
+}
+
+func TestRetryStrategies(t *testing.T) {
+	for _, test := range []struct {
+		strategy RetryStrategy
+		want     string
+	}{
+		{ConstantBackoff(time.Second), "[1s 1s 1s 1s 1s]"},
+		{ExponentialBackoff(time.Second, 5*time.Second), "[1s 2s 4s 5s 5s]"},
+		{FibonacciBackoff(time.Second, 4*time.Second), "[1s 1s 2s 3s 4s]"},
+		{ExponentialBackoff(time.Second, 0), "[1s 2s 4s 8s 16s]"},
+	} {
+		if res := delays(test.strategy, 5); res != test.want {
+			t.Errorf("Expected %s, got %s", test.want, res)
+		}
+	}
+}
+
+type countingStrategy struct {
+	attempts []int
+	resets   int
+}
+
+func (s *countingStrategy) NextDelay(attempt int) time.Duration {
+	s.attempts = append(s.attempts, attempt)
+	return time.Millisecond
+}
+
+func (s *countingStrategy) Reset() { s.resets++ }
+
+func TestPublishRetryStrategy(t *testing.T) {
+	pub := redigomock.NewConn()
+	pub.Command("PUBLISH", "/casbin", "node1").
+		Expect(int64(0)).
+		Expect(int64(0)).
+		Expect(int64(1))
+	strategy := &countingStrategy{}
+	w, _ := newPublishWatcher("", LocalID("node1"), StrictDelivery(3, time.Hour), PublishRetryStrategy(strategy),
+		WithRedisPubConnection(pub), WithRedisSubConnection(redigomock.NewConn()))
+	defer w.Close()
+
+	if err := w.Update(); err != nil {
+		t.Fatalf("Update should succeed on the third attempt, got %v", err)
+	}
+	if fmt.Sprint(strategy.attempts) != "[0 1]" || strategy.resets != 1 {
+		t.Fatalf("The strategy should pace the retries and reset after success, got %v and %d resets", strategy.attempts, strategy.resets)
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	w := &Watcher{}
+	ResubscribeThreshold(time.Second)(&w.options)
+	if d := w.reconnectDelay(3); d != time.Second {
+		t.Fatalf("Without a strategy the threshold applies, got %v", d)
+	}
+	ReconnectStrategy(ExponentialBackoff(time.Millisecond, 0))(&w.options)
+	if d := w.reconnectDelay(3); d != 8*time.Millisecond {
+		t.Fatalf("The strategy should set the delay, got %v", d)
+	}
+}
+
+func TestConnectRetry(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	if _, err := newPublishWatcher(addr, ConnectRetry(50*time.Millisecond, ConstantBackoff(20*time.Millisecond))); err == nil ||
+		!strings.Contains(err.Error(), "unavailable") {
+		t.Fatalf("Retrying should give up after the timeout, got %v", err)
+	}
+
+	// redis comes up after the application
+	up := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l, _ := net.Listen("tcp", addr)
+		up <- l
+	}()
+	w, err := newPublishWatcher(addr, ManualStart(true), ConnectRetry(2*time.Second, ConstantBackoff(20*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("The constructor should wait for redis, got %v", err)
+	}
+	w.Close()
+	if l := <-up; l != nil {
+		l.Close()
+	}
+}
+
+func TestPublishRetryWait(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(0))
+	w, _ := newPublishWatcher("", LocalID("node1"), StrictDelivery(3, time.Hour),
+		WithRedisPubConnection(pub), WithRedisSubConnection(redigomock.NewConn()))
+
+	done := make(chan error, 1)
+	go func() { done <- w.UpdateForDomain("acme") }()
+	for deadline := time.Now().Add(time.Second); len(pub.calls("PUBLISH")) == 0 && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+
+	// the backoff does not hold up other publishes
+	w.pubMu.Lock()
+	channel := w.publishChannel()
+	w.pubMu.Unlock()
+	if channel != "/casbin" {
+		t.Fatalf("The retrying publish should not set the channel of others, got %s", channel)
+	}
+	w.Close()
+	select {
+	case err := <-done:
+		if err != ErrClosed {
+			t.Fatalf("Close should end the backoff with ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close should end the backoff")
+	}
+	if calls := pub.calls("PUBLISH"); len(calls) != 1 || calls[0][0] != "/casbin/acme" {
+		t.Fatalf("Expected one publish on the domain channel, got %v", calls)
+	}
+}
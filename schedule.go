@@ -0,0 +1,111 @@
+package rediswatcher
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/google/uuid"
+)
+
+// scheduleBatch bounds the due updates taken per scheduler run.
+const scheduleBatch = 100
+
+// takeDueScript removes and returns up to ARGV[2] members of KEYS[1] with a
+// score up to ARGV[1], each followed by its score, so concurrent schedulers
+// never take the same one.
+var takeDueScript = redis.NewScript(1, `
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "WITHSCORES", "LIMIT", 0, ARGV[2])
+local members = {}
+for i = 1, #due, 2 do
+	members[#members + 1] = due[i]
+end
+if #members > 0 then
+	redis.call("ZREM", KEYS[1], unpack(members))
+end
+return due`)
+
+// ScheduleUpdate stores an update to be published at, e.g. for a role
+// change taking effect at midnight. It needs the Scheduler; the update is
+// published by whichever watcher's scheduler finds it due first, on the
+// Redis server clock.
+func (w *Watcher) ScheduleUpdate(at time.Time) error {
+	if w.options.scheduleKey == "" {
+		return errors.New("rediswatcher: no Scheduler configured")
+	}
+
+	// the nonce keeps updates for the same time apart in the set
+	msg := encodeMessage(Message{Type: MessageTypeUpdate, ID: w.options.LocalID, Nonce: uuid.New().String()})
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("ZADD", w.options.scheduleKey, at.UnixNano()/int64(time.Millisecond), msg)
+	return err
+}
+
+func (w *Watcher) startScheduler() {
+	if w.options.scheduleKey == "" || w.options.scheduleInterval <= 0 {
+		return
+	}
+
+	w.spawn(func() {
+		ticker := w.clock().NewTicker(w.options.scheduleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+				if _, err := w.publishDue(); err != nil {
+					w.reportError(err)
+				}
+			}
+		}
+	})
+}
+
+// publishDue publishes the scheduled updates that are due and returns how
+// many it published. Updates failing to publish are put back at their time,
+// for the next run, and the first failure is returned.
+func (w *Watcher) publishDue() (int, error) {
+	now := w.serverNow().UnixNano() / int64(time.Millisecond)
+
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return 0, err
+	}
+	due, err := redis.Strings(takeDueScript.Do(c, w.options.scheduleKey, now, scheduleBatch))
+	if err != nil {
+		return 0, err
+	}
+
+	var published int
+	var failed []interface{}
+	for i := 0; i+1 < len(due); i += 2 {
+		msg, score := due[i], due[i+1]
+		if pubErr := w.publishOrQueue(msg); pubErr != nil {
+			if err == nil {
+				err = pubErr
+			}
+			failed = append(failed, score, msg)
+			continue
+		}
+		published++
+	}
+	if len(failed) > 0 {
+		c, addErr := w.publisher()
+		if addErr == nil {
+			_, addErr = c.Do("ZADD", append([]interface{}{w.options.scheduleKey}, failed...)...)
+		}
+		if addErr != nil {
+			w.reportError(fmt.Errorf("rediswatcher: %d scheduled updates lost: %v", len(failed)/2, addErr))
+		}
+	}
+	return published, err
+}
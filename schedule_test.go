@@ -0,0 +1,67 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestScheduleUpdate(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("ZADD").Expect(int64(1))
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), Scheduler("casbin:scheduled", time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	midnight := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := w.ScheduleUpdate(midnight); err != nil {
+		t.Fatalf("Failed watcher.ScheduleUpdate(): %v", err)
+	}
+	zadd := pub.calls("ZADD")
+	if len(zadd) != 1 || zadd[0][0] != "casbin:scheduled" || zadd[0][1] != midnight.UnixNano()/int64(time.Millisecond) {
+		t.Fatalf("Update should be added to the set at its time, got %v", zadd)
+	}
+	if m, ok := decodeMessage(zadd[0][2].(string)); !ok || m.ID != "node1" || m.Nonce == "" {
+		t.Fatalf("Scheduled update should be a unique update message, got %v", zadd[0][2])
+	}
+
+	pub.GenericCommand("EVALSHA").Expect([]interface{}{zadd[0][2], "1893456000000"})
+	if n, err := w.publishDue(); err != nil || n != 1 {
+		t.Fatalf("Due update should be published, got %d, %v", n, err)
+	}
+	if calls := pub.calls("PUBLISH"); len(calls) != 1 || calls[0][1] != zadd[0][2] {
+		t.Fatalf("The scheduled message should be published, got %v", calls)
+	}
+}
+
+func TestPublishDueFailure(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), Scheduler("casbin:scheduled", time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	pub.GenericCommand("EVALSHA").Expect([]interface{}{"m1", "1", "m2", "2", "m3", "3"})
+	first := pub.Command("PUBLISH", "/casbin", "m1").Expect(int64(1))
+	pub.Command("PUBLISH", "/casbin", "m2").ExpectError(fmt.Errorf("connection refused"))
+	third := pub.Command("PUBLISH", "/casbin", "m3").Expect(int64(1))
+	readd := pub.Command("ZADD", "casbin:scheduled", "2", "m2").Expect(int64(1))
+
+	if n, err := w.publishDue(); err == nil || n != 2 {
+		t.Fatalf("Expected 2 updates published and the failure, got %d, %v", n, err)
+	}
+	if pub.Stats(first) != 1 || pub.Stats(third) != 1 {
+		t.Fatal("The updates after a failed one should still be published")
+	}
+	if pub.Stats(readd) != 1 {
+		t.Fatal("The failed update should be put back at its time")
+	}
+}
@@ -0,0 +1,232 @@
+package rediswatcher
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// subShard is a further subscription connection carrying part of the
+// channels, see SubscriptionShards, or every channel of a federated server,
+// see FederatedServers.
+type subShard struct {
+	index    int
+	addr     string // federated server, empty for a shard of the watcher address
+	mu       sync.Mutex
+	conn     redis.Conn // open subscription, nil while disconnected
+	wake     chan struct{}
+	attempts int // reconnects since the last subscription, used by its loop only
+}
+
+func (s *subShard) getConn() redis.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+func (s *subShard) setConn(c redis.Conn) {
+	s.mu.Lock()
+	s.conn = c
+	s.mu.Unlock()
+}
+
+func (s *subShard) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// shardOf returns the connection carrying channel. The watcher channel and
+// patterns always use the first one.
+func (w *Watcher) shardOf(channel string) int {
+	n := w.options.SubscriptionShards
+	if n <= 1 || channel == w.channel() {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(channel))
+	return int(h.Sum32() % uint32(n))
+}
+
+// channelsFor returns the channels of shard.
+func (w *Watcher) channelsFor(shard int) []interface{} {
+	var channels []interface{}
+	for _, channel := range w.channels() {
+		if w.shardOf(channel.(string)) == shard {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// startShards starts a subscription loop for every shard after the first,
+// which is served by the regular subscription.
+func (w *Watcher) startShards() {
+	for i := 1; i < w.options.SubscriptionShards; i++ {
+		s := &subShard{index: i, wake: make(chan struct{}, 1)}
+		w.shards = append(w.shards, s)
+	}
+	for _, s := range w.shards {
+		w.runShard(s)
+	}
+}
+
+// runShard keeps s subscribed, reconnecting after failures until the
+// watcher is closed.
+func (w *Watcher) runShard(s *subShard) {
+	w.spawn(func() {
+		for !w.isClosed() {
+			if err := w.subscribeShard(s); err != nil && !w.isClosed() {
+				w.reportError(err)
+			}
+			var retry <-chan time.Time
+			if !w.isUnsubscribed() && len(w.shardChannels(s)) > 0 {
+				retry = w.clock().After(w.reconnectDelay(s.attempts))
+				s.attempts++
+			}
+			select {
+			case <-w.closed:
+			case <-s.wake:
+			case <-retry:
+			}
+		}
+	})
+}
+
+// shardChannels returns the channels s subscribes.
+func (w *Watcher) shardChannels(s *subShard) []interface{} {
+	if s.addr != "" {
+		return w.channels()
+	}
+	return w.channelsFor(s.index)
+}
+
+// subscribeShard subscribes the channels of s on a connection of its own
+// until the subscription ends.
+func (w *Watcher) subscribeShard(s *subShard) error {
+	channels := w.shardChannels(s)
+	if len(channels) == 0 || w.isUnsubscribed() {
+		return nil
+	}
+
+	addr := s.addr
+	if addr == "" {
+		addr = w.addr
+	}
+	dial := w.options.shardDialer
+	if dial == nil {
+		dial = func(addr string) (redis.Conn, error) {
+			c, err := w.dial(addr)
+			if err != nil {
+				return nil, err
+			}
+			return *c, nil
+		}
+	}
+	c, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	psc := redis.PubSubConn{Conn: c}
+	w.subMu.Lock()
+	// SubscribeChannel calls after this find the connection set
+	if channels = w.shardChannels(s); len(channels) == 0 {
+		w.subMu.Unlock()
+		return nil
+	}
+	err = psc.Subscribe(channels...)
+	if err == nil && s.addr != "" && len(w.options.patterns) > 0 {
+		err = psc.PSubscribe(w.patterns()...)
+	}
+	if err == nil {
+		s.setConn(c)
+	}
+	w.subMu.Unlock()
+	if err != nil {
+		return err
+	}
+	defer s.setConn(nil)
+	s.attempts = 0
+	resetRetry(w.options.ReconnectStrategy)
+	if w.isClosed() {
+		return nil
+	}
+
+	for {
+		startTime := time.Now()
+		switch n := psc.Receive().(type) {
+		case error:
+			return wrapError(ErrSubscribeClosed, n)
+		case redis.Message:
+			if !w.received(startTime, n) {
+				return nil
+			}
+		case redis.PMessage:
+			if !w.received(startTime, redis.Message{Channel: n.Channel, Data: n.Data}) {
+				return nil
+			}
+		case redis.Subscription:
+			if n.Count == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// subscriptionConn returns the open subscription connection carrying
+// channel, or nil while it is disconnected; a disconnected shard is woken
+// to subscribe the current channels. Callers must hold subMu.
+func (w *Watcher) subscriptionConn(channel string) redis.Conn {
+	i := w.shardOf(channel)
+	if i == 0 || len(w.shards) < i {
+		if w.isSubscribed() {
+			return w.subConn
+		}
+		return nil
+	}
+	s := w.shards[i-1]
+	c := s.getConn()
+	if c == nil {
+		s.signal()
+	}
+	return c
+}
+
+// leaveShards ends the subscriptions of every shard and federated server.
+// Callers must hold subMu.
+func (w *Watcher) leaveShards() {
+	for _, s := range w.allShards() {
+		if c := s.getConn(); c != nil {
+			psc := redis.PubSubConn{Conn: c}
+			psc.Unsubscribe()
+			if s.addr != "" && len(w.options.patterns) > 0 {
+				psc.PUnsubscribe()
+			}
+		}
+	}
+}
+
+// wakeShards lets disconnected shards subscribe again.
+func (w *Watcher) wakeShards() {
+	for _, s := range w.allShards() {
+		s.signal()
+	}
+}
+
+// closeShards closes the shard connections, failing pending receives.
+func (w *Watcher) closeShards() {
+	for _, s := range w.allShards() {
+		if c := s.getConn(); c != nil {
+			c.Close()
+		}
+	}
+}
+
+func (w *Watcher) allShards() []*subShard {
+	return append(w.shards[:len(w.shards):len(w.shards)], w.federated...)
+}
@@ -0,0 +1,40 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestSubscriptionShards(t *testing.T) {
+	var channels []string
+	for i := 0; i < 10; i++ {
+		channels = append(channels, fmt.Sprintf("/casbin/t%d", i))
+	}
+	shardConn := NewTestConn()
+	w := &Watcher{closed: make(chan struct{}), messagesIn: make(chan redis.Message, 1)}
+	w.options.Channel = "/casbin"
+	Channels(channels...)(&w.options)
+	SubscriptionShards(2)(&w.options)
+	w.options.shardDialer = func(string) (redis.Conn, error) { return shardConn, nil }
+
+	first, second := w.channelsFor(0), w.channelsFor(1)
+	if len(first)+len(second) != 11 || first[0] != "/casbin" || len(second) == 0 {
+		t.Fatalf("Channels should be spread over the shards, got %v and %v", first, second)
+	}
+
+	w.shards = []*subShard{{index: 1, wake: make(chan struct{}, 1)}}
+	shardConn.Command("SUBSCRIBE", second...).Expect([]interface{}{[]byte("message"), second[0], []byte("node2")})
+	if err := w.subscribeShard(w.shards[0]); err == nil {
+		t.Fatal("The mock shard subscription should end with an error")
+	}
+	select {
+	case msg := <-w.messagesIn:
+		if msg.Channel != second[0] || string(msg.Data) != "node2" {
+			t.Fatalf("Unexpected message %+v", msg)
+		}
+	default:
+		t.Fatal("Messages of the shard should be received")
+	}
+}
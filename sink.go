@@ -0,0 +1,80 @@
+package rediswatcher
+
+import "time"
+
+// MetricsSink receives watcher metrics for exporters such as StatsD or
+// Datadog, without depending on a metrics library. Names are the metric
+// constants, e.g. PubSubPublishMetric, tags hold the channel and LocalID.
+type MetricsSink interface {
+	Count(name string, value int64, tags map[string]string)
+	Gauge(name string, value float64, tags map[string]string)
+	Timing(name string, d time.Duration, tags map[string]string)
+}
+
+// Gauges reported to a MetricsSink.
+const (
+	QueuedUpdatesGauge  = "QueuedUpdates"
+	RunningUpdatesGauge = "RunningUpdates"
+	EarlyMessagesGauge  = "EarlyMessages"
+	DroppedUpdatesGauge = "DroppedUpdates"
+	SubscribedGauge     = "Subscribed"
+)
+
+// applyMetricsSink feeds the sink from RecordMetrics, keeping a hook set by
+// RecordMetrics.
+func (w *Watcher) applyMetricsSink() {
+	sink := w.options.metricsSink
+	if sink == nil {
+		return
+	}
+
+	record := w.options.RecordMetrics
+	w.options.RecordMetrics = func(m *WatcherMetrics) {
+		if record != nil {
+			record(m)
+		}
+		tags := map[string]string{"channel": m.Channel, "local_id": m.LocalID}
+		sink.Count(m.Name, 1, tags)
+		if m.Error != nil {
+			sink.Count(m.Name+".error", 1, tags)
+		}
+		sink.Timing(m.Name, time.Duration(m.LatencyMs*float64(time.Millisecond)), tags)
+	}
+}
+
+// startGaugeReports reports the queue and subscription gauges to the
+// MetricsSink every metricsInterval.
+func (w *Watcher) startGaugeReports() {
+	if w.options.metricsSink == nil || w.options.metricsInterval <= 0 {
+		return
+	}
+
+	w.spawn(func() {
+		ticker := w.clock().NewTicker(w.options.metricsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+				w.reportGauges()
+			}
+		}
+	})
+}
+
+func (w *Watcher) reportGauges() {
+	s := w.Stats()
+	tags := map[string]string{"channel": w.channel(), "local_id": w.options.LocalID}
+	subscribed := 0.0
+	if s.Subscribed {
+		subscribed = 1
+	}
+
+	sink := w.options.metricsSink
+	sink.Gauge(QueuedUpdatesGauge, float64(s.QueuedUpdates), tags)
+	sink.Gauge(RunningUpdatesGauge, float64(s.RunningUpdates), tags)
+	sink.Gauge(EarlyMessagesGauge, float64(s.EarlyMessages), tags)
+	sink.Gauge(DroppedUpdatesGauge, float64(s.DroppedUpdates), tags)
+	sink.Gauge(SubscribedGauge, subscribed, tags)
+}
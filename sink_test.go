@@ -0,0 +1,67 @@
+package rediswatcher
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordSink struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	gauges map[string]float64
+	timing map[string]int
+}
+
+func newRecordSink() *recordSink {
+	return &recordSink{counts: map[string]int64{}, gauges: map[string]float64{}, timing: map[string]int{}}
+}
+
+func (s *recordSink) Count(name string, value int64, _ map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[name] += value
+}
+
+func (s *recordSink) Gauge(name string, value float64, _ map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gauges[name] = value
+}
+
+func (s *recordSink) Timing(name string, _ time.Duration, _ map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timing[name]++
+}
+
+func TestMetricsSink(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	sink := newRecordSink()
+	recorded := 0
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		RecordMetrics(func(m *WatcherMetrics) { recorded++ }), WithMetricsSink(sink, time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	if err := w.Update(); err != nil {
+		t.Fatalf("Failed watcher.Update(): %v", err)
+	}
+	w.reportGauges()
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.counts[PubSubPublishMetric] != 1 || sink.timing[PubSubPublishMetric] != 1 {
+		t.Fatalf("Sink should receive the publish, got %v and %v", sink.counts, sink.timing)
+	}
+	if recorded == 0 {
+		t.Fatal("RecordMetrics hook should still be called")
+	}
+	if _, ok := sink.gauges[QueuedUpdatesGauge]; !ok {
+		t.Fatalf("Sink should receive the gauges, got %v", sink.gauges)
+	}
+}
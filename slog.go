@@ -0,0 +1,15 @@
+//go:build go1.21
+// +build go1.21
+
+package rediswatcher
+
+import "log/slog"
+
+// WithSlog logs to logger at debug, info, warn and error level, see
+// WithLeveledLogger. A nil logger uses slog.Default().
+func WithSlog(logger *slog.Logger) WatcherOption {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return WithLeveledLogger(logger)
+}
@@ -0,0 +1,34 @@
+//go:build go1.21
+// +build go1.21
+
+package rediswatcher
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	fallback := &recordLogger{}
+
+	w := &Watcher{closed: make(chan struct{})}
+	for _, setter := range []WatcherOption{WithLogger(fallback), WithSlog(logger), LocalID("node1"),
+		CallbackRetry(1, 0, 0)} {
+		setter(&w.options)
+	}
+	w.SetUpdateCallbackWithError(func(string) error { return fmt.Errorf("load failed") })
+	w.runCallback("node2")
+
+	if out := buf.String(); !strings.Contains(out, "level=WARN") ||
+		!strings.Contains(out, "update callback failed, retrying") || !strings.Contains(out, "localId=node1") {
+		t.Fatalf("Retry should be logged at warn level, got %q", out)
+	}
+	if len(fallback.lines) != 0 {
+		t.Fatalf("Nothing should reach the Printf logger, got %q", fallback.lines)
+	}
+}
@@ -0,0 +1,123 @@
+package rediswatcher
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var errNoSnapshotKey = errors.New("rediswatcher: no PolicySnapshots key configured")
+
+// UpdateWithSnapshot stores snapshot, a serialized policy such as the rules
+// of the model encoded by the caller after SavePolicy, in Redis and
+// publishes an update pointing to it. Receivers get it with LoadSnapshot
+// and reload from it instead of the database.
+func (w *Watcher) UpdateWithSnapshot(snapshot []byte) error {
+	if w.options.snapshotKey == "" {
+		return errNoSnapshotKey
+	}
+	if w.suppressUpdate() {
+		return nil
+	}
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+	return w.storeSnapshot(snapshot)
+}
+
+// storeSnapshot stores snapshot and publishes the update pointing to it.
+func (w *Watcher) storeSnapshot(snapshot []byte) error {
+	key := w.snapshotVersionKey()
+	args := []interface{}{key, snapshot}
+	if w.options.snapshotTTL > 0 {
+		args = append(args, "PX", int64(w.options.snapshotTTL/time.Millisecond))
+	}
+	w.pubMu.Lock()
+	c, err := w.publisher()
+	if err == nil {
+		_, err = c.Do("SET", args...)
+	}
+	if err == nil {
+		err = w.pointSnapshot(c, key)
+	}
+	w.pubMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return w.publishMessage(Message{Type: MessageTypeUpdate, ID: w.options.LocalID, Snapshot: key})
+}
+
+// previousSnapshotTTL is how long the previous snapshot is kept without a
+// ttl, for receivers still handling the update pointing to it.
+const previousSnapshotTTL = time.Minute
+
+// pointSnapshot makes the snapshot key name key as the latest snapshot, see
+// LatestSnapshot. Without a ttl nothing else removes the previous one, so
+// it expires after previousSnapshotTTL; receivers fetching it later reload
+// from the database as after an expiry. Callers must hold pubMu.
+func (w *Watcher) pointSnapshot(c redis.Conn, key string) error {
+	if w.options.snapshotTTL > 0 {
+		_, err := c.Do("SET", w.options.snapshotKey, key)
+		return err
+	}
+	previous, err := redis.String(c.Do("GETSET", w.options.snapshotKey, key))
+	if err == redis.ErrNil || (err == nil && previous == key) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("PEXPIRE", previous, int64(previousSnapshotTTL/time.Millisecond))
+	return err
+}
+
+// snapshotVersionKey returns a new key for a snapshot.
+func (w *Watcher) snapshotVersionKey() string {
+	return w.options.snapshotKey + ":" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// LoadSnapshot returns the policy snapshot an update callback received msg
+// points to. It returns ErrNoSnapshot when msg has none or it expired, in
+// which case the callback loads the policy from the database as usual.
+// Snapshots of UpdateWithSnapshotStream are decompressed, see OpenSnapshot
+// to read them without holding them in memory.
+func (w *Watcher) LoadSnapshot(msg string) ([]byte, error) {
+	m, ok := decodeMessage(msg)
+	if !ok || m.Snapshot == "" {
+		return nil, ErrNoSnapshot
+	}
+
+	snapshot, err := w.getSnapshot(m.Snapshot)
+	return decompressSnapshot(m.Encoding, snapshot, err)
+}
+
+// LatestSnapshot returns the snapshot of the last UpdateWithSnapshot, or
+// ErrNoSnapshot when there is none or it expired.
+func (w *Watcher) LatestSnapshot() ([]byte, error) {
+	if w.options.snapshotKey == "" {
+		return nil, ErrNoSnapshot
+	}
+	key, err := w.getSnapshot(w.options.snapshotKey)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := w.getSnapshot(string(key))
+	return decompressSnapshot(latestSnapshotEncoding(string(key)), snapshot, err)
+}
+
+func (w *Watcher) getSnapshot(key string) ([]byte, error) {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := redis.Bytes(c.Do("GET", key))
+	if err == redis.ErrNil {
+		return nil, ErrNoSnapshot
+	}
+	return snapshot, err
+}
@@ -0,0 +1,79 @@
+package rediswatcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateWithSnapshot(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("SET").Expect("OK")
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), PolicySnapshots("casbin:snapshot", time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.UpdateWithSnapshot([]byte("p, alice, data1, read")); err != nil {
+		t.Fatalf("Failed watcher.UpdateWithSnapshot(): %v", err)
+	}
+	sets := pub.calls("SET")
+	if len(sets) != 2 || sets[0][2] != "PX" || sets[0][3] != int64(3600000) {
+		t.Fatalf("Snapshot should be stored with its ttl, got %v", sets)
+	}
+	key := sets[0][0].(string)
+	if !strings.HasPrefix(key, "casbin:snapshot:") || sets[1][0] != "casbin:snapshot" || sets[1][1] != key {
+		t.Fatalf("Snapshot should be stored under a versioned key named by the latest one, got %v", sets)
+	}
+	msg := pub.calls("PUBLISH")[0][1].(string)
+	if m, _ := decodeMessage(msg); m.Snapshot != key {
+		t.Fatalf("Update should point to the snapshot, got %s", msg)
+	}
+
+	pub.Command("GET", key).Expect([]byte("p, alice, data1, read"))
+	if snapshot, err := w.LoadSnapshot(msg); err != nil || string(snapshot) != "p, alice, data1, read" {
+		t.Fatalf("LoadSnapshot should return the snapshot, got %q, %v", snapshot, err)
+	}
+	pub.Command("GET", "casbin:snapshot").Expect([]byte(key))
+	if snapshot, err := w.LatestSnapshot(); err != nil || string(snapshot) != "p, alice, data1, read" {
+		t.Fatalf("LatestSnapshot should return the snapshot, got %q, %v", snapshot, err)
+	}
+	pub.Command("GET", key).Expect(nil)
+	if _, err := w.LoadSnapshot(msg); err != ErrNoSnapshot {
+		t.Fatalf("Expired snapshot should fail with ErrNoSnapshot, got %v", err)
+	}
+	if _, err := w.LoadSnapshot("node2"); err != ErrNoSnapshot {
+		t.Fatalf("Plain update should fail with ErrNoSnapshot, got %v", err)
+	}
+}
+
+func TestUpdateWithSnapshotNoTTL(t *testing.T) {
+	pub := newKVConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(NewTestConn()),
+		PolicySnapshots("casbin:snapshot", 0))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	for _, policy := range []string{"p, alice, data1, read", "p, bob, data2, write"} {
+		if err := w.UpdateWithSnapshot([]byte(policy)); err != nil {
+			t.Fatalf("Failed watcher.UpdateWithSnapshot(): %v", err)
+		}
+	}
+	if len(pub.keys) != 3 || len(pub.ttls) != 1 {
+		t.Fatalf("Without a ttl the previous snapshot should expire, got %d keys and ttls %v", len(pub.keys), pub.ttls)
+	}
+	for key, ttl := range pub.ttls {
+		if snapshot := string(pub.keys[key]); snapshot != "p, alice, data1, read" || ttl != int64(previousSnapshotTTL/time.Millisecond) {
+			t.Fatalf("The previous snapshot should expire after %v, got %s for %dms", previousSnapshotTTL, snapshot, ttl)
+		}
+	}
+	if snapshot, err := w.LatestSnapshot(); err != nil || string(snapshot) != "p, bob, data2, write" {
+		t.Fatalf("LatestSnapshot should return the snapshot, got %q, %v", snapshot, err)
+	}
+}
@@ -0,0 +1,236 @@
+package rediswatcher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// snapshotChunkSize is the size of the pieces streamed snapshots are stored
+// and fetched in, which bounds the memory they take.
+const snapshotChunkSize = 64 << 10
+
+var errSnapshotTruncated = errors.New("rediswatcher: snapshot expired while being read")
+
+// snapshotEncodingGzip is the Encoding of the snapshots of
+// UpdateWithSnapshotStream, whose keys end with gzipSnapshotSuffix so that
+// LatestSnapshot knows it too.
+const (
+	snapshotEncodingGzip = "gzip"
+	gzipSnapshotSuffix   = ".gz"
+)
+
+// UpdateWithSnapshotStream works like UpdateWithSnapshot for a snapshot read
+// from r, e.g. a policy encoded while it is written, so that a large policy
+// is never in memory as a whole. The snapshot is gzip-compressed and stored
+// in chunks.
+func (w *Watcher) UpdateWithSnapshotStream(r io.Reader) error {
+	if w.options.snapshotKey == "" {
+		return errNoSnapshotKey
+	}
+	if w.suppressUpdate() {
+		return nil
+	}
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+
+	key := w.snapshotVersionKey() + gzipSnapshotSuffix
+	buf := bufio.NewWriterSize(snapshotWriter{w: w, key: key}, snapshotChunkSize)
+	zw := gzip.NewWriter(buf)
+	_, err := io.Copy(zw, r)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	w.pubMu.Lock()
+	c, connErr := w.publisher()
+	if err == nil {
+		err = connErr
+	}
+	if err == nil && w.options.snapshotTTL > 0 {
+		_, err = c.Do("PEXPIRE", key, int64(w.options.snapshotTTL/time.Millisecond))
+	}
+	if err == nil {
+		err = w.pointSnapshot(c, key)
+	} else if connErr == nil {
+		c.Do("DEL", key)
+	}
+	w.pubMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return w.publishMessage(Message{Type: MessageTypeUpdate, ID: w.options.LocalID, Snapshot: key, Encoding: snapshotEncodingGzip})
+}
+
+// OpenSnapshot returns a reader streaming the snapshot msg points to, for
+// decoding it incrementally, e.g. line by line with a bufio.Scanner. It is
+// fetched chunk by chunk while being read and decompressed when it was
+// stored by UpdateWithSnapshotStream. It returns ErrNoSnapshot like
+// LoadSnapshot, and reading fails when the snapshot expires meanwhile.
+func (w *Watcher) OpenSnapshot(msg string) (io.ReadCloser, error) {
+	m, ok := decodeMessage(msg)
+	if !ok || m.Snapshot == "" {
+		return nil, ErrNoSnapshot
+	}
+	return w.openSnapshot(m.Snapshot, m.Encoding)
+}
+
+// OpenLatestSnapshot works like OpenSnapshot for the snapshot of the last
+// update, see LatestSnapshot.
+func (w *Watcher) OpenLatestSnapshot() (io.ReadCloser, error) {
+	if w.options.snapshotKey == "" {
+		return nil, ErrNoSnapshot
+	}
+	key, err := w.getSnapshot(w.options.snapshotKey)
+	if err != nil {
+		return nil, err
+	}
+	return w.openSnapshot(string(key), latestSnapshotEncoding(string(key)))
+}
+
+func (w *Watcher) openSnapshot(key, encoding string) (io.ReadCloser, error) {
+	if err := checkSnapshotEncoding(encoding); err != nil {
+		return nil, err
+	}
+	r := &snapshotReader{w: w, key: key}
+	if err := r.stat(); err != nil {
+		return nil, err
+	}
+	if r.size == 0 {
+		return nil, ErrNoSnapshot
+	}
+	if err := r.fetch(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if encoding == "" {
+		return ioutil.NopCloser(r), nil
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+// decompressSnapshot decompresses the snapshot fetched by getSnapshot
+// according to its encoding.
+func decompressSnapshot(encoding string, snapshot []byte, err error) ([]byte, error) {
+	if err == nil {
+		err = checkSnapshotEncoding(encoding)
+	}
+	if err != nil || encoding == "" {
+		return snapshot, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(snapshot))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(zr)
+}
+
+func checkSnapshotEncoding(encoding string) error {
+	if encoding != "" && encoding != snapshotEncodingGzip {
+		return fmt.Errorf("rediswatcher: unknown snapshot encoding %q", encoding)
+	}
+	return nil
+}
+
+// latestSnapshotEncoding returns the encoding of the snapshot at key, which
+// the latest snapshot key points to.
+func latestSnapshotEncoding(key string) string {
+	if strings.HasSuffix(key, gzipSnapshotSuffix) {
+		return snapshotEncodingGzip
+	}
+	return ""
+}
+
+// snapshotWriter appends what is written to the snapshot at key.
+type snapshotWriter struct {
+	w   *Watcher
+	key string
+}
+
+func (s snapshotWriter) Write(p []byte) (int, error) {
+	s.w.pubMu.Lock()
+	defer s.w.pubMu.Unlock()
+	c, err := s.w.publisher()
+	if err == nil {
+		_, err = c.Do("APPEND", s.key, p)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// snapshotReader reads the snapshot at key a chunk at a time. size is the
+// length of the snapshot when it was opened, which tells a complete read
+// from one cut short by the snapshot expiring.
+type snapshotReader struct {
+	w      *Watcher
+	key    string
+	size   int
+	offset int
+	buf    []byte
+	err    error
+}
+
+func (r *snapshotReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 && r.err == nil {
+		r.err = r.fetch()
+	}
+	if len(r.buf) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// stat gets the size of the snapshot, 0 when there is none.
+func (r *snapshotReader) stat() error {
+	r.w.pubMu.Lock()
+	defer r.w.pubMu.Unlock()
+	c, err := r.w.publisher()
+	if err != nil {
+		return err
+	}
+	r.size, err = redis.Int(c.Do("STRLEN", r.key))
+	return err
+}
+
+// fetch gets the next chunk into buf, reporting io.EOF after the last one.
+func (r *snapshotReader) fetch() error {
+	r.w.pubMu.Lock()
+	defer r.w.pubMu.Unlock()
+	c, err := r.w.publisher()
+	if err != nil {
+		return err
+	}
+	chunk, err := redis.Bytes(c.Do("GETRANGE", r.key, r.offset, r.offset+snapshotChunkSize-1))
+	if err != nil {
+		return err
+	}
+	r.buf = chunk
+	r.offset += len(chunk)
+	if len(chunk) < snapshotChunkSize {
+		r.err = io.EOF
+		if r.offset != r.size {
+			r.err = errSnapshotTruncated
+		}
+		return r.err
+	}
+	return nil
+}
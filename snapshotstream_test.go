@@ -0,0 +1,226 @@
+package rediswatcher
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// kvConn keeps string keys and lists in memory, with the commands of
+// snapshots and list queues.
+type kvConn struct {
+	mu       sync.Mutex
+	keys     map[string][]byte
+	lists    map[string][][]byte
+	ttls     map[string]int64 // milliseconds, from PEXPIRE
+	commands map[string]int
+	messages []string // published
+}
+
+func newKVConn() *kvConn {
+	return &kvConn{keys: map[string][]byte{}, lists: map[string][][]byte{}, ttls: map[string]int64{}, commands: map[string]int{}}
+}
+
+func (c *kvConn) Do(command string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands[command]++
+	var key string
+	if len(args) > 0 {
+		key, _ = args[0].(string)
+	}
+	switch command {
+	case "APPEND":
+		c.keys[key] = append(c.keys[key], args[1].([]byte)...)
+		return int64(len(c.keys[key])), nil
+	case "GETRANGE":
+		v := c.keys[key]
+		start, end := args[1].(int), args[2].(int)+1
+		if start > len(v) {
+			start = len(v)
+		}
+		if end > len(v) {
+			end = len(v)
+		}
+		return append([]byte{}, v[start:end]...), nil
+	case "GET":
+		if v, ok := c.keys[key]; ok {
+			return v, nil
+		}
+		return nil, nil
+	case "GETSET":
+		v, ok := c.keys[key]
+		c.keys[key] = []byte(args[1].(string))
+		if !ok {
+			return nil, nil
+		}
+		return v, nil
+	case "SET":
+		if v, ok := args[1].([]byte); ok {
+			c.keys[key] = v
+		} else {
+			c.keys[key] = []byte(args[1].(string))
+		}
+		return "OK", nil
+	case "RPUSH":
+		c.lists[key] = append(c.lists[key], args[1].([]byte))
+		return int64(len(c.lists[key])), nil
+	case "LPUSH":
+		c.lists[key] = append([][]byte{args[1].([]byte)}, c.lists[key]...)
+		return int64(len(c.lists[key])), nil
+	case "LLEN":
+		return int64(len(c.lists[key])), nil
+	case "LPOP":
+		l := c.lists[key]
+		if len(l) == 0 {
+			return nil, nil
+		}
+		c.lists[key] = l[1:]
+		return l[0], nil
+	case "PUBLISH":
+		c.messages = append(c.messages, args[1].(string))
+		return int64(1), nil
+	case "DEL":
+		for _, k := range args {
+			delete(c.keys, k.(string))
+			delete(c.lists, k.(string))
+		}
+		return int64(len(args)), nil
+	case "PEXPIRE":
+		c.ttls[key] = args[1].(int64)
+		return int64(1), nil
+	case "STRLEN":
+		return int64(len(c.keys[key])), nil
+	}
+	return nil, errors.New("unsupported command " + command)
+}
+
+func (c *kvConn) Close() error                      { return nil }
+func (c *kvConn) Err() error                        { return nil }
+func (c *kvConn) Send(string, ...interface{}) error { return nil }
+func (c *kvConn) Flush() error                      { return nil }
+func (c *kvConn) Receive() (interface{}, error)     { return nil, redis.ErrNil }
+
+func (c *kvConn) count(command string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.commands[command]
+}
+
+// randomPolicy generates n distinct rules, incompressible enough to take
+// several chunks.
+func randomPolicy(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "p, user%d, data%x, read\n", i, i*2654435761)
+	}
+	return b.String()
+}
+
+func TestUpdateWithSnapshotStream(t *testing.T) {
+	pub := newKVConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(NewTestConn()),
+		LocalID("node1"), PolicySnapshots("casbin:snapshot", time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	policy := randomPolicy(100000)
+	if err := w.UpdateWithSnapshotStream(strings.NewReader(policy)); err != nil {
+		t.Fatalf("Failed watcher.UpdateWithSnapshotStream(): %v", err)
+	}
+	if n := pub.count("APPEND"); n < 2 {
+		t.Fatalf("Snapshot should be stored in chunks, got %d APPEND", n)
+	}
+	if pub.count("PEXPIRE") != 1 {
+		t.Fatal("Snapshot should get the ttl")
+	}
+	if m, _ := decodeMessage(pub.messages[0]); m.Encoding != "gzip" {
+		t.Fatalf("The update should tell the snapshot is compressed, got %s", pub.messages[0])
+	}
+
+	r, err := w.OpenLatestSnapshot()
+	if err != nil {
+		t.Fatalf("Failed watcher.OpenLatestSnapshot(): %v", err)
+	}
+	defer r.Close()
+	scanner := bufio.NewScanner(r)
+	lines := 0
+	for scanner.Scan() {
+		if want := fmt.Sprintf("p, user%d, data%x, read", lines, lines*2654435761); scanner.Text() != want {
+			t.Fatalf("Line %d: expected %q, got %q", lines, want, scanner.Text())
+		}
+		lines++
+	}
+	if err := scanner.Err(); err != nil || lines != 100000 {
+		t.Fatalf("Expected 100000 rules, got %d: %v", lines, err)
+	}
+	if n := pub.count("GETRANGE"); n < 2 {
+		t.Fatalf("Snapshot should be fetched in chunks, got %d GETRANGE", n)
+	}
+
+	snapshot, err := w.LatestSnapshot()
+	if err != nil || string(snapshot) != policy {
+		t.Fatalf("LatestSnapshot should decompress the snapshot, got %d bytes: %v", len(snapshot), err)
+	}
+}
+
+func TestOpenSnapshot(t *testing.T) {
+	pub := newKVConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(NewTestConn()),
+		PolicySnapshots("casbin:snapshot", 0))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	// snapshots of UpdateWithSnapshot are streamed as stored
+	pub.keys["casbin:snapshot:1"] = []byte("p, alice, data1, read")
+	msg := encodeMessage(Message{Type: MessageTypeUpdate, ID: "node2", Snapshot: "casbin:snapshot:1"})
+	r, err := w.OpenSnapshot(msg)
+	if err != nil {
+		t.Fatalf("Failed watcher.OpenSnapshot(): %v", err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || string(b) != "p, alice, data1, read" {
+		t.Fatalf("Expected the snapshot, got %q: %v", b, err)
+	}
+
+	// only the Encoding tells a snapshot is compressed
+	pub.keys["casbin:snapshot:1"] = []byte{0x1f, 0x8b, 'p'}
+	if snapshot, err := w.LoadSnapshot(msg); err != nil || string(snapshot) != "\x1f\x8bp" {
+		t.Fatalf("Expected the snapshot as stored, got %q: %v", snapshot, err)
+	}
+	msg = encodeMessage(Message{Type: MessageTypeUpdate, ID: "node2", Snapshot: "casbin:snapshot:1", Encoding: "zstd"})
+	if _, err := w.OpenSnapshot(msg); err == nil {
+		t.Fatal("Unknown encodings should be rejected")
+	}
+
+	if _, err := w.OpenSnapshot("node2"); err != ErrNoSnapshot {
+		t.Fatalf("Plain update should fail with ErrNoSnapshot, got %v", err)
+	}
+	msg = encodeMessage(Message{Type: MessageTypeUpdate, ID: "node2", Snapshot: "casbin:snapshot:2"})
+	if _, err := w.OpenSnapshot(msg); err != ErrNoSnapshot {
+		t.Fatalf("Expired snapshot should fail with ErrNoSnapshot, got %v", err)
+	}
+
+	// a snapshot expiring while being read is not returned truncated
+	pub.keys["casbin:snapshot:2"] = []byte(randomPolicy(10000))
+	r, err = w.OpenSnapshot(msg)
+	if err != nil {
+		t.Fatalf("Failed watcher.OpenSnapshot(): %v", err)
+	}
+	pub.mu.Lock()
+	delete(pub.keys, "casbin:snapshot:2")
+	pub.mu.Unlock()
+	if _, err := ioutil.ReadAll(r); err != errSnapshotTruncated {
+		t.Fatalf("Expected the read to fail, got %v", err)
+	}
+}
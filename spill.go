@@ -0,0 +1,164 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// spillState tracks the updates spilled to the OverflowSpillList. count,
+// accessed atomically, only drops once a spilled update is back in the
+// queue, so new updates keep going to the list behind it until it is empty.
+type spillState struct {
+	count int64
+	mu    sync.Mutex // orders spilling against the queue
+	ready chan struct{}
+}
+
+// spilledJob is a job as stored in the OverflowSpillList.
+type spilledJob struct {
+	Msg      string    `json:"msg"`
+	Batch    []string  `json:"batch,omitempty"`
+	Channel  string    `json:"channel,omitempty"`
+	Control  string    `json:"control,omitempty"`
+	Delivery *Delivery `json:"delivery,omitempty"`
+}
+
+// OverflowSpillList makes updates that find the callback queue full go to
+// the Redis list key instead, to be handed to the callbacks in order as the
+// workers catch up. A paused or very slow consumer then keeps every update
+// without unbounded memory. Use a key unique to the instance, e.g. ending
+// in its LocalID; updates still spilled when the watcher closes stay there.
+// When Redis can't take them the queue blocks as with OverflowBlock.
+func OverflowSpillList(key string) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.OverflowPolicy = OverflowSpill
+		options.OverflowSpillList = key
+	}
+}
+
+// spillJob stores j in the OverflowSpillList when the queue is full or
+// updates are already spilled, and reports whether it did.
+func (w *Watcher) spillJob(j job) bool {
+	w.spill.mu.Lock()
+	defer w.spill.mu.Unlock()
+
+	if atomic.LoadInt64(&w.spill.count) == 0 {
+		select {
+		case w.jobs <- j:
+			return true
+		default:
+		}
+	}
+
+	s := spilledJob{Msg: j.msg, Batch: j.batch, Channel: j.channel, Control: j.control}
+	if j.delivery.Channel != "" {
+		s.Delivery = &j.delivery
+	}
+	b, _ := json.Marshal(s)
+	w.pubMu.Lock()
+	c, err := w.publisher()
+	if err == nil {
+		_, err = c.Do("RPUSH", w.options.OverflowSpillList, b)
+	}
+	w.pubMu.Unlock()
+	if err != nil {
+		w.reportError(err)
+		return false
+	}
+
+	atomic.AddInt64(&w.spill.count, 1)
+	w.signalSpill()
+	return true
+}
+
+// startSpillDrain moves spilled updates back into the queue as it frees up.
+func (w *Watcher) startSpillDrain() {
+	if w.options.OverflowPolicy != OverflowSpill {
+		return
+	}
+
+	w.spill.ready = make(chan struct{}, 1)
+	w.spawn(func() {
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-w.spill.ready:
+			}
+			w.drainSpill()
+		}
+	})
+}
+
+// drainSpill hands the spilled updates to the queue until the list is empty.
+// When Redis fails it tries again after the resubscribe threshold.
+func (w *Watcher) drainSpill() {
+	for atomic.LoadInt64(&w.spill.count) > 0 {
+		b, err := w.unspill()
+		if err == redis.ErrNil {
+			// the list was cleared behind our back
+			atomic.StoreInt64(&w.spill.count, 0)
+			return
+		}
+		if err != nil {
+			w.reportError(err)
+			w.clock().AfterFunc(w.resubscribeThreshold(), w.signalSpill)
+			return
+		}
+
+		var s spilledJob
+		if err := json.Unmarshal(b, &s); err != nil {
+			w.reportError(err)
+		} else {
+			j := job{msg: s.Msg, batch: s.Batch, channel: s.Channel, control: s.Control}
+			if s.Delivery != nil {
+				j.delivery = *s.Delivery
+			}
+			select {
+			case w.jobs <- j:
+			case <-w.closed:
+				// keep it first in the list for the next start
+				w.respill(b)
+				return
+			}
+		}
+		w.spill.mu.Lock()
+		atomic.AddInt64(&w.spill.count, -1)
+		w.spill.mu.Unlock()
+	}
+}
+
+// unspill takes the oldest update from the OverflowSpillList.
+func (w *Watcher) unspill() ([]byte, error) {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return nil, err
+	}
+	return redis.Bytes(c.Do("LPOP", w.options.OverflowSpillList))
+}
+
+// respill puts b back at the head of the OverflowSpillList.
+func (w *Watcher) respill(b []byte) {
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err == nil {
+		_, err = c.Do("LPUSH", w.options.OverflowSpillList, b)
+	}
+	if err != nil {
+		w.reportError(err)
+	}
+}
+
+// signalSpill wakes the drain.
+func (w *Watcher) signalSpill() {
+	select {
+	case w.spill.ready <- struct{}{}:
+	default:
+	}
+}
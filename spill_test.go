@@ -0,0 +1,96 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverflowSpillList(t *testing.T) {
+	kv := newKVConn()
+	w := &Watcher{closed: make(chan struct{})}
+	WithRedisPubConnection(kv)(&w.options)
+	CallbackWorkers(1)(&w.options)
+	CallbackQueueSize(1)(&w.options)
+	OverflowSpillList("casbin:spill:node1")(&w.options)
+	w.startCallbackWorkers()
+	defer w.Close()
+
+	release := make(chan struct{})
+	got := make(chan string, 8)
+	w.SetUpdateCallback(func(msg string) {
+		if msg == "node1" {
+			<-release
+		}
+		got <- msg
+	})
+	w.dispatch("node1")
+	for len(w.jobs) > 0 {
+		time.Sleep(time.Millisecond) // until the worker is stuck on node1
+	}
+	for _, msg := range []string{"node2", "node3", "node4", "node5"} {
+		w.dispatch(msg)
+	}
+
+	if s := w.Stats(); s.QueuedUpdates != 1 || s.SpilledUpdates != 3 || s.DroppedUpdates != 0 {
+		t.Fatalf("Expected 1 queued and 3 spilled updates, got %+v", s)
+	}
+	kv.mu.Lock()
+	spilled := len(kv.lists["casbin:spill:node1"])
+	kv.mu.Unlock()
+	if spilled != 3 {
+		t.Fatalf("Expected 3 updates in the spill list, got %d", spilled)
+	}
+
+	close(release)
+	for _, want := range []string{"node1", "node2", "node3", "node4", "node5"} {
+		select {
+		case msg := <-got:
+			if msg != want {
+				t.Fatalf("Expected %s, got %s", want, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s was not delivered", want)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for w.Stats().SpilledUpdates != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("The spill list should be drained, got %d", w.Stats().SpilledUpdates)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOverflowSpillListKeepsUpdatesOnClose(t *testing.T) {
+	kv := newKVConn()
+	w := &Watcher{closed: make(chan struct{}), jobs: make(chan job)}
+	WithRedisPubConnection(kv)(&w.options)
+	OverflowSpillList("casbin:spill:node1")(&w.options)
+
+	d := Delivery{Channel: "/casbin", ReceivedAt: time.Unix(1700000000, 0).UTC()}
+	if !w.spillJob(job{msg: "node2", delivery: d}) {
+		t.Fatal("The update should be spilled")
+	}
+
+	// closing while the queue is full puts the update back
+	close(w.closed)
+	w.drainSpill()
+	kv.mu.Lock()
+	spilled := len(kv.lists["casbin:spill:node1"])
+	kv.mu.Unlock()
+	if spilled != 1 || w.Stats().SpilledUpdates != 1 {
+		t.Fatalf("Expected the update to stay in the spill list, got %d", spilled)
+	}
+
+	w.closed = make(chan struct{})
+	w.jobs = make(chan job, 1)
+	w.drainSpill()
+	select {
+	case j := <-w.jobs:
+		if j.msg != "node2" || j.delivery.Channel != d.Channel || !j.delivery.ReceivedAt.Equal(d.ReceivedAt) {
+			t.Fatalf("Expected node2 with its delivery, got %+v", j)
+		}
+	default:
+		t.Fatal("The spilled update should be queued")
+	}
+}
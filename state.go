@@ -0,0 +1,94 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// connectionState tracks the subscription of a watcher, guarded by stateMu.
+type connectionState struct {
+	subscribed     bool
+	disconnectedAt time.Time
+	stale          bool
+	unsubscribed   bool // left the channel on request, see Watcher.Unsubscribe
+	publishFailing bool // the last publish failed
+	lastError      error
+	lastErrorAt    time.Time
+	errors         []ErrorRecord // recent errors, see Watcher.Errors
+	lastReconnect  time.Duration
+	totalReconnect time.Duration
+}
+
+// setSubscribed records the subscription state and reports whether it
+// changed.
+func (w *Watcher) setSubscribed(subscribed bool) bool {
+	w.stateMu.Lock()
+	if w.state.subscribed == subscribed {
+		w.stateMu.Unlock()
+		return false
+	}
+	w.state.subscribed = subscribed
+	recovered := false
+	if subscribed {
+		if atomic.AddInt64(&w.counters.subscriptions, 1) > 1 && !w.state.disconnectedAt.IsZero() {
+			w.state.lastReconnect = w.clock().Now().Sub(w.state.disconnectedAt)
+			w.state.totalReconnect += w.state.lastReconnect
+		}
+		recovered = w.state.stale
+		w.state.stale = false
+	} else {
+		w.state.disconnectedAt = w.clock().Now()
+	}
+	w.stateMu.Unlock()
+
+	if recovered && w.options.staleRecoveredCallback != nil {
+		w.options.staleRecoveredCallback()
+	}
+	return true
+}
+
+func (w *Watcher) isSubscribed() bool {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	return w.state.subscribed
+}
+
+func (w *Watcher) isUnsubscribed() bool {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	return w.state.unsubscribed
+}
+
+func (w *Watcher) startStalenessMonitor() {
+	if w.options.staleThreshold <= 0 || w.options.staleCallback == nil {
+		return
+	}
+
+	w.spawn(func() {
+		ticker := w.clock().NewTicker(w.options.staleThreshold / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+				w.checkStaleness()
+			}
+		}
+	})
+}
+
+// checkStaleness fires the staleness handler once per disconnection.
+func (w *Watcher) checkStaleness() {
+	w.stateMu.Lock()
+	disconnected := w.clock().Now().Sub(w.state.disconnectedAt)
+	fire := !w.state.subscribed && !w.state.stale && disconnected > w.options.staleThreshold
+	if fire {
+		w.state.stale = true
+	}
+	w.stateMu.Unlock()
+
+	if fire {
+		w.options.staleCallback(disconnected)
+	}
+}
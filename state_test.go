@@ -0,0 +1,35 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStalenessHandler(t *testing.T) {
+	stale, recovered := 0, 0
+	w := &Watcher{closed: make(chan struct{})}
+	StalenessHandler(10*time.Millisecond, func(time.Duration) {
+		stale++
+	}, func() {
+		recovered++
+	})(&w.options)
+
+	w.setSubscribed(true)
+	w.setSubscribed(false)
+	w.checkStaleness()
+	if stale != 0 {
+		t.Fatal("Staleness handler fired before the threshold")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	w.checkStaleness()
+	w.checkStaleness()
+	if stale != 1 {
+		t.Fatalf("Staleness handler should fire once per disconnection, fired %d times", stale)
+	}
+
+	w.setSubscribed(true)
+	if recovered != 1 {
+		t.Fatal("Recovery handler not invoked after resubscribing")
+	}
+}
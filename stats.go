@@ -0,0 +1,106 @@
+package rediswatcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the state and activity of a watcher.
+type Stats struct {
+	Subscribed     bool      // Currently subscribed to the channel.
+	Unsubscribed   bool      // Left the channel on request.
+	Stale          bool      // Disconnected for longer than the staleness threshold.
+	DisconnectedAt time.Time // Start of the current disconnection, zero when subscribed.
+	Published      int64
+	Received       int64
+	Errors         int64 // Errors reported to the failure callback.
+	Reconnects     int64
+	LastPublish    time.Time
+	LastReceive    time.Time
+	QueuedUpdates  int   // Updates waiting for a callback worker.
+	QueueCapacity  int   // Size of the callback queue, QueueOverflow applies when full.
+	EarlyMessages  int   // Messages waiting for an update callback to be set.
+	SpilledUpdates int64 // Updates waiting in the OverflowSpillList.
+	RunningUpdates int32 // Callbacks currently running.
+	DroppedUpdates int64 // Updates discarded by QueueOverflow or the early buffer.
+	FailedUpdates  int64 // Update callbacks that returned an error after all retries.
+	Paused         bool
+	ProbeLatency   time.Duration // Round trip of the last latency probe.
+	LastReload     time.Time     // Last update callback that succeeded.
+}
+
+// Stats returns a snapshot of the watcher state, e.g. for a health endpoint.
+func (w *Watcher) Stats() Stats {
+	w.stateMu.Lock()
+	state := w.state
+	w.stateMu.Unlock()
+
+	s := Stats{
+		Subscribed:     state.subscribed,
+		Unsubscribed:   state.unsubscribed,
+		Stale:          state.stale,
+		Published:      atomic.LoadInt64(&w.counters.published),
+		Received:       atomic.LoadInt64(&w.counters.received),
+		Errors:         atomic.LoadInt64(&w.counters.errors),
+		Reconnects:     w.reconnects(),
+		LastPublish:    loadTime(&w.counters.lastPublish),
+		LastReceive:    loadTime(&w.counters.lastReceive),
+		LastReload:     loadTime(&w.counters.lastReload),
+		QueuedUpdates:  len(w.jobs),
+		QueueCapacity:  cap(w.jobs),
+		EarlyMessages:  w.earlyMessages(),
+		SpilledUpdates: atomic.LoadInt64(&w.spill.count),
+		RunningUpdates: atomic.LoadInt32(&w.inflight),
+		DroppedUpdates: atomic.LoadInt64(&w.counters.dropped),
+		FailedUpdates:  atomic.LoadInt64(&w.counters.failed),
+		Paused:         w.Paused(),
+		ProbeLatency:   time.Duration(atomic.LoadInt64(&w.counters.probeLatency)),
+	}
+	if !state.subscribed {
+		s.DisconnectedAt = state.disconnectedAt
+	}
+	return s
+}
+
+// counters are maintained for every watcher and read by Stats and the
+// expvar export. All fields are accessed atomically.
+type counters struct {
+	published     int64
+	received      int64
+	errors        int64
+	dropped       int64
+	failed        int64
+	dials         int64
+	dialErrors    int64
+	authFailures  int64
+	subscriptions int64
+	lastPublish   int64 // UnixNano
+	lastReceive   int64 // UnixNano
+	lastReload    int64 // UnixNano
+	probeLatency  int64 // time.Duration
+}
+
+func (w *Watcher) countPublished() {
+	atomic.AddInt64(&w.counters.published, 1)
+	atomic.StoreInt64(&w.counters.lastPublish, time.Now().UnixNano())
+}
+
+func (w *Watcher) countReceived() {
+	atomic.AddInt64(&w.counters.received, 1)
+	atomic.StoreInt64(&w.counters.lastReceive, time.Now().UnixNano())
+}
+
+// reconnects is the number of subscriptions after the first one.
+func (w *Watcher) reconnects() int64 {
+	if n := atomic.LoadInt64(&w.counters.subscriptions); n > 1 {
+		return n - 1
+	}
+	return 0
+}
+
+func loadTime(addr *int64) time.Time {
+	if n := atomic.LoadInt64(addr); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
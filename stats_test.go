@@ -0,0 +1,28 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{}), state: connectionState{disconnectedAt: time.Now()}}
+
+	if s := w.Stats(); s.Subscribed || s.DisconnectedAt.IsZero() || s.Reconnects != 0 {
+		t.Fatalf("New watcher should be disconnected, got %+v", s)
+	}
+
+	w.setSubscribed(true)
+	w.setSubscribed(false)
+	w.setSubscribed(true)
+	w.countReceived()
+	w.reportError(ErrClosed)
+
+	s := w.Stats()
+	if !s.Subscribed || !s.DisconnectedAt.IsZero() {
+		t.Fatalf("Watcher should be subscribed, got %+v", s)
+	}
+	if s.Reconnects != 1 || s.Received != 1 || s.Errors != 1 || s.LastReceive.IsZero() {
+		t.Fatalf("Unexpected counters %+v", s)
+	}
+}
@@ -0,0 +1,60 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StatsReport is published on the stats channel set by PublishStats.
+type StatsReport struct {
+	ID      string    `json:"id"`
+	Channel string    `json:"channel"`
+	Time    time.Time `json:"time"`
+	Stats   Stats     `json:"stats"`
+	Conn    ConnStats `json:"conn"`
+}
+
+func (w *Watcher) startStatsPush() {
+	if w.options.statsChannel == "" || w.options.statsInterval <= 0 {
+		return
+	}
+
+	w.spawn(func() {
+		ticker := w.clock().NewTicker(w.options.statsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.closed:
+				return
+			case <-ticker.C():
+				if err := w.pushStats(); err != nil {
+					w.reportError(err)
+				}
+			}
+		}
+	})
+}
+
+// pushStats publishes a StatsReport on the stats channel. Reports are not
+// queued when Redis is unreachable, the next one replaces them.
+func (w *Watcher) pushStats() error {
+	b, err := json.Marshal(StatsReport{
+		ID:      w.options.LocalID,
+		Channel: w.channel(),
+		Time:    time.Now(),
+		Stats:   w.Stats(),
+		Conn:    w.ConnStats(),
+	})
+	if err != nil {
+		return err
+	}
+
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("PUBLISH", w.options.statsChannel, string(b))
+	return err
+}
@@ -0,0 +1,31 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPublishStats(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), PublishStats("/casbin/stats", time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.pushStats(); err != nil {
+		t.Fatalf("Failed to push stats: %v", err)
+	}
+	calls := pub.calls("PUBLISH")
+	if len(calls) != 1 || calls[0][0] != "/casbin/stats" {
+		t.Fatalf("Stats should be published on the stats channel, got %v", calls)
+	}
+	var report StatsReport
+	if err := json.Unmarshal([]byte(calls[0][1].(string)), &report); err != nil || report.ID != "node1" {
+		t.Fatalf("Stats report should decode, got %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,55 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StatusResponse is published on the StatusChannel in answer to
+// RequestStatus.
+type StatusResponse struct {
+	ID         string    `json:"id"`
+	Nonce      string    `json:"nonce,omitempty"` // Nonce of the request answered.
+	Version    string    `json:"version,omitempty"`
+	Channel    string    `json:"channel"`
+	Subscribed bool      `json:"subscribed"`
+	Lag        int       `json:"lag"` // Updates received but not yet handled.
+	LastReload time.Time `json:"lastReload"`
+}
+
+// RequestStatus asks every watcher on the channel to publish a
+// StatusResponse on its StatusChannel. Operators can send the same request
+// with redis-cli:
+//
+//	PUBLISH /casbin '{"type":"status","id":"ops","nonce":"1"}'
+func (w *Watcher) RequestStatus(nonce string) error {
+	return w.publishMessage(Message{Type: MessageTypeStatus, ID: w.options.LocalID, Nonce: nonce})
+}
+
+// answerStatus publishes the status of the watcher on the StatusChannel.
+func (w *Watcher) answerStatus(m Message) {
+	if w.options.statusChannel == "" {
+		return
+	}
+
+	s := w.Stats()
+	b, _ := json.Marshal(StatusResponse{
+		ID:         w.options.LocalID,
+		Nonce:      m.Nonce,
+		Version:    w.options.helloVersion,
+		Channel:    w.channel(),
+		Subscribed: s.Subscribed,
+		Lag:        s.QueuedUpdates + s.EarlyMessages,
+		LastReload: s.LastReload,
+	})
+
+	w.pubMu.Lock()
+	defer w.pubMu.Unlock()
+	c, err := w.publisher()
+	if err == nil {
+		_, err = c.Do("PUBLISH", w.options.statusChannel, string(b))
+	}
+	if err != nil {
+		w.reportError(err)
+	}
+}
@@ -0,0 +1,41 @@
+package rediswatcher
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStatusRequest(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), Hello("v1.2.0", nil), StatusChannel("/casbin/status"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.RequestStatus("42"); err != nil {
+		t.Fatalf("Failed watcher.RequestStatus(): %v", err)
+	}
+	request, ok := decodeMessage(pub.calls("PUBLISH")[0][1].(string))
+	if !ok || request.Type != MessageTypeStatus {
+		t.Fatalf("Status request should be a structured message, got %v", pub.calls("PUBLISH"))
+	}
+	if !w.handleControlMessage(request) {
+		t.Fatal("Status requests must not reach the update callback")
+	}
+
+	calls := pub.calls("PUBLISH")
+	if len(calls) != 2 || calls[1][0] != "/casbin/status" {
+		t.Fatalf("Status should be answered on the status channel, got %v", calls)
+	}
+	var response StatusResponse
+	if err := json.Unmarshal([]byte(calls[1][1].(string)), &response); err != nil {
+		t.Fatalf("Invalid status response: %v", err)
+	}
+	if response.ID != "node1" || response.Nonce != "42" || response.Version != "v1.2.0" {
+		t.Fatalf("Unexpected status response %+v", response)
+	}
+}
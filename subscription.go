@@ -0,0 +1,167 @@
+package rediswatcher
+
+import (
+	"errors"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var errNotSubscribing = errors.New("rediswatcher: publish watchers do not subscribe")
+
+// Unsubscribe leaves the channel without closing the watcher. No updates are
+// received until Resubscribe is called.
+func (w *Watcher) Unsubscribe() error {
+	if w.subDone == nil {
+		return errNotSubscribing
+	}
+	if w.isClosed() {
+		return ErrClosed
+	}
+
+	w.stateMu.Lock()
+	w.state.unsubscribed = true
+	w.stateMu.Unlock()
+
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	if w.isSubscribed() {
+		return w.leave(redis.PubSubConn{Conn: w.subConn})
+	}
+	return nil
+}
+
+// SubscribeChannel adds channel to the channels of a running watcher, also
+// after reconnecting, e.g. when a tenant is provisioned.
+func (w *Watcher) SubscribeChannel(channel string) error {
+	return w.subscribeChannel(w.prefixed(channel))
+}
+
+func (w *Watcher) subscribeChannel(channel string) error {
+	if w.subDone == nil {
+		return errNotSubscribing
+	}
+	if w.isClosed() {
+		return ErrClosed
+	}
+
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	if !w.addChannel(channel) {
+		return nil
+	}
+	for _, c := range w.federatedConns() {
+		redis.PubSubConn{Conn: c}.Subscribe(channel)
+	}
+	if c := w.subscriptionConn(channel); c != nil {
+		return redis.PubSubConn{Conn: c}.Subscribe(channel)
+	}
+	return nil
+}
+
+// addChannel adds channel to the subscribed channels, reporting false when
+// it is already one of them.
+func (w *Watcher) addChannel(channel string) bool {
+	w.channelMu.Lock()
+	defer w.channelMu.Unlock()
+	for _, c := range w.options.channels {
+		if c == channel {
+			return false
+		}
+	}
+	w.options.channels = append(w.options.channels, channel)
+	return true
+}
+
+// UnsubscribeChannel removes a channel added with Channels or
+// SubscribeChannel, e.g. when a tenant is archived. The watcher channel is
+// left with Unsubscribe instead.
+func (w *Watcher) UnsubscribeChannel(channel string) error {
+	return w.unsubscribeChannel(w.prefixed(channel))
+}
+
+func (w *Watcher) unsubscribeChannel(channel string) error {
+	if w.subDone == nil {
+		return errNotSubscribing
+	}
+	if w.isClosed() {
+		return ErrClosed
+	}
+	if channel == w.channel() {
+		return errors.New("rediswatcher: use Unsubscribe to leave the watcher channel")
+	}
+
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	if !w.removeChannel(channel) {
+		return nil
+	}
+	for _, c := range w.federatedConns() {
+		redis.PubSubConn{Conn: c}.Unsubscribe(channel)
+	}
+	if c := w.subscriptionConn(channel); c != nil {
+		return redis.PubSubConn{Conn: c}.Unsubscribe(channel)
+	}
+	return nil
+}
+
+// removeChannel removes channel from the subscribed channels, reporting
+// whether it was one of them.
+func (w *Watcher) removeChannel(channel string) bool {
+	w.channelMu.Lock()
+	defer w.channelMu.Unlock()
+	for i, c := range w.options.channels {
+		if c == channel {
+			w.options.channels = append(w.options.channels[:i:i], w.options.channels[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// leave unsubscribes from every channel and pattern. Callers must hold
+// subMu.
+func (w *Watcher) leave(psc redis.PubSubConn) error {
+	w.leaveShards()
+	err := psc.Unsubscribe()
+	if err == nil && len(w.options.patterns) > 0 {
+		err = psc.PUnsubscribe()
+	}
+	return err
+}
+
+// Resubscribe subscribes again after Unsubscribe. A non-empty channel
+// switches the watcher, publishing included, to that channel; an active
+// subscription to the old channel is replaced.
+func (w *Watcher) Resubscribe(channel string) error {
+	if w.subDone == nil {
+		return errNotSubscribing
+	}
+	if w.isClosed() {
+		return ErrClosed
+	}
+
+	if channel != "" {
+		channel = w.prefixed(channel)
+	}
+	if channel != "" && channel != w.channel() {
+		w.subMu.Lock()
+		w.optMu.Lock()
+		w.options.Channel = channel
+		w.optMu.Unlock()
+		if w.isSubscribed() {
+			w.leave(redis.PubSubConn{Conn: w.subConn})
+		}
+		w.subMu.Unlock()
+	}
+
+	w.stateMu.Lock()
+	w.state.unsubscribed = false
+	w.stateMu.Unlock()
+	w.wakeShards()
+
+	select {
+	case w.resubscribe <- struct{}{}:
+	default:
+	}
+	return nil
+}
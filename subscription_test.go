@@ -0,0 +1,157 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestUnsubscribeResubscribe(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.Unsubscribe(); err != errNotSubscribing {
+		t.Fatalf("Publish watchers cannot unsubscribe, got %v", err)
+	}
+
+	// pretend the subscription loop is running
+	w.subDone = make(chan struct{})
+	w.resubscribe = make(chan struct{}, 1)
+	if err := w.Unsubscribe(); err != nil {
+		t.Fatalf("Failed watcher.Unsubscribe(): %v", err)
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		w.waitBeforeResubscribe(0)
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("Unsubscribed watcher must not retry on its own")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := w.Resubscribe("/casbin/v2"); err != nil {
+		t.Fatalf("Failed watcher.Resubscribe(): %v", err)
+	}
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("Resubscribe did not wake the subscription loop")
+	}
+	if w.GetWatcherOptions().Channel != "/casbin/v2" {
+		t.Fatalf("Channel should be '/casbin/v2', is '%s'", w.GetWatcherOptions().Channel)
+	}
+}
+
+func TestSubscribeChannel(t *testing.T) {
+	pub := NewTestConn()
+	sub := newRecordConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		Channels("/casbin/tenant1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.SubscribeChannel("/casbin/tenant2"); err != errNotSubscribing {
+		t.Fatalf("Publish watchers cannot subscribe, got %v", err)
+	}
+
+	// pretend the subscription loop is running
+	w.subDone = make(chan struct{})
+	w.setSubscribed(true)
+	defer w.setSubscribed(false)
+
+	if err := w.SubscribeChannel("/casbin/tenant2"); err != nil {
+		t.Fatalf("Failed watcher.SubscribeChannel(): %v", err)
+	}
+	if err := w.UnsubscribeChannel("/casbin/tenant1"); err != nil {
+		t.Fatalf("Failed watcher.UnsubscribeChannel(): %v", err)
+	}
+	if err := w.UnsubscribeChannel("/casbin"); err == nil {
+		t.Fatal("The watcher channel cannot be removed")
+	}
+	if fmt.Sprint(sub.calls("SUBSCRIBE"), sub.calls("UNSUBSCRIBE")) != "[[/casbin/tenant2]] [[/casbin/tenant1]]" {
+		t.Fatalf("Channels should be changed on the running subscription, got %v and %v",
+			sub.calls("SUBSCRIBE"), sub.calls("UNSUBSCRIBE"))
+	}
+	if channels := fmt.Sprint(w.channels()); channels != "[/casbin /casbin/tenant2]" {
+		t.Fatalf("Reconnects should subscribe to the current channels, got %v", channels)
+	}
+}
+
+func TestSubscribeChannelWhileSubscribing(t *testing.T) {
+	pub := NewTestConn()
+	sub := newRecordConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		Channels("/casbin/tenant1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	// the subscription loop sent SUBSCRIBE and waits for the confirmation
+	w.subDone = make(chan struct{})
+	subscribed := w.channelsFor(0)
+	defer w.setSubscribed(false)
+
+	if err := w.SubscribeChannel("/casbin/tenant2"); err != nil {
+		t.Fatalf("Failed watcher.SubscribeChannel(): %v", err)
+	}
+	if err := w.UnsubscribeChannel("/casbin/tenant1"); err != nil {
+		t.Fatalf("Failed watcher.UnsubscribeChannel(): %v", err)
+	}
+	if len(sub.calls("SUBSCRIBE")) != 0 || len(sub.calls("UNSUBSCRIBE")) != 0 {
+		t.Fatal("Channels should be left to the subscription loop before it is subscribed")
+	}
+	if !w.confirmSubscription(redis.PubSubConn{Conn: sub}, subscribed) {
+		t.Fatal("The first confirmation should mark the watcher subscribed")
+	}
+	if fmt.Sprint(sub.calls("SUBSCRIBE"), sub.calls("UNSUBSCRIBE")) != "[[/casbin/tenant2]] [[/casbin/tenant1]]" {
+		t.Fatalf("The confirmation should catch up with the channels changed in between, got %v and %v",
+			sub.calls("SUBSCRIBE"), sub.calls("UNSUBSCRIBE"))
+	}
+}
+
+func TestResubscribeWhileRunning(t *testing.T) {
+	pub := NewTestConn()
+	sub := newRecordConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	// pretend the subscription loop is running
+	w.subDone = make(chan struct{})
+	w.resubscribe = make(chan struct{}, 1)
+	w.setSubscribed(true)
+	defer w.setSubscribed(false)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			w.DomainChannel("tenant1")
+			w.SubscribeChannel(fmt.Sprintf("/casbin/tenant%d", i))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := w.Resubscribe(fmt.Sprintf("/casbin/v%d", i)); err != nil {
+			t.Fatalf("Failed watcher.Resubscribe(): %v", err)
+		}
+	}
+	<-done
+	if w.GetWatcherOptions().Channel != "/casbin/v99" {
+		t.Fatalf("Channel should be '/casbin/v99', is '%s'", w.GetWatcherOptions().Channel)
+	}
+}
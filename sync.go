@@ -0,0 +1,55 @@
+package rediswatcher
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Synchronous makes the watcher run without goroutines of its own, for
+// deterministic, race detector friendly unit tests. It does not subscribe
+// and starts no background loops; messages are handed in with
+// DeliverMessage and the callbacks run inline before it returns.
+// CallbackWorkers, CallbackTimeout and SquashMessages have no effect, and
+// Close does not cancel the context of a running callback. Debounce and
+// PublishCoalesce keep their timers, which WithClock and a ManualClock make
+// synchronous too.
+func Synchronous(sync bool) WatcherOption {
+	return func(options *WatcherOptions) {
+		options.Synchronous = sync
+	}
+}
+
+// DeliverMessage handles data as if it was received on channel, the watcher
+// channel when empty. A Synchronous watcher runs the callbacks before it
+// returns; otherwise data is queued for the processing loop like a message
+// from Redis.
+func (w *Watcher) DeliverMessage(channel, data string) {
+	if channel == "" {
+		channel = w.channel()
+	}
+	msg := redis.Message{Channel: channel, Data: []byte(data)}
+	if !w.options.Synchronous {
+		if w.messagesIn != nil {
+			w.received(time.Now(), msg)
+		}
+		return
+	}
+
+	w.countReceived()
+	j, ok := w.acceptMessage(msg, false)
+	if !ok || (w.options.IgnoreSelf && j.msg == w.options.LocalID) {
+		return
+	}
+	w.deliverJob(j)
+}
+
+// replayEarlyMessages delivers inline what arrived before a callback was
+// set, the job of the processing loop in Synchronous mode.
+func (w *Watcher) replayEarlyMessages() {
+	for _, early := range w.takeEarlyMessages() {
+		if !w.options.IgnoreSelf || early != w.options.LocalID {
+			w.deliver(early)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rafaeljusto/redigomock"
+)
+
+func TestSynchronous(t *testing.T) {
+	pub := redigomock.NewConn()
+	pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+	w, err := newWatcher("", Synchronous(true), LocalID("node1"), IgnoreSelf(true),
+		CallbackWorkers(4), CallbackTimeout(time.Second), WithRedisPubConnection(pub))
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	// buffered until a callback is set, then replayed inline
+	w.DeliverMessage("", "node2")
+	var got []string
+	w.SetUpdateCallback(func(msg string) { got = append(got, msg) })
+	if fmt.Sprint(got) != "[node2]" {
+		t.Fatalf("The early message should be replayed inline, got %v", got)
+	}
+
+	w.DeliverMessage("", "node3")
+	w.DeliverMessage("", "node1")
+	if fmt.Sprint(got) != "[node2 node3]" {
+		t.Fatalf("Callbacks should run inline, ignoring own messages, got %v", got)
+	}
+	if err := w.Update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if s := w.Stats(); s.Subscribed || s.Received != 3 {
+		t.Fatalf("A synchronous watcher should not subscribe, got %+v", s)
+	}
+}
@@ -0,0 +1,22 @@
+package rediswatcher
+
+// trustedSender reports whether a message, decoded as m when structured or
+// the plain data otherwise, comes from one of the TrustedPublishers.
+func (w *Watcher) trustedSender(m Message, structured bool, data string) bool {
+	if len(w.options.TrustedPublishers) == 0 {
+		return true
+	}
+	sender := data
+	if structured {
+		sender = m.ID
+	}
+	if sender == w.options.LocalID {
+		return true
+	}
+	for _, id := range w.options.TrustedPublishers {
+		if sender == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestTrustedPublishers(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), TrustedPublishers("node2"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	received := make(chan string, 4)
+	w.SetUpdateCallback(func(msg string) {
+		received <- msg
+	})
+	w.messagesIn = make(chan redis.Message, 4)
+	w.messageInProcessor()
+
+	for _, data := range []string{
+		"rogue",
+		encodeMessage(Message{Type: MessageTypeUpdate, ID: "rogue"}),
+		"node2",
+		encodeMessage(Message{Type: MessageTypeUpdate, ID: "node1"}),
+	} {
+		w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte(data)}
+	}
+	for _, want := range []string{"node2", `{"type":"update","id":"node1"}`} {
+		select {
+		case msg := <-received:
+			if msg != want {
+				t.Fatalf("Only trusted messages should be delivered, got %s instead of %s", msg, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Trusted message %s was not delivered", want)
+		}
+	}
+}
+
+func TestTrustedPublishersRemoteReload(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), TrustedPublishers("node2"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	received := make(chan string, 4)
+	w.SetUpdateCallback(func(msg string) {
+		received <- msg
+	})
+	w.messagesIn = make(chan redis.Message, 4)
+	w.localIn = make(chan redis.Message)
+	w.messageInProcessor()
+
+	w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte(encodeMessage(Message{Type: MessageTypeReload, ID: "mallory"}))}
+	w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte("node2")}
+	expect := func(want string) {
+		select {
+		case msg := <-received:
+			if msg != want {
+				t.Fatalf("A reload from an untrusted publisher should be dropped, got %s instead of %s", msg, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Message %s was not delivered", want)
+		}
+	}
+	expect("node2")
+	w.reload() // a local reload passes
+	expect(encodeMessage(Message{Type: MessageTypeReload, ID: "periodic"}))
+}
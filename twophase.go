@@ -0,0 +1,45 @@
+package rediswatcher
+
+import "context"
+
+const controlKey contextKey = deliveryKey + 1
+
+// Prepare announces a new policy version so subscribers can fetch and stage
+// it without applying it yet. Follow it with Commit once every publisher
+// side change is in place.
+func (w *Watcher) Prepare(version string) error {
+	return w.publishMessage(Message{
+		Type:    MessageTypePrepare,
+		ID:      w.options.LocalID,
+		Version: version,
+	})
+}
+
+// Commit tells subscribers to switch to a previously prepared policy
+// version. Subscribers without two-phase handlers treat it as a regular
+// update.
+func (w *Watcher) Commit(version string) error {
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+	return w.publishMessage(Message{
+		Type:    MessageTypeCommit,
+		ID:      w.options.LocalID,
+		Version: version,
+	})
+}
+
+// twoPhaseCallback returns the TwoPhaseCallbacks handler for control
+// messages of type kind as an update callback, so that they run on the
+// workers like updates.
+func (w *Watcher) twoPhaseCallback(kind string) func(context.Context, string) error {
+	handler := w.options.prepareCallback
+	if kind == MessageTypeCommit {
+		handler = w.options.commitCallback
+	}
+	return func(_ context.Context, msg string) error {
+		m, _ := decodeMessage(msg)
+		handler(m.Version)
+		return nil
+	}
+}
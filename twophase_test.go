@@ -0,0 +1,85 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTwoPhase(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+
+	var prepared, committed string
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), LocalID("node1"),
+		TwoPhaseCallbacks(func(version string) {
+			prepared = version
+		}, func(version string) {
+			committed = version
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	prepare := pub.Command("PUBLISH", "/casbin", `{"type":"prepare","id":"node1","version":"v2"}`).Expect(int64(1))
+	if err := w.Prepare("v2"); err != nil {
+		t.Fatalf("Failed watcher.Prepare(): %v", err)
+	}
+	if pub.Stats(prepare) != 1 {
+		t.Fatal("Prepare message was not published")
+	}
+
+	m, ok := decodeMessage(`{"type":"prepare","id":"node2","version":"v2"}`)
+	if !ok || !w.handleControlMessage(m) {
+		t.Fatal("Prepare message should be consumed")
+	}
+	if prepared != "v2" || committed != "" {
+		t.Fatalf("Only prepare should have run, prepared '%s' committed '%s'", prepared, committed)
+	}
+
+	m, _ = decodeMessage(`{"type":"commit","id":"node2","version":"v2"}`)
+	if !w.handleControlMessage(m) {
+		t.Fatal("Commit message should be consumed")
+	}
+	if committed != "v2" {
+		t.Fatalf("Commit should switch to 'v2', received '%s' instead", committed)
+	}
+
+	if _, ok := decodeMessage("node2"); ok {
+		t.Fatal("Plain payloads are not structured messages")
+	}
+}
+
+func TestTwoPhaseCallbacksRunOnWorkers(t *testing.T) {
+	release := make(chan struct{})
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(NewTestConn()), WithRedisSubConnection(NewTestConn()),
+		LocalID("node1"), CallbackWorkers(1), CallbackQueueSize(4), TwoPhaseCallbacks(func(version string) {
+			<-release
+		}, func(version string) {
+			panic("commit failed")
+		}))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+	w.startCallbackWorkers()
+
+	// a slow prepare handler does not hold up the message processor
+	m, _ := decodeMessage(`{"type":"prepare","id":"node2","version":"v2"}`)
+	if !w.handleControlMessage(m) {
+		t.Fatal("Prepare message should be consumed")
+	}
+	m, _ = decodeMessage(`{"type":"commit","id":"node2","version":"v2"}`)
+	if !w.handleControlMessage(m) {
+		t.Fatal("Commit message should be consumed")
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for w.Stats().FailedUpdates != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("The panicking commit handler should count as a failed update, got %+v", w.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,70 @@
+package rediswatcher
+
+// Operation kinds of a batch message.
+const (
+	OperationAdd    = "add"
+	OperationRemove = "remove"
+	OperationUpdate = "update"
+)
+
+// Operation is a policy change carried by a batch message. For updates
+// Rules holds the old rules and NewRules their replacements.
+type Operation struct {
+	Op       string     `json:"op"`
+	Sec      string     `json:"sec"`
+	Ptype    string     `json:"ptype"`
+	Rules    [][]string `json:"rules,omitempty"`
+	NewRules [][]string `json:"newRules,omitempty"`
+}
+
+// UpdateBatch collects policy changes that are published together as one
+// MessageTypeBatch message. Receivers get them from ParseMessage.
+type UpdateBatch struct {
+	w   *Watcher
+	ops []Operation
+}
+
+// NewBatch starts an UpdateBatch for the watcher.
+func (w *Watcher) NewBatch() *UpdateBatch {
+	return &UpdateBatch{w: w}
+}
+
+// AddPolicies records added rules.
+func (b *UpdateBatch) AddPolicies(sec, ptype string, rules ...[]string) *UpdateBatch {
+	b.ops = append(b.ops, Operation{Op: OperationAdd, Sec: sec, Ptype: ptype, Rules: rules})
+	return b
+}
+
+// RemovePolicies records removed rules.
+func (b *UpdateBatch) RemovePolicies(sec, ptype string, rules ...[]string) *UpdateBatch {
+	b.ops = append(b.ops, Operation{Op: OperationRemove, Sec: sec, Ptype: ptype, Rules: rules})
+	return b
+}
+
+// UpdatePolicies records rules replaced by newRules.
+func (b *UpdateBatch) UpdatePolicies(sec, ptype string, oldRules, newRules [][]string) *UpdateBatch {
+	b.ops = append(b.ops, Operation{Op: OperationUpdate, Sec: sec, Ptype: ptype, Rules: oldRules, NewRules: newRules})
+	return b
+}
+
+// Len returns the number of recorded operations.
+func (b *UpdateBatch) Len() int {
+	return len(b.ops)
+}
+
+// Publish publishes all recorded operations as a single message and resets
+// the batch. An empty batch publishes nothing.
+func (b *UpdateBatch) Publish() error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+	if err := b.w.waitBeforePublish(); err != nil {
+		return err
+	}
+
+	err := b.w.publishMessage(Message{Type: MessageTypeBatch, ID: b.w.options.LocalID, Operations: b.ops})
+	if err == nil {
+		b.ops = nil
+	}
+	return err
+}
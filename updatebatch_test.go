@@ -0,0 +1,45 @@
+package rediswatcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpdateBatch(t *testing.T) {
+	pub := newRecordConn()
+	sub := NewTestConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	b := w.NewBatch().
+		AddPolicies("p", "p", []string{"alice", "data1", "read"}).
+		RemovePolicies("g", "g", []string{"bob", "admin"}).
+		UpdatePolicies("p", "p", [][]string{{"bob", "data2", "read"}}, [][]string{{"bob", "data2", "write"}})
+	if b.Len() != 3 {
+		t.Fatalf("Batch should hold 3 operations, got %d", b.Len())
+	}
+	if err := b.Publish(); err != nil {
+		t.Fatalf("Failed batch.Publish(): %v", err)
+	}
+
+	calls := pub.calls("PUBLISH")
+	if len(calls) != 1 {
+		t.Fatalf("Batch should be published as one message, got %d", len(calls))
+	}
+	m, ok := ParseMessage(calls[0][1].(string))
+	if !ok || m.Type != MessageTypeBatch || m.ID != "node1" {
+		t.Fatalf("Unexpected batch message %+v", m)
+	}
+	if !reflect.DeepEqual(m.Operations[2].NewRules, [][]string{{"bob", "data2", "write"}}) {
+		t.Fatalf("Unexpected update operation %+v", m.Operations[2])
+	}
+
+	if b.Len() != 0 || b.Publish() != nil || len(pub.calls("PUBLISH")) != 1 {
+		t.Fatal("Published batch should be reset and not publish again")
+	}
+}
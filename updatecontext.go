@@ -0,0 +1,68 @@
+package rediswatcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// UpdateWithContext is Update bounded by ctx. A deadline on ctx also limits
+// the PUBLISH round trip on connections supporting read timeouts. When ctx
+// is done first ctx.Err() is returned, though the publish may still go out.
+func (w *Watcher) UpdateWithContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if w.suppressUpdate() {
+		return nil
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- w.publishUpdateContext(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Watcher) publishUpdateContext(ctx context.Context) error {
+	if w.IsLoader() {
+		return w.reloadForPeers()
+	}
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+
+	return w.publishLocked(func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			w.pubDeadline = deadline
+			defer func() { w.pubDeadline = time.Time{} }()
+		}
+		return w.publishOrQueue(w.options.LocalID)
+	})
+}
+
+// doPublish sends PUBLISH on c, within pubDeadline when one is set.
+// Callers must hold pubMu.
+func (w *Watcher) doPublish(c redis.Conn, msg string) (interface{}, error) {
+	if w.pubDeadline.IsZero() {
+		return c.Do("PUBLISH", w.publishChannel(), msg)
+	}
+
+	timeout := time.Until(w.pubDeadline)
+	if timeout <= 0 {
+		return nil, context.DeadlineExceeded
+	}
+	if _, ok := c.(redis.ConnWithTimeout); !ok {
+		return c.Do("PUBLISH", w.publishChannel(), msg)
+	}
+	return redis.DoWithTimeout(c, timeout, "PUBLISH", w.publishChannel(), msg)
+}
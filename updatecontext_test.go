@@ -0,0 +1,36 @@
+package rediswatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUpdateWithContext(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub),
+		LocalID("node1"), PublishDelay(time.Second))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := w.UpdateWithContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Update should give up at the deadline, got %v", err)
+	}
+
+	w, err = newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	pub.Command("PUBLISH", "/casbin", "node1").Expect(int64(1))
+	if err := w.UpdateWithContext(context.Background()); err != nil {
+		t.Fatalf("Failed watcher.UpdateWithContext(): %v", err)
+	}
+}
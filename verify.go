@@ -0,0 +1,55 @@
+package rediswatcher
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Verify publishes a probe on the channel and waits up to timeout for the
+// watcher's own subscription to receive it, proving the whole pub/sub path
+// works. Probes never reach update callbacks of current watchers.
+func (w *Watcher) Verify(timeout time.Duration) error {
+	if w.messagesIn == nil {
+		return errors.New("rediswatcher: Verify needs a subscribing watcher")
+	}
+
+	nonce := uuid.New().String()
+	received := make(chan struct{})
+	w.probeMu.Lock()
+	if w.probes == nil {
+		w.probes = make(map[string]chan struct{})
+	}
+	w.probes[nonce] = received
+	w.probeMu.Unlock()
+	defer func() {
+		w.probeMu.Lock()
+		delete(w.probes, nonce)
+		w.probeMu.Unlock()
+	}()
+
+	if err := w.publishMessage(Message{Type: MessageTypeProbe, ID: w.options.LocalID, Nonce: nonce}); err != nil {
+		return err
+	}
+
+	select {
+	case <-received:
+		return nil
+	case <-w.closed:
+		return ErrClosed
+	case <-w.clock().After(timeout):
+		return fmt.Errorf("rediswatcher: probe not received on %q within %v", w.channel(), timeout)
+	}
+}
+
+func (w *Watcher) probeReceived(nonce string) {
+	w.probeMu.Lock()
+	defer w.probeMu.Unlock()
+
+	if received, ok := w.probes[nonce]; ok {
+		close(received)
+		delete(w.probes, nonce)
+	}
+}
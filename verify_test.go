@@ -0,0 +1,37 @@
+package rediswatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestVerify(t *testing.T) {
+	pub := newRecordConn()
+	pub.GenericCommand("PUBLISH").Expect(int64(1))
+	sub := NewTestConn()
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub), LocalID("node1"))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.Verify(time.Millisecond); err == nil {
+		t.Fatal("Verify should fail for a publish-only watcher")
+	}
+
+	// pretend to subscribe and loop the published probe back
+	w.messagesIn = make(chan redis.Message)
+	go func() {
+		for len(pub.calls("PUBLISH")) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		m, _ := decodeMessage(pub.calls("PUBLISH")[0][1].(string))
+		w.handleControlMessage(m)
+	}()
+	if err := w.Verify(time.Second); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+}
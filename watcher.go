@@ -1,9 +1,16 @@
 package rediswatcher
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/casbin/casbin/v2/persist"
@@ -11,14 +18,79 @@ import (
 	"github.com/google/uuid"
 )
 
+var _ persist.Watcher = (*Watcher)(nil)
+
+// Watcher is a persist.Watcher backed by Redis Pub/Sub. The constructors
+// return it as the concrete type, so its methods beyond persist.Watcher need
+// no type assertion.
 type Watcher struct {
-	options    WatcherOptions
-	pubConn    redis.Conn
-	subConn    redis.Conn
-	callback   func(string)
-	closed     chan struct{}
-	messagesIn chan redis.Message
-	once       sync.Once
+	crashes     int64 // recovered background panics, accessed atomically; first for alignment
+	counters    counters
+	options     WatcherOptions
+	optMu       sync.RWMutex // guards the options ApplyOptions changes, see liveOptions
+	addr        string
+	pubConn     redis.Conn
+	subConn     redis.Conn // guarded by subMu, set by the subscription loop
+	subMu       sync.Mutex // serializes commands on the subscription connections
+	queueConn   redis.Conn
+	pubMu       sync.Mutex
+	pubDeadline time.Time // bounds publishes of UpdateWithContext, guarded by pubMu
+	pubChannel  string    // overrides the channel of the publish in progress, guarded by pubMu
+	pubUsed     time.Time // last use of pubConn, guarded by pubMu
+	stateMu     sync.Mutex
+	state       connectionState
+	channelMu   sync.Mutex // guards options.channels, changed by SubscribeChannel
+	shards      []*subShard
+	federated   []*subShard // one per FederatedServers address
+	probeMu     sync.Mutex
+	probes      map[string]chan struct{}
+	replies     map[string]chan Message // answers to requests by nonce, guarded by probeMu
+	peerMu      sync.Mutex
+	peers       map[string]Message // last hello of other watchers by ID, guarded by peerMu
+	callbackMu  sync.RWMutex
+	callback    func(context.Context, string) error
+	callbacks   []registeredCallback
+	routes      map[string]func(context.Context, string) error
+	nextHandle  CallbackHandle
+	batch       func([]string) error
+	onError     func(error)
+	callbackSet chan struct{} // signals the processor to replay early messages
+	earlyMu     sync.Mutex
+	early       []string // messages received before a callback was set
+	jobs        chan job
+	orderMu     sync.Mutex // serializes callbacks with OrderedDelivery
+	debounced   debounceState
+	coalesced   coalesceState
+	spill       spillState
+	limiter     tokenBucket
+	asyncOnce   sync.Once
+	async       chan chan error // queued UpdateAsync results
+	bulkMu      sync.Mutex
+	readyOnce   sync.Once
+	readyClose  sync.Once
+	ready       chan struct{} // closed on the first subscription, see Ready
+	bulk        bulkState
+	closed      chan struct{}
+	messagesIn  chan redis.Message
+	localIn     chan redis.Message // issued by the watcher itself, e.g. PeriodicReload
+	once        sync.Once
+	startOnce   sync.Once
+	wg          sync.WaitGroup
+	subDone     chan struct{}
+	resubscribe chan struct{} // skips the wait before the next subscription attempt
+	closeErr    error
+	inflight    int32  // callbacks running, accessed atomically
+	squashed    int32  // 1 while a squashed update waits, see IsCallbackPending; accessed atomically
+	leader      int32  // 1 while holding the LeaderElection key, accessed atomically
+	loader      int32  // 1 while holding the DelegatedReload key, accessed atomically
+	createdAt   string // stack of the constructor call, reported on leaks
+	audit       auditLog
+	leakLogger  func(stack string)
+	pauseMu     sync.Mutex
+	pause       pauseState
+
+	lastMessage atomic.Value // string, see ShutdownReport.LastMessage
+	report      ShutdownReport
 }
 
 type WatcherMetrics struct {
@@ -29,8 +101,21 @@ type WatcherMetrics struct {
 	Protocol    string
 	Error       error
 	MessageSize int64
+	Receivers   int64 // Number of clients that received a published message.
 }
 
+// ErrNoSubscribers is returned by Update in strict delivery mode when no
+// client received the message.
+var ErrNoSubscribers = errors.New("rediswatcher: message was received by no subscribers")
+
+// ErrCallbackTimeout is reported when an update callback runs longer than the
+// CallbackTimeout.
+var ErrCallbackTimeout = errors.New("rediswatcher: update callback timed out")
+
+// ErrReconnected is passed to the error callback when the subscription has
+// been re-established after a failure.
+var ErrReconnected = errors.New("rediswatcher: subscription re-established")
+
 const (
 	RedisDoAuthMetric       = "RedisDoAuth"
 	RedisCloseMetric        = "RedisClose"
@@ -39,30 +124,60 @@ const (
 	PubSubReceiveMetric     = "PubSubReceive"
 	PubSubSubscribeMetric   = "PubSubSubscribe"
 	PubSubUnsubscribeMetric = "PubSubUnsubscribe"
+	PublishQueuePushMetric  = "PublishQueuePush"
+	PublishQueueFlushMetric = "PublishQueueFlush"
+	OutboxRelayMetric       = "OutboxRelay"
+	DeadLetterMetric        = "DeadLetter"
+	CallbackRetryMetric     = "CallbackRetry"
+	CallbackMetric          = "Callback"
+	ProbeLatencyMetric      = "ProbeLatency"
+	AuditStreamMetric       = "AuditStream"
+	WatcherUpdateMetric     = "WatcherUpdate"
+	WatcherCallbackMetric   = "WatcherCallback"
 )
 
 const (
 	defaultShortMessageInTimeout = 1 * time.Millisecond
 	defaultLongMessageInTimeout  = 1 * time.Minute
+	defaultOutboxInterval        = 1 * time.Second
+	defaultChannelCheckDelay     = 5 * time.Second
+	defaultCloseTimeout          = 5 * time.Second
+	defaultEarlyBufferSize       = 16
+	defaultCallbackWorkers       = 1
+	defaultCallbackQueueSize     = 64
 )
 
 // NewWatcher creates a new Watcher to be used with a Casbin enforcer
 // addr is a redis target string in the format "host:port"
 // setters allows for inline WatcherOptions
 //
-// 		Example:
-// 				w, err := rediswatcher.NewWatcher("127.0.0.1:6379", rediswatcher.Password("pass"), rediswatcher.Channel("/yourchan"))
+//	Example:
+//			w, err := rediswatcher.NewWatcher("127.0.0.1:6379", rediswatcher.Password("pass"), rediswatcher.Channel("/yourchan"))
 //
 // A custom redis.Conn can be provided to NewWatcher
 //
-// 		Example:
-// 				c, err := redis.Dial("tcp", ":6379")
-// 				w, err := rediswatcher.NewWatcher("", rediswatcher.WithRedisConnection(c)
+//	Example:
+//			c, err := redis.Dial("tcp", ":6379")
+//			w, err := rediswatcher.NewWatcher(":6379", rediswatcher.WithRedisConnection(c))
 //
-func NewWatcher(addr string, setters ...WatcherOption) (persist.Watcher, error) {
+// The result is a persist.Watcher as for the original watcher, the rest of
+// the API is reached through ExtendedWatcher or w.(*rediswatcher.Watcher).
+func NewWatcher(addr string, setters ...WatcherOption) (ExtendedWatcher, error) {
+	w, err := newWatcher(addr, setters...)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func newWatcher(addr string, setters ...WatcherOption) (*Watcher, error) {
 	w := &Watcher{
-		closed:     make(chan struct{}),
-		messagesIn: make(chan redis.Message),
+		addr:        addr,
+		closed:      make(chan struct{}),
+		messagesIn:  make(chan redis.Message),
+		localIn:     make(chan redis.Message),
+		resubscribe: make(chan struct{}, 1),
+		callbackSet: make(chan struct{}, 1),
 	}
 
 	w.options = WatcherOptions{
@@ -71,52 +186,71 @@ func NewWatcher(addr string, setters ...WatcherOption) (persist.Watcher, error)
 		LocalID:              uuid.New().String(),
 		SquashTimeoutShort:   defaultShortMessageInTimeout,
 		SquashTimeoutLong:    defaultLongMessageInTimeout,
+		OutboxInterval:       defaultOutboxInterval,
+		DrainTimeout:         defaultCloseTimeout,
+		EarlyBufferSize:      defaultEarlyBufferSize,
+		CallbackWorkers:      defaultCallbackWorkers,
+		CallbackQueueSize:    defaultCallbackQueueSize,
+		Logger:               stdoutLogger{},
+		ErrorHistorySize:     defaultErrorHistorySize,
 		resubscribeThreshold: 2 * time.Second,
 		subscriptionFailureCallback: func(err error) {
-			fmt.Printf("Failure from Redis subscription: %v\n", err)
+			if !w.logEvent(levelError, "redis subscription failed", "error", err) {
+				w.logf("Failure from Redis subscription: %v", err)
+			}
 		},
 	}
 
 	for _, setter := range setters {
 		setter(&w.options)
 	}
-
-	if err := w.connect(addr); err != nil {
+	if err := w.options.err; err != nil {
 		return nil, err
 	}
+	w.applyMetricsSink()
+	w.applyChannelPrefix()
+	w.state.disconnectedAt = w.clock().Now()
+	if w.options.ReceiveBufferSize > 0 {
+		w.messagesIn = make(chan redis.Message, w.options.ReceiveBufferSize)
+	}
+	if w.options.PublishOnly {
+		w.messagesIn = nil
+	}
 
-	// call destructor when the object is released
-	runtime.SetFinalizer(w, finalizer)
-
-	w.messageInProcessor()
-
-	go func() {
-		for {
-			select {
-			case <-w.closed:
-				return
-			default:
-				err := w.connect(addr)
-				if err == nil {
-					err = w.subscribe()
-				}
-				if err != nil {
-					if w.options.subscriptionFailureCallback != nil {
-						// Make callback on error
-						w.options.subscriptionFailureCallback(err)
-					}
-				}
-				time.Sleep(w.options.resubscribeThreshold)
+	if !w.options.LazyConnect {
+		if err := w.connectWithRetry(addr); err != nil {
+			return nil, err
+		}
+		if w.options.PreflightCheck {
+			if err := w.preflight(); err != nil {
+				return nil, err
 			}
 		}
-	}()
+	}
+
+	// warn about and clean up watchers released without Close
+	w.createdAt = string(debug.Stack())
+	runtime.SetFinalizer(w, finalizer)
+
+	if !w.options.ManualStart {
+		w.start()
+	}
 
 	return w, nil
 }
 
 // NewPublishWatcher return a Watcher only publish but not subscribe
-func NewPublishWatcher(addr string, setters ...WatcherOption) (persist.Watcher, error) {
+func NewPublishWatcher(addr string, setters ...WatcherOption) (ExtendedWatcher, error) {
+	w, err := newPublishWatcher(addr, setters...)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func newPublishWatcher(addr string, setters ...WatcherOption) (*Watcher, error) {
 	w := &Watcher{
+		addr:   addr,
 		closed: make(chan struct{}),
 	}
 
@@ -126,67 +260,375 @@ func NewPublishWatcher(addr string, setters ...WatcherOption) (persist.Watcher,
 		LocalID:            uuid.New().String(),
 		SquashTimeoutShort: defaultShortMessageInTimeout,
 		SquashTimeoutLong:  defaultLongMessageInTimeout,
+		OutboxInterval:     defaultOutboxInterval,
+		DrainTimeout:       defaultCloseTimeout,
+		Logger:             stdoutLogger{},
+		ErrorHistorySize:   defaultErrorHistorySize,
 	}
 
 	for _, setter := range setters {
 		setter(&w.options)
 	}
-
-	if err := w.connect(addr); err != nil {
+	if err := w.options.err; err != nil {
 		return nil, err
 	}
+	w.applyMetricsSink()
+	w.applyChannelPrefix()
+
+	if !w.options.LazyConnect {
+		if err := w.connectWithRetry(addr); err != nil {
+			return nil, err
+		}
+		if w.options.PreflightCheck {
+			if err := w.preflight(); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-	// call destructor when the object is released
+	// warn about and clean up watchers released without Close
+	w.createdAt = string(debug.Stack())
 	runtime.SetFinalizer(w, finalizer)
 
+	if !w.options.ManualStart {
+		w.start()
+	}
+
+	return w, nil
+}
+
+// NewWatcherWithContext works like NewWatcher, cancelling ctx closes the
+// watcher: the subscription, connections and any pending work are torn down.
+func NewWatcherWithContext(ctx context.Context, addr string, setters ...WatcherOption) (*Watcher, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	w, err := newWatcher(addr, setters...)
+	if err != nil {
+		return nil, err
+	}
+	w.closeOnDone(ctx)
+	return w, nil
+}
+
+// NewPublishWatcherWithContext works like NewPublishWatcher, cancelling ctx
+// closes the watcher.
+func NewPublishWatcherWithContext(ctx context.Context, addr string, setters ...WatcherOption) (*Watcher, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	w, err := newPublishWatcher(addr, setters...)
+	if err != nil {
+		return nil, err
+	}
+	w.closeOnDone(ctx)
 	return w, nil
 }
 
+// closeOnDone closes the watcher once ctx is done. It is not tracked by
+// spawn since Close waits for those goroutines.
+func (w *Watcher) closeOnDone(ctx context.Context) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.shutdown()
+		case <-w.closed:
+		}
+	}()
+}
+
 // SetUpdateCallBack sets the update callback function invoked by the watcher
 // when the policy is updated. Defaults to Enforcer.LoadPolicy()
+//
+// The signature is fixed by persist.Watcher, so a failure inside callback
+// goes unnoticed; use SetUpdateCallbackWithError to report it.
 func (w *Watcher) SetUpdateCallback(callback func(string)) error {
+	w.callbackMu.Lock()
+	w.callback = nil
+	if callback != nil {
+		w.callback = func(_ context.Context, msg string) error {
+			callback(msg)
+			return nil
+		}
+	}
+	w.callbackMu.Unlock()
+	w.notifyCallbackSet()
+	return nil
+}
+
+// SetUpdateCallbackWithError sets an update callback that reports failures,
+// e.g. a LoadPolicy error. A returned error is retried per CallbackRetry,
+// then pushed to the DeadLetterList when one is configured, passed to
+// SetErrorCallback and RecordMetrics and counted in Stats.FailedUpdates.
+func (w *Watcher) SetUpdateCallbackWithError(callback func(string) error) error {
+	w.callbackMu.Lock()
+	w.callback = nil
+	if callback != nil {
+		w.callback = func(_ context.Context, msg string) error {
+			return callback(msg)
+		}
+	}
+	w.callbackMu.Unlock()
+	w.notifyCallbackSet()
+	return nil
+}
+
+// SetUpdateCallbackWithContext sets an update callback receiving a context
+// that is cancelled once the CallbackTimeout expires or the watcher closes.
+func (w *Watcher) SetUpdateCallbackWithContext(callback func(ctx context.Context, msg string) error) error {
+	w.callbackMu.Lock()
 	w.callback = callback
+	w.callbackMu.Unlock()
+	w.notifyCallbackSet()
 	return nil
 }
 
+// getCallback returns the current update callback, combined with the
+// callbacks registered by AddCallback. Callbacks may be swapped at any time,
+// also while messages are being delivered.
+func (w *Watcher) getCallback() func(context.Context, string) error {
+	w.callbackMu.RLock()
+	defer w.callbackMu.RUnlock()
+	if len(w.callbacks) == 0 {
+		return w.callback
+	}
+
+	callbacks := make([]func(context.Context, string) error, 0, len(w.callbacks)+1)
+	if w.callback != nil {
+		callbacks = append(callbacks, w.callback)
+	}
+	for _, r := range w.callbacks {
+		callbacks = append(callbacks, r.callback)
+	}
+	return func(ctx context.Context, msg string) error {
+		var first error
+		for _, callback := range callbacks {
+			if err := callback(ctx, msg); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+}
+
 // Update publishes a message to all other casbin instances telling them to
-// invoke their update callback
+// invoke their update callback. It is safe for concurrent use.
+//
+// A PublishDelay or BeforePublish hook runs first, giving database replicas
+// time to catch up with the adapter write.
+//
+// When a PublishQueue is configured a failed publish is stored in Redis and
+// forwarded once publishing succeeds again, in which case Update returns nil.
+//
+// With PublishCoalesce, Update returns immediately and all calls within the
+// window result in a single message. Between BeginBulk and EndBulk it only
+// records that an update is due.
 func (w *Watcher) Update() error {
+	if w.options.SubscribeOnly {
+		return ErrSubscribeOnly
+	}
+	if w.suppressUpdate() {
+		return nil
+	}
+	if w.options.PublishCoalesce > 0 {
+		w.coalesceUpdate()
+		return nil
+	}
+	return w.publishUpdate()
+}
+
+func (w *Watcher) publishUpdate() error {
+	if w.IsLoader() {
+		return w.reloadForPeers()
+	}
+	if err := w.waitBeforePublish(); err != nil {
+		return err
+	}
+
+	return w.publishLocked(func() error {
+		return w.publishOrQueue(w.options.LocalID)
+	})
+}
+
+// waitBeforePublish holds a publish back until the policy change can be
+// expected to be visible to peers.
+func (w *Watcher) waitBeforePublish() error {
+	if w.options.PublishDelay > 0 {
+		select {
+		case <-w.clock().After(w.options.PublishDelay):
+		case <-w.closed:
+		}
+	}
+	if w.options.beforePublish != nil {
+		return w.options.beforePublish()
+	}
+	return nil
+}
+
+func (w *Watcher) publishOrQueue(msg string) error {
+	if w.options.SubscribeOnly {
+		return ErrSubscribeOnly
+	}
+	err := w.flushQueue()
+	if err == nil {
+		err = w.publish(msg)
+	}
+	if err != nil {
+		// nobody listening is not a connectivity problem, report it
+		if w.options.PublishQueue == "" || err == ErrNoSubscribers {
+			w.auditMessage("publish", msg, err)
+			return err
+		}
+		if qErr := w.enqueue(msg); qErr != nil {
+			w.auditMessage("publish", msg, err)
+			return err
+		}
+		err = fmt.Errorf("rediswatcher: publish failed, queued for retry: %v", err)
+		w.notifyError(err)
+	} else {
+		w.recordAudit(msg)
+	}
+	w.auditMessage("publish", msg, err)
+	return nil
+}
+
+func (w *Watcher) publish(msg string) error {
+	if err := w.waitPublishToken(); err != nil {
+		return err
+	}
+
+	receivers, err := w.publishOnce(msg)
+	if err != nil {
+		return err
+	}
+	if w.options.StrictDelivery && receivers == 0 {
+		return ErrNoSubscribers
+	}
+	w.publishDual(msg)
+	return nil
+}
+
+// publishDual publishes msg on the DualPublish channel after it went out on
+// the watcher channel. A failure is reported but does not fail the publish,
+// which would repeat it on the watcher channel when queued for retry.
+func (w *Watcher) publishDual(msg string) {
+	if w.options.dualChannel == "" || w.pubChannel != "" {
+		return
+	}
+	if w.options.dualConvert != nil {
+		msg = w.options.dualConvert(msg)
+	}
+
+	w.pubChannel = w.options.dualChannel
+	defer func() { w.pubChannel = "" }()
+	if _, err := w.publishOnce(msg); err != nil {
+		w.reportError(err)
+	}
+}
+
+// publishOnce publishes msg and returns the number of clients that received
+// it, or -1 when the reply does not tell.
+func (w *Watcher) publishOnce(msg string) (int64, error) {
+	if w.options.PublishDryRun {
+		if w.options.dryRunLogger != nil {
+			w.options.dryRunLogger(w.channel(), msg)
+		} else {
+			if !w.logEvent(levelInfo, "dry run, not publishing", "message", msg) {
+				w.logf("Dry run, not publishing on %s: %s", w.channel(), msg)
+			}
+		}
+		return -1, nil
+	}
+
 	startTime := time.Now()
-	if _, err := w.pubConn.Do("PUBLISH", w.options.Channel, w.options.LocalID); err != nil {
+	if err := w.publishFault(msg); err != nil {
+		w.setPublishFailing(true)
+		return 0, wrapError(ErrPublishFailed, err)
+	}
+	c, err := w.publisher()
+	if err != nil {
+		w.setPublishFailing(true)
+		return 0, wrapError(ErrPublishFailed, err)
+	}
+	reply, err := w.doPublish(c, msg)
+	w.setPublishFailing(err != nil)
+	if err != nil {
 		if w.options.RecordMetrics != nil {
 			w.options.RecordMetrics(w.createMetrics(PubSubPublishMetric, startTime, err))
 		}
-		return err
+		return 0, wrapError(ErrPublishFailed, err)
+	}
+	receivers, convErr := redis.Int64(reply, nil)
+	if convErr != nil {
+		receivers = -1
 	}
 	if w.options.RecordMetrics != nil {
-		w.options.RecordMetrics(w.createMetrics(PubSubPublishMetric, startTime, nil))
+		watcherMetrics := w.createMetrics(PubSubPublishMetric, startTime, nil)
+		watcherMetrics.MessageSize = int64(len(msg))
+		watcherMetrics.Receivers = receivers
+		w.options.RecordMetrics(watcherMetrics)
 	}
 
-	return nil
+	w.countPublished()
+	return receivers, nil
 }
 
-// Close disconnects the watcher from redis
+// Close unsubscribes, stops the background goroutines and disconnects the
+// watcher from redis. No new messages are accepted, callbacks already running
+// get up to DrainTimeout to finish before the connections are closed.
+//
+// Close has no result as required by persist.Watcher, use Closer for an
+// io.Closer reporting connection errors.
 func (w *Watcher) Close() {
-	finalizer(w)
+	w.shutdown()
 }
 
-func (w *Watcher) connect(addr string) error {
-	var pubConnErr error
-	if w.pubConn != nil {
-		pubConnErr = w.pubConn.Err()
+// Closer returns an io.Closer that closes the watcher.
+func (w *Watcher) Closer() io.Closer {
+	return closerFunc(w.shutdown)
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// spawn runs f in a background goroutine that Close waits for. It is
+// labelled with the channel and LocalID, so profiles attribute its work to
+// the watcher.
+func (w *Watcher) spawn(f func()) {
+	labels := pprof.Labels("rediswatcher", w.channel(), "localId", w.options.LocalID)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		pprof.Do(context.Background(), labels, func(context.Context) {
+			f()
+		})
+	}()
+}
+
+func (w *Watcher) isClosed() bool {
+	select {
+	case <-w.closed:
+		return true
+	default:
+		return false
 	}
-	if w.pubConn == nil || pubConnErr != nil {
-		if err := w.connectPub(addr); err != nil {
+}
+
+func (w *Watcher) connect(addr string) error {
+	if !w.options.SubscribeOnly {
+		w.pubMu.Lock()
+		_, err := w.publisher()
+		w.pubMu.Unlock()
+		if err != nil {
 			return err
 		}
 	}
 
-	var subConnErr error
-	if w.subConn != nil {
-		subConnErr = w.subConn.Err()
-	}
-	if w.subConn == nil || subConnErr != nil {
+	subConn := w.getSubConn()
+	if (subConn == nil || subConn.Err() != nil) && !w.options.PublishOnly && !w.options.Synchronous {
 		if err := w.connectSub(addr); err != nil {
 			return err
 		}
@@ -195,6 +637,36 @@ func (w *Watcher) connect(addr string) error {
 	return nil
 }
 
+// publisher returns the publish connection, dialing it on first use for
+// watchers created with LazyConnect and re-dialing a broken one, or one idle
+// beyond the PublishIdleTimeout.
+//
+// Callers must hold pubMu: a redis.Conn must not be used concurrently, so
+// this lock is what makes Update and the other publishing methods safe to
+// call from several goroutines.
+func (w *Watcher) publisher() (redis.Conn, error) {
+	if w.options.SubscribeOnly {
+		return nil, ErrSubscribeOnly
+	}
+	if w.pubConn != nil && w.options.PubConn == nil {
+		if w.pubConn.Err() != nil {
+			w.pubConn.Close()
+			w.pubConn = nil
+		} else if idle := w.options.PublishIdleTimeout; idle > 0 && time.Since(w.pubUsed) > idle {
+			w.logEvent(levelDebug, "publish connection idle, re-dialing", "idle", time.Since(w.pubUsed))
+			w.pubConn.Close()
+			w.pubConn = nil
+		}
+	}
+	if w.pubConn == nil {
+		if err := w.connectPub(w.addr); err != nil {
+			return nil, err
+		}
+	}
+	w.pubUsed = time.Now()
+	return w.pubConn, nil
+}
+
 func (w *Watcher) connectPub(addr string) error {
 	if w.options.PubConn != nil {
 		w.pubConn = w.options.PubConn
@@ -211,7 +683,7 @@ func (w *Watcher) connectPub(addr string) error {
 
 func (w *Watcher) connectSub(addr string) error {
 	if w.options.SubConn != nil {
-		w.subConn = w.options.SubConn
+		w.setSubConn(w.options.SubConn)
 		return nil
 	}
 
@@ -219,14 +691,67 @@ func (w *Watcher) connectSub(addr string) error {
 	if err != nil {
 		return err
 	}
-	w.subConn = *c
+	w.setSubConn(*c)
 	return nil
 }
 
+func (w *Watcher) getSubConn() redis.Conn {
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	return w.subConn
+}
+
+func (w *Watcher) setSubConn(c redis.Conn) {
+	w.subMu.Lock()
+	w.subConn = c
+	w.subMu.Unlock()
+}
+
+func (w *Watcher) dialOptions() []redis.DialOption {
+	var options []redis.DialOption
+	if w.options.TLSConfig != nil {
+		options = append(options, redis.DialUseTLS(true), redis.DialTLSConfig(w.options.TLSConfig))
+	}
+	if w.dialTimeout() > 0 {
+		options = append(options, redis.DialConnectTimeout(w.dialTimeout()))
+	}
+	if w.options.SocketReadBuffer > 0 || w.options.SocketWriteBuffer > 0 {
+		options = append(options, redis.DialNetDial(w.netDial))
+	}
+	return options
+}
+
+// netDial dials like redigo does by default, then sizes the socket buffers.
+func (w *Watcher) netDial(network, addr string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: w.dialTimeout(), KeepAlive: 5 * time.Minute}
+	c, err := dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := c.(*net.TCPConn); ok {
+		if n := w.options.SocketReadBuffer; n > 0 {
+			err = tc.SetReadBuffer(n)
+		}
+		if n := w.options.SocketWriteBuffer; n > 0 && err == nil {
+			err = tc.SetWriteBuffer(n)
+		}
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
 func (w *Watcher) dial(addr string) (*redis.Conn, error) {
 	startTime := time.Now()
-	c, err := redis.Dial(w.options.Protocol, addr)
+	err := w.dialFault(addr)
+	var c redis.Conn
+	if err == nil {
+		c, err = redis.Dial(w.options.Protocol, addr, w.dialOptions()...)
+	}
 	if err != nil {
+		atomic.AddInt64(&w.counters.dialErrors, 1)
 		if w.options.RecordMetrics != nil {
 			w.options.RecordMetrics(w.createMetrics(RedisDialMetric, startTime, err))
 		}
@@ -235,9 +760,9 @@ func (w *Watcher) dial(addr string) (*redis.Conn, error) {
 	if w.options.RecordMetrics != nil {
 		w.options.RecordMetrics(w.createMetrics(RedisDialMetric, startTime, nil))
 	}
-	if w.options.Password != "" {
+	if w.password() != "" {
 		startTime = time.Now()
-		_, err = c.Do("AUTH", w.options.Password)
+		_, err = c.Do("AUTH", w.password())
 		if err != nil {
 			if w.options.RecordMetrics != nil {
 				w.options.RecordMetrics(w.createMetrics(RedisDoAuthMetric, startTime, err))
@@ -247,27 +772,74 @@ func (w *Watcher) dial(addr string) (*redis.Conn, error) {
 			if w.options.RecordMetrics != nil {
 				w.options.RecordMetrics(w.createMetrics(RedisCloseMetric, startTime, err2))
 			}
-			return nil, err
+			atomic.AddInt64(&w.counters.dialErrors, 1)
+			atomic.AddInt64(&w.counters.authFailures, 1)
+			return nil, wrapError(ErrAuthFailed, err)
 		}
 		if w.options.RecordMetrics != nil {
 			w.options.RecordMetrics(w.createMetrics(RedisDoAuthMetric, startTime, nil))
 		}
 	}
+	atomic.AddInt64(&w.counters.dials, 1)
 	return &c, nil
 }
 
+// confirmSubscription marks the watcher subscribed and reports whether it
+// was not before. On the first confirmation the channels subscribed are
+// brought up to date: SubscribeChannel and UnsubscribeChannel calls in
+// between found the watcher not yet subscribed and left that to the
+// subscription loop.
+func (w *Watcher) confirmSubscription(psc redis.PubSubConn, subscribed []interface{}) bool {
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	if !w.setSubscribed(true) {
+		return false
+	}
+	current := w.channelsFor(0)
+	if added := missingChannels(current, subscribed); len(added) > 0 {
+		psc.Subscribe(added...)
+	}
+	if removed := missingChannels(subscribed, current); len(removed) > 0 {
+		psc.Unsubscribe(removed...)
+	}
+	return true
+}
+
+// missingChannels returns the channels of a that are not in b.
+func missingChannels(a, b []interface{}) []interface{} {
+	var missing []interface{}
+next:
+	for _, channel := range a {
+		for _, c := range b {
+			if c == channel {
+				continue next
+			}
+		}
+		missing = append(missing, channel)
+	}
+	return missing
+}
+
+// unsubscribe leaves the subscription on psc. Callers must hold subMu.
 func (w *Watcher) unsubscribe(psc redis.PubSubConn) {
 	startTime := time.Now()
-	err := psc.Unsubscribe()
+	err := w.leave(psc)
 	if w.options.RecordMetrics != nil {
 		w.options.RecordMetrics(w.createMetrics(PubSubUnsubscribeMetric, startTime, err))
 	}
 }
 
 func (w *Watcher) subscribe() error {
+	w.subMu.Lock()
 	psc := redis.PubSubConn{Conn: w.subConn}
 	startTime := time.Now()
-	if err := psc.Subscribe(w.options.Channel); err != nil {
+	channels := w.channelsFor(0)
+	err := psc.Subscribe(channels...)
+	if err == nil && len(w.options.patterns) > 0 {
+		err = psc.PSubscribe(w.patterns()...)
+	}
+	w.subMu.Unlock()
+	if err != nil {
 		if w.options.RecordMetrics != nil {
 			w.options.RecordMetrics(w.createMetrics(PubSubSubscribeMetric, startTime, err))
 		}
@@ -276,9 +848,21 @@ func (w *Watcher) subscribe() error {
 	if w.options.RecordMetrics != nil {
 		w.options.RecordMetrics(w.createMetrics(PubSubSubscribeMetric, startTime, nil))
 	}
-	defer w.unsubscribe(psc)
+	left := false
+	defer func() {
+		// after a confirmed unsubscribe another UNSUBSCRIBE would leave a
+		// stray reply for the next subscription
+		if !left {
+			w.subMu.Lock()
+			w.unsubscribe(psc)
+			w.subMu.Unlock()
+		}
+	}()
 
 	for {
+		if w.isClosed() {
+			return nil
+		}
 		startTime := time.Now()
 		msg := psc.Receive()
 		switch n := msg.(type) {
@@ -286,71 +870,241 @@ func (w *Watcher) subscribe() error {
 			if w.options.RecordMetrics != nil {
 				w.options.RecordMetrics(w.createMetrics(PubSubReceiveMetric, startTime, n))
 			}
-			return n
+			return wrapError(ErrSubscribeClosed, n)
 		case redis.Message:
-			if w.options.RecordMetrics != nil {
-				watcherMetrics := w.createMetrics(PubSubReceiveMetric, startTime, nil)
-				watcherMetrics.MessageSize = int64(len(n.Data))
-				w.options.RecordMetrics(watcherMetrics)
+			if !w.received(startTime, n) {
+				return nil
+			}
+		case redis.PMessage:
+			if !w.received(startTime, redis.Message{Channel: n.Channel, Data: n.Data}) {
+				return nil
 			}
-			w.messagesIn <- msg.(redis.Message)
 		case redis.Subscription:
 			if w.options.RecordMetrics != nil {
 				w.options.RecordMetrics(w.createMetrics(PubSubReceiveMetric, startTime, nil))
 			}
 			if n.Count == 0 {
+				left = true
 				return nil
 			}
+			if w.confirmSubscription(psc, channels) {
+				w.logEvent(levelInfo, "subscribed")
+				w.markReady()
+				if w.reconnects() > 0 {
+					w.notifyError(ErrReconnected)
+					if w.options.onResubscribed != nil {
+						w.options.onResubscribed()
+					}
+				} else if w.options.onSubscribed != nil {
+					w.options.onSubscribed()
+				}
+				w.announce()
+			}
 		}
 
 	}
 }
 
+// received hands a message to the processor. It returns false when the
+// watcher closed instead.
+func (w *Watcher) received(startTime time.Time, msg redis.Message) bool {
+	w.countReceived()
+	w.logEvent(levelDebug, "message received", "size", len(msg.Data))
+	if w.options.RecordMetrics != nil {
+		watcherMetrics := w.createMetrics(PubSubReceiveMetric, startTime, nil)
+		watcherMetrics.MessageSize = int64(len(msg.Data))
+		w.options.RecordMetrics(watcherMetrics)
+	}
+	if w.options.faults == nil {
+		select {
+		case w.messagesIn <- msg:
+			return true
+		case <-w.closed:
+			return false
+		}
+	}
+	msgs, ok := w.receiveFault(msg)
+	for _, msg := range msgs {
+		select {
+		case w.messagesIn <- msg:
+		case <-w.closed:
+			return false
+		}
+	}
+	return ok
+}
+
 func (w *Watcher) messageInProcessor() {
-	w.options.callbackPending = false
+	atomic.StoreInt32(&w.squashed, 0)
 	var data string
+	var last Delivery          // of data
+	var pendingSince time.Time // first squashed message not yet delivered
 	timeOut := w.options.SquashTimeoutLong
-	go func() {
+	handle := func(msg redis.Message, local bool) {
+		delivered, ok := w.acceptMessage(msg, local)
+		if !ok {
+			return
+		}
+		data, last = delivered.msg, delivered.delivery
+
+		switch {
+		case !w.options.IgnoreSelf && !w.options.SquashMessages:
+			w.deliverJob(delivered)
+		case w.options.IgnoreSelf && data == w.options.LocalID: // ignore message
+		case !w.options.IgnoreSelf && w.options.SquashMessages:
+			atomic.StoreInt32(&w.squashed, 1)
+		case w.options.IgnoreSelf && data != w.options.LocalID && !w.options.SquashMessages:
+			w.deliverJob(delivered)
+		case w.options.IgnoreSelf && data != w.options.LocalID && w.options.SquashMessages:
+			atomic.StoreInt32(&w.squashed, 1)
+		default:
+			w.deliverJob(delivered)
+		}
+		if atomic.LoadInt32(&w.squashed) == 1 { // set short timeout
+			if pendingSince.IsZero() {
+				pendingSince = time.Now()
+			}
+			timeOut = w.squashTimeout(pendingSince)
+		}
+	}
+	process := func() {
+		// one timer for the loop, time.After would allocate one per message
+		timer := time.NewTimer(timeOut)
+		defer timer.Stop()
 		for {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(timeOut)
 			select {
 			case <-w.closed:
 				return
 			case msg := <-w.messagesIn:
-				if w.callback != nil {
-					data = string(msg.Data)
-
-					switch {
-					case !w.options.IgnoreSelf && !w.options.SquashMessages:
-						w.callback(data)
-					case w.options.IgnoreSelf && data == w.options.LocalID: // ignore message
-					case !w.options.IgnoreSelf && w.options.SquashMessages:
-						w.options.callbackPending = true
-					case w.options.IgnoreSelf && data != w.options.LocalID && !w.options.SquashMessages:
-						w.callback(data)
-					case w.options.IgnoreSelf && data != w.options.LocalID && w.options.SquashMessages:
-						w.options.callbackPending = true
-					default:
-						w.callback(data)
+				handle(msg, false)
+			case msg := <-w.localIn:
+				handle(msg, true)
+			case <-w.callbackSet:
+				// replay what arrived before the callback was registered
+				for _, early := range w.takeEarlyMessages() {
+					if w.options.IgnoreSelf && early == w.options.LocalID {
+						continue
+					}
+					data, last = early, Delivery{}
+					if w.options.SquashMessages {
+						atomic.StoreInt32(&w.squashed, 1)
+					} else {
+						w.deliver(data)
 					}
 				}
-				if w.options.callbackPending { // set short timeout
-					timeOut = w.options.SquashTimeoutShort
+				if atomic.LoadInt32(&w.squashed) == 1 {
+					if pendingSince.IsZero() {
+						pendingSince = time.Now()
+					}
+					timeOut = w.squashTimeout(pendingSince)
 				}
-			case <-time.After(timeOut):
-				if w.options.callbackPending {
-					w.options.callbackPending = false
-					w.callback(data)                      // data will be last message recieved
+			case <-timer.C:
+				if atomic.CompareAndSwapInt32(&w.squashed, 1, 0) {
+					pendingSince = time.Time{}
+					// data will be last message recieved
+					w.deliverJob(job{msg: data, delivery: last})
 					timeOut = w.options.SquashTimeoutLong // long timeout
 				}
 			}
 		}
-	}()
+	}
+	w.spawn(func() {
+		for w.restartOnPanic("message processor", process) {
+		}
+	})
+}
+
+// acceptMessage runs the checks of the processing loop on msg and returns
+// the job for the update callbacks. It reports false when msg needs no
+// further handling: untrusted, a control message, filtered, routed to a
+// channel callback or buffered until a callback is set. Local messages,
+// issued by the watcher itself, skip the TrustedPublishers check.
+func (w *Watcher) acceptMessage(msg redis.Message, local bool) (job, bool) {
+	data := string(msg.Data)
+	m, structured := decodeHeader(data)
+	if structured && m.Type == MessageTypePolicy {
+		m, _ = decodeMessage(data) // the answer to FetchPolicy
+	}
+	if !local && !w.trustedSender(m, structured, data) {
+		w.logEvent(levelWarn, "message from untrusted publisher ignored", "channel", msg.Channel)
+		return job{}, false
+	}
+	if structured && w.handleControlMessage(m) {
+		return job{}, false
+	}
+	if filter := w.options.messageFilter; filter != nil && !filter(data) {
+		return job{}, false
+	}
+	if w.getChannelCallback(msg.Channel) != nil {
+		if !w.options.IgnoreSelf || data != w.options.LocalID {
+			w.dispatchJob(job{msg: data, channel: msg.Channel, delivery: w.newDelivery(msg.Channel)})
+		}
+		return job{}, false
+	}
+	if !w.hasCallback() {
+		w.bufferEarlyMessage(data)
+		return job{}, false
+	}
+	return job{msg: data, delivery: w.newDelivery(msg.Channel)}, true
+}
+
+// squashTimeout returns how long to wait for further messages before
+// delivering a squashed one, cut short so that it is delivered no later than
+// SquashMaxDelay after the first message it stands for.
+func (w *Watcher) squashTimeout(pendingSince time.Time) time.Duration {
+	timeOut := w.options.SquashTimeoutShort
+	if max := w.options.SquashMaxDelay; max > 0 {
+		if left := max - time.Since(pendingSince); left < timeOut {
+			timeOut = left
+		}
+	}
+	return timeOut
+}
+
+// reportError hands errors from background work to the failure callback.
+func (w *Watcher) reportError(err error) {
+	atomic.AddInt64(&w.counters.errors, 1)
+	w.stateMu.Lock()
+	w.recordError(err, time.Now())
+	w.stateMu.Unlock()
+	if w.options.subscriptionFailureCallback != nil {
+		w.options.subscriptionFailureCallback(err)
+	}
+	w.notifyError(err)
+}
+
+// SetErrorCallback sets a callback receiving every problem of the watcher:
+// subscription failures, reconnects (ErrReconnected), publish retries and
+// errors returned by update callbacks. It runs on the goroutine hitting the
+// problem and must not block.
+func (w *Watcher) SetErrorCallback(callback func(error)) {
+	w.callbackMu.Lock()
+	defer w.callbackMu.Unlock()
+	w.onError = callback
+}
+
+// notifyError hands err to the error callback only, for problems that the
+// failure callback never received.
+func (w *Watcher) notifyError(err error) {
+	w.callbackMu.RLock()
+	callback := w.onError
+	w.callbackMu.RUnlock()
+	if callback != nil {
+		callback(err)
+	}
 }
 
 func (w *Watcher) createMetrics(metricsName string, startTime time.Time, err error) *WatcherMetrics {
 	return &WatcherMetrics{
 		Name:      metricsName,
-		Channel:   w.options.Channel,
+		Channel:   w.channel(),
 		LocalID:   w.options.LocalID,
 		Protocol:  w.options.Protocol,
 		LatencyMs: float64(time.Since(startTime)) / float64(time.Millisecond),
@@ -358,23 +1112,109 @@ func (w *Watcher) createMetrics(metricsName string, startTime time.Time, err err
 	}
 }
 
-// return option settings
+// GetWatcherOptions returns the option settings, including the Password and
+// connections; see Options for a redacted form to log.
 func (w *Watcher) GetWatcherOptions() WatcherOptions {
+	w.optMu.RLock()
+	defer w.optMu.RUnlock()
 	return w.options
 }
 
+// finalizer is only a safety net: a watcher must be closed with Close.
+// Background goroutines keep a subscribing watcher reachable, so it can only
+// fire for watchers without them, e.g. plain publish watchers.
 func finalizer(w *Watcher) {
+	if !w.isClosed() {
+		if w.leakLogger != nil {
+			w.leakLogger(w.createdAt)
+		} else {
+			if !w.logEvent(levelWarn, "watcher leaked without Close", "createdAt", w.createdAt) {
+				w.logf("rediswatcher: watcher leaked without Close, created at:\n%s", w.createdAt)
+			}
+		}
+	}
+	w.shutdown()
+}
+
+func (w *Watcher) shutdown() error {
 	w.once.Do(func() {
-		close(w.closed)
 		startTime := time.Now()
-		err := w.subConn.Close()
-		if w.options.RecordMetrics != nil {
-			w.options.RecordMetrics(w.createMetrics(RedisCloseMetric, startTime, err))
+		// an update still waiting for its coalescing window goes out now
+		w.flushCoalesced()
+		close(w.closed)
+		unregisterWatcher(w)
+		w.unpublishExpvar()
+		defer w.audit.close()
+
+		// leave the channel cleanly before dropping the connection
+		if w.subDone != nil && w.isSubscribed() {
+			w.subMu.Lock()
+			w.unsubscribe(redis.PubSubConn{Conn: w.subConn})
+			w.subMu.Unlock()
+			waitTimeout(w.subDone, w.drainTimeout())
 		}
-		startTime = time.Now()
-		err = w.pubConn.Close()
-		if w.options.RecordMetrics != nil {
-			w.options.RecordMetrics(w.createMetrics(RedisCloseMetric, startTime, err))
+		// let a running policy reload finish with working connections
+		w.drainCallbacks(w.drainTimeout())
+		w.closeShards()
+		w.leavePresence()
+		w.sayGoodbye()
+		w.resign()
+		w.resignLoader()
+
+		// closing the subscription ends a loop still waiting for a reply,
+		// the background publishers are waited for before their connections
+		var errs []error
+		w.subMu.Lock()
+		if w.subConn != nil {
+			errs = append(errs, w.closeConn(w.subConn))
+		}
+		w.subMu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			w.wg.Wait()
+			close(done)
+		}()
+		waitTimeout(done, w.drainTimeout())
+
+		w.pubMu.Lock()
+		if w.queueConn != nil {
+			errs = append(errs, w.closeConn(w.queueConn))
+		}
+		if w.pubConn != nil {
+			errs = append(errs, w.closeConn(w.pubConn))
+		}
+		w.pubMu.Unlock()
+
+		for _, err := range errs {
+			if err != nil {
+				w.closeErr = err
+				break
+			}
 		}
+		w.report = w.shutdownReport(startTime)
+		w.logEvent(levelInfo, "watcher closed", "queued", w.report.QueuedUpdates,
+			"cancelled", w.report.CancelledCallbacks, "lastMessage", w.report.LastMessage)
 	})
+	return w.closeErr
+}
+
+// closeConn closes c, recording the RedisCloseMetric.
+func (w *Watcher) closeConn(c redis.Conn) error {
+	startTime := time.Now()
+	err := c.Close()
+	if w.options.RecordMetrics != nil {
+		w.options.RecordMetrics(w.createMetrics(RedisCloseMetric, startTime, err))
+	}
+	return err
+}
+
+// waitTimeout waits for done to be closed, giving up after d.
+func waitTimeout(done <-chan struct{}, d time.Duration) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(d):
+		return false
+	}
 }
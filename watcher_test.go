@@ -1,10 +1,17 @@
 package rediswatcher
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/casbin/casbin/v2"
+	"github.com/garyburd/redigo/redis"
 	"github.com/rafaeljusto/redigomock"
 )
 
@@ -16,8 +23,40 @@ func NewTestConn() *testConn {
 	tc := &testConn{*redigomock.NewConn()}
 	return tc
 }
+
+// recordConn is a testConn that remembers the arguments of every Do and Send
+// call.
+type recordConn struct {
+	*testConn
+	mu       sync.Mutex
+	commands map[string][][]interface{}
+}
+
+func newRecordConn() *recordConn {
+	return &recordConn{testConn: NewTestConn(), commands: map[string][][]interface{}{}}
+}
+
+func (c *recordConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	c.commands[commandName] = append(c.commands[commandName], args)
+	c.mu.Unlock()
+	return c.testConn.Do(commandName, args...)
+}
+
+func (c *recordConn) Send(commandName string, args ...interface{}) error {
+	c.mu.Lock()
+	c.commands[commandName] = append(c.commands[commandName], args)
+	c.mu.Unlock()
+	return c.testConn.Send(commandName, args...)
+}
+
+func (c *recordConn) calls(commandName string) [][]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.commands[commandName]
+}
 func TestWatcher(t *testing.T) {
-	if _, err := NewWatcher(""); err == nil {
+	if _, err := newWatcher(""); err == nil {
 		t.Error("Connecting to nothing should fail")
 	}
 
@@ -34,14 +73,12 @@ func TestWatcher(t *testing.T) {
 
 	c.Command("SUBSCRIBE", "/casbin").Expect(values)
 
-	w, err := NewWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c))
+	w, err := newWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c))
 	if err != nil {
 		t.Fatalf("Failed to connect to Redis: %v", err)
 	}
 
-	wi := w.(interface{})
-	rediswatch := wi.(*Watcher)
-	c.Command("PUBLISH", "/casbin", rediswatch.GetWatcherOptions().LocalID).Expect("1")
+	c.Command("PUBLISH", "/casbin", w.GetWatcherOptions().LocalID).Expect("1")
 
 	if err := w.Update(); err != nil {
 		t.Fatalf("Failed watcher.Update(): %v", err)
@@ -50,6 +87,11 @@ func TestWatcher(t *testing.T) {
 	closed := false
 	c.CloseMock = func() error {
 		closed = true
+		// like a real connection, closing fails a pending Receive
+		select {
+		case c.ReceiveNow <- true:
+		default:
+		}
 		return nil
 	}
 
@@ -87,7 +129,7 @@ func TestWithEnforcer(t *testing.T) {
 	values = append(values, interface{}([]byte("casbin rules updated")))
 	c.AddSubscriptionMessage(values)
 
-	w, err := NewWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c))
+	w, err := newWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c))
 	if err != nil {
 		t.Fatalf("Failed to connect to Redis: %v", err)
 	}
@@ -105,8 +147,18 @@ func TestWithEnforcer(t *testing.T) {
 
 	e.SavePolicy()
 
+	// the SUBSCRIBE reply may still be queued in the mock, keep receiving
+	// until the message arrives
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		c.ReceiveNow <- true
+		for {
+			select {
+			case c.ReceiveNow <- true:
+			case <-done:
+				return
+			}
+		}
 	}()
 
 	select {
@@ -138,7 +190,7 @@ func TestWithEnforcerSquash(t *testing.T) {
 	subValues = append(subValues, interface{}([]byte("1")))
 	c.Command("SUBSCRIBE", "/casbin").Expect(subValues)
 
-	w, err := NewWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c), SquashMessages(true))
+	w, err := newWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c), SquashMessages(true))
 	if err != nil {
 		t.Fatalf("Failed to connect to Redis: %v", err)
 	}
@@ -146,9 +198,7 @@ func TestWithEnforcerSquash(t *testing.T) {
 	values := []interface{}{}
 	values = append(values, interface{}([]byte("message")))
 	values = append(values, interface{}([]byte("/casbin")))
-	wp := w.(interface{})
-	rw := wp.(*Watcher)
-	values = append(values, interface{}([]byte(rw.GetWatcherOptions().LocalID)))
+	values = append(values, interface{}([]byte(w.GetWatcherOptions().LocalID)))
 	c.AddSubscriptionMessage(values)
 	c.AddSubscriptionMessage(values)
 
@@ -172,8 +222,8 @@ func TestWithEnforcerSquash(t *testing.T) {
 
 	select {
 	case res := <-ch:
-		if res != rw.GetWatcherOptions().LocalID {
-			t.Fatalf("Message should be '%s', received '%v' instead", rw.GetWatcherOptions().LocalID, res)
+		if res != w.GetWatcherOptions().LocalID {
+			t.Fatalf("Message should be '%s', received '%v' instead", w.GetWatcherOptions().LocalID, res)
 		}
 	case <-time.After(time.Second * 5):
 		t.Fatal("Enforcer message timed out")
@@ -205,7 +255,7 @@ func TestWithEnforcerIgnoreSelf(t *testing.T) {
 	subValues = append(subValues, interface{}([]byte("1")))
 	c.Command("SUBSCRIBE", "/casbin").Expect(subValues)
 
-	w, err := NewWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c), IgnoreSelf(true))
+	w, err := newWatcher("127.0.0.1:6379", WithRedisSubConnection(c), WithRedisPubConnection(c), IgnoreSelf(true))
 	if err != nil {
 		t.Fatalf("Failed to connect to Redis: %v", err)
 	}
@@ -213,9 +263,7 @@ func TestWithEnforcerIgnoreSelf(t *testing.T) {
 	values := []interface{}{}
 	values = append(values, interface{}([]byte("message")))
 	values = append(values, interface{}([]byte("/casbin")))
-	wp := w.(interface{})
-	rw := wp.(*Watcher)
-	values = append(values, interface{}([]byte(rw.GetWatcherOptions().LocalID)))
+	values = append(values, interface{}([]byte(w.GetWatcherOptions().LocalID)))
 	c.AddSubscriptionMessage(values)
 
 	e, err := casbin.NewEnforcer("examples/rbac_model.conf", "examples/rbac_policy.csv")
@@ -242,3 +290,173 @@ func TestWithEnforcerIgnoreSelf(t *testing.T) {
 	case <-time.After(time.Millisecond * 50):
 	}
 }
+
+func TestCloser(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+	closeErr := fmt.Errorf("already closed")
+	pub.CloseMock = func() error {
+		return closeErr
+	}
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	var closer io.Closer = w.Closer()
+	if err := closer.Close(); err != closeErr {
+		t.Fatalf("Close should report the connection error, got %v", err)
+	}
+	// closing again is a no-op
+	w.Close()
+}
+
+func TestLeakWarning(t *testing.T) {
+	pub := NewTestConn()
+	sub := NewTestConn()
+
+	w, err := newPublishWatcher("127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub))
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	var stack string
+	w.leakLogger = func(s string) {
+		stack = s
+	}
+	finalizer(w)
+	if !strings.Contains(stack, "TestLeakWarning") {
+		t.Fatalf("Leak warning should include the creation stack, got '%s'", stack)
+	}
+
+	// a closed watcher is not reported
+	stack = ""
+	finalizer(w)
+	if stack != "" {
+		t.Fatal("Closed watcher reported as leaked")
+	}
+}
+
+func TestWatcherWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pub := NewTestConn()
+	sub := NewTestConn()
+	closed := make(chan struct{})
+	pub.CloseMock = func() error {
+		close(closed)
+		return nil
+	}
+
+	if _, err := NewPublishWatcherWithContext(ctx, "127.0.0.1:6379", WithRedisPubConnection(pub), WithRedisSubConnection(sub)); err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	cancel()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Cancelling the context did not close the watcher")
+	}
+
+	if _, err := NewWatcherWithContext(ctx, "127.0.0.1:6379"); err != context.Canceled {
+		t.Fatalf("Cancelled context should fail construction, got %v", err)
+	}
+}
+
+func TestSquashMaxDelay(t *testing.T) {
+	w := &Watcher{
+		closed:      make(chan struct{}),
+		messagesIn:  make(chan redis.Message),
+		callbackSet: make(chan struct{}, 1),
+	}
+	for _, setter := range []WatcherOption{SquashMessages(true), SquashTimeoutShort(30 * time.Millisecond),
+		SquashTimeoutLong(time.Second), SquashMaxDelay(50 * time.Millisecond)} {
+		setter(&w.options)
+	}
+	ch := make(chan string, 10)
+	w.SetUpdateCallback(func(msg string) { ch <- msg })
+	w.messageInProcessor()
+	defer close(w.closed)
+
+	// messages keep coming faster than the short timeout
+	start := time.Now()
+	for time.Since(start) < 150*time.Millisecond {
+		w.messagesIn <- redis.Message{Channel: "/casbin", Data: []byte("node2")}
+		select {
+		case <-ch:
+			if elapsed := time.Since(start); elapsed > 120*time.Millisecond {
+				t.Fatalf("Squashed message should be delivered within the max delay, took %v", elapsed)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Fatal("Squashing held the message back beyond the max delay")
+}
+
+func TestSocketBuffers(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+
+	w := &Watcher{}
+	SocketBuffers(64<<10, 32<<10)(&w.options)
+	c, err := w.netDial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+	if len(w.dialOptions()) == 0 {
+		t.Fatal("Socket buffers should add a dial option")
+	}
+}
+
+func TestReceiveBufferSize(t *testing.T) {
+	w, _ := newWatcher("", ManualStart(true), ReceiveBufferSize(16),
+		WithRedisPubConnection(redigomock.NewConn()), WithRedisSubConnection(redigomock.NewConn()))
+	defer w.Close()
+	if cap(w.messagesIn) != 16 {
+		t.Fatalf("The receive buffer should hold 16 messages, got %d", cap(w.messagesIn))
+	}
+}
+
+func TestPublishIdleTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	accepted := make(chan net.Conn, 4)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	w := &Watcher{addr: l.Addr().String(), options: WatcherOptions{Protocol: "tcp"}}
+	PublishIdleTimeout(20 * time.Millisecond)(&w.options)
+	first, _ := w.publisher()
+	if c, _ := w.publisher(); c != first {
+		t.Fatal("A busy connection should be kept")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if c, err := w.publisher(); err != nil || c == first {
+		t.Fatalf("An idle connection should be re-dialed, got %v", err)
+	}
+	w.pubConn.Close()
+	for i := 0; i < 2; i++ {
+		select {
+		case c := <-accepted:
+			c.Close()
+		case <-time.After(time.Second):
+			t.Fatalf("Expected 2 dials, got %d", i)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package rediswatcher
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	webhookAttempts  = 3
+	webhookBackoff   = time.Second
+	webhookQueueSize = 256
+)
+
+type webhook struct {
+	url    string
+	secret string
+}
+
+// webhookClient posts the webhook requests.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// startWebhooks delivers received updates to the Webhook URLs from a
+// goroutine of its own, so slow endpoints never hold up callbacks.
+func (w *Watcher) startWebhooks() {
+	if len(w.options.webhooks) == 0 {
+		return
+	}
+
+	events := make(chan Event, webhookQueueSize)
+	w.AddCallback(func(msg string) {
+		e := Event{Time: time.Now(), Message: msg, Type: MessageTypeUpdate, Sender: msg}
+		if m, ok := decodeMessage(msg); ok {
+			e.Type, e.Sender = m.Type, m.ID
+		}
+		select {
+		case events <- e:
+		default:
+			w.reportError(fmt.Errorf("rediswatcher: webhook queue full, dropping update %s", msg))
+		}
+	})
+	w.spawn(func() {
+		for {
+			select {
+			case <-w.closed:
+				return
+			case e := <-events:
+				body, _ := json.Marshal(e)
+				for _, hook := range w.options.webhooks {
+					if err := w.postWebhook(hook, body); err != nil {
+						w.reportError(err)
+					}
+				}
+			}
+		}
+	})
+}
+
+// postWebhook posts body to hook, retrying failures with backoff.
+func (w *Watcher) postWebhook(hook webhook, body []byte) error {
+	backoff := webhookBackoff
+	err := sendWebhook(hook, body)
+	for attempt := 1; err != nil && attempt < webhookAttempts; attempt++ {
+		select {
+		case <-w.closed:
+			return fmt.Errorf("rediswatcher: webhook %s: %v", hook.url, err)
+		case <-w.clock().After(backoff):
+		}
+		backoff *= 2
+		err = sendWebhook(hook, body)
+	}
+	if err != nil {
+		return fmt.Errorf("rediswatcher: webhook %s: %v", hook.url, err)
+	}
+	return nil
+}
+
+func sendWebhook(hook webhook, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hook.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.secret))
+		mac.Write(body)
+		req.Header.Set("X-Rediswatcher-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package rediswatcher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	type delivery struct {
+		body      []byte
+		signature string
+	}
+	deliveries := make(chan delivery, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		deliveries <- delivery{body, r.Header.Get("X-Rediswatcher-Signature")}
+	}))
+	defer server.Close()
+
+	w := &Watcher{closed: make(chan struct{})}
+	defer close(w.closed)
+	Webhook(server.URL, "s3cret")(&w.options)
+	w.startWebhooks()
+	w.runCallback("node2")
+
+	select {
+	case d := <-deliveries:
+		var e Event
+		if err := json.Unmarshal(d.body, &e); err != nil || e.Message != "node2" || e.Sender != "node2" {
+			t.Fatalf("Update should be posted as an Event, got %s", d.body)
+		}
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(d.body)
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); d.signature != want {
+			t.Fatalf("Body should be signed, got %s instead of %s", d.signature, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Update was not posted")
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	attempts := make(chan struct{}, webhookAttempts)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		attempts <- struct{}{}
+		rw.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	w := &Watcher{closed: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		done <- w.postWebhook(webhook{url: server.URL}, []byte("{}"))
+	}()
+	<-attempts
+	close(w.closed)
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Failed delivery should be reported")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Retries should stop when the watcher closes")
+	}
+}
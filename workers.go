@@ -0,0 +1,139 @@
+package rediswatcher
+
+import "sync/atomic"
+
+// startCallbackWorkers starts the CallbackWorkers pool consuming jobs. The
+// queue decouples callbacks from the receive loop, so Redis keeps being read
+// while a callback runs and the server side output buffer does not fill up.
+func (w *Watcher) startCallbackWorkers() {
+	if w.options.CallbackWorkers <= 0 {
+		return
+	}
+
+	workers := w.options.CallbackWorkers
+	if w.options.OrderedDelivery {
+		workers = 1
+	}
+	w.jobs = make(chan job, w.options.CallbackQueueSize)
+	w.startSpillDrain()
+	for i := 0; i < workers; i++ {
+		w.spawn(func() {
+			for {
+				select {
+				case <-w.closed:
+					return
+				case j := <-w.jobs:
+					j, next := w.mergeQueued(j)
+					w.runJob(j)
+					if next != nil {
+						w.runJob(*next)
+					}
+				}
+			}
+		})
+	}
+}
+
+// OverflowPolicy decides what happens to an update when the callback queue
+// is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops receiving until a worker frees a slot.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest queued update.
+	OverflowDropOldest
+	// OverflowCollapse drops every queued update, leaving only the new one,
+	// so a burst results in a single reload.
+	OverflowCollapse
+	// OverflowSpill stores updates in Redis until there is room, see
+	// OverflowSpillList.
+	OverflowSpill
+)
+
+// mergeQueued merges the jobs waiting behind j into it, up to ReceiveBatch
+// in all. A routed job ends the merge and is returned to run after.
+func (w *Watcher) mergeQueued(j job) (job, *job) {
+	if j.channel != "" || j.control != "" {
+		return j, nil
+	}
+	for n := 1; n < w.options.ReceiveBatch; n++ {
+		var next job
+		select {
+		case next = <-w.jobs:
+		default:
+			return j, nil
+		}
+		if next.channel != "" || next.control != "" {
+			return j, &next
+		}
+		queued, merged := j.messages(), next.messages()
+		batch := make([]string, 0, len(queued)+len(merged))
+		for _, msg := range queued {
+			if !containsString(merged, msg) {
+				batch = append(batch, msg)
+			}
+		}
+		j = job{msg: next.msg, batch: append(batch, merged...), delivery: next.delivery}
+	}
+	return j, nil
+}
+
+// dispatch hands data to the worker pool, or runs the callback inline when
+// there is none.
+func (w *Watcher) dispatch(data string) {
+	w.dispatchJob(singleJob(data))
+}
+
+func (w *Watcher) dispatchJob(j job) {
+	if w.jobs == nil {
+		w.runJob(j)
+		return
+	}
+
+	if w.options.OverflowPolicy == OverflowSpill && w.spillJob(j) {
+		return
+	}
+	if w.options.OverflowPolicy == OverflowBlock || w.options.OverflowPolicy == OverflowSpill {
+		select {
+		case w.jobs <- j:
+		case <-w.closed:
+		}
+		return
+	}
+
+	for {
+		select {
+		case w.jobs <- j:
+			return
+		default:
+		}
+
+		// queue full, make room according to the policy
+	drain:
+		for {
+			select {
+			case old := <-w.jobs:
+				atomic.AddInt64(&w.counters.dropped, 1)
+				if w.options.overflowCallback != nil {
+					w.options.overflowCallback(old.msg)
+				}
+				if w.options.OverflowPolicy == OverflowDropOldest {
+					break drain
+				}
+			default:
+				break drain
+			}
+		}
+	}
+}
+
+// containsString reports whether list holds s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
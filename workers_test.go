@@ -0,0 +1,188 @@
+package rediswatcher
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallbackWorkers(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	CallbackWorkers(2)(&w.options)
+	w.startCallbackWorkers()
+	defer close(w.closed)
+
+	release := make(chan struct{})
+	started := make(chan string, 2)
+	w.SetUpdateCallback(func(msg string) {
+		started <- msg
+		<-release
+	})
+
+	w.dispatch("node2")
+	w.dispatch("node3")
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("Both callbacks should run concurrently on the pool")
+		}
+	}
+	close(release)
+}
+
+func TestCallbackQueue(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	CallbackWorkers(1)(&w.options)
+	CallbackQueueSize(4)(&w.options)
+	w.startCallbackWorkers()
+	defer close(w.closed)
+
+	release := make(chan struct{})
+	ch := make(chan string, 4)
+	w.SetUpdateCallback(func(msg string) {
+		<-release
+		ch <- msg
+	})
+
+	// a blocked callback must not block dispatching
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, id := range []string{"node2", "node3", "node4"} {
+			w.dispatch(id)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Dispatch blocked on a running callback")
+	}
+
+	close(release)
+	for _, want := range []string{"node2", "node3", "node4"} {
+		if res := <-ch; res != want {
+			t.Fatalf("A single worker should keep the order, expected '%s', got '%s'", want, res)
+		}
+	}
+}
+
+func TestOrderedDelivery(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	CallbackWorkers(4)(&w.options)
+	CallbackQueueSize(8)(&w.options)
+	CallbackTimeout(5 * time.Millisecond)(&w.options)
+	OrderedDelivery(true)(&w.options)
+	w.startCallbackWorkers()
+	defer close(w.closed)
+
+	var running int32
+	ch := make(chan string, 8)
+	w.SetUpdateCallback(func(msg string) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			t.Error("Callbacks must not overlap in ordered mode")
+		}
+		time.Sleep(10 * time.Millisecond) // past the timeout
+		atomic.AddInt32(&running, -1)
+		ch <- msg
+	})
+
+	ids := []string{"node2", "node3", "node4", "node5"}
+	for _, id := range ids {
+		w.dispatch(id)
+	}
+	for _, want := range ids {
+		select {
+		case res := <-ch:
+			if res != want {
+				t.Fatalf("Expected '%s', got '%s'", want, res)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Ordered callbacks were not delivered")
+		}
+	}
+}
+
+func TestQueueOverflow(t *testing.T) {
+	for _, test := range []struct {
+		policy  OverflowPolicy
+		dropped string
+		queued  string
+	}{
+		{OverflowDropOldest, "[node2]", "[node3 node4]"},
+		{OverflowCollapse, "[node2 node3]", "[node4]"},
+	} {
+		var dropped []string
+		w := &Watcher{closed: make(chan struct{})}
+		QueueOverflow(test.policy, func(msg string) { dropped = append(dropped, msg) })(&w.options)
+		w.jobs = make(chan job, 2) // no workers, the queue only fills
+
+		for _, id := range []string{"node2", "node3", "node4"} {
+			w.dispatch(id)
+		}
+		close(w.jobs)
+		var queued []string
+		for j := range w.jobs {
+			queued = append(queued, j.msg)
+		}
+
+		if fmt.Sprint(dropped) != test.dropped || fmt.Sprint(queued) != test.queued {
+			t.Fatalf("Policy %d: dropped %v and queued %v", test.policy, dropped, queued)
+		}
+		if s := w.Stats(); s.DroppedUpdates != int64(len(dropped)) || s.QueueCapacity != 2 {
+			t.Fatalf("Policy %d: Stats should count %d drops, got %+v", test.policy, len(dropped), s)
+		}
+	}
+}
+
+func TestReceiveBatch(t *testing.T) {
+	w := &Watcher{closed: make(chan struct{})}
+	CallbackWorkers(1)(&w.options)
+	CallbackQueueSize(8)(&w.options)
+	ReceiveBatch(8)(&w.options)
+	w.startCallbackWorkers()
+	defer close(w.closed)
+
+	started := make(chan struct{}, 4)
+	release := make(chan struct{})
+	updates := make(chan string, 4)
+	batches := make(chan []string, 4)
+	w.SetUpdateCallback(func(msg string) {
+		started <- struct{}{}
+		<-release
+		updates <- msg
+	})
+	w.SetBatchCallback(func(msgs []string) error {
+		batches <- msgs
+		return nil
+	})
+
+	// the worker holds node2 while the rest is queued
+	w.dispatch("node2")
+	<-started
+	for _, id := range []string{"node3", "node4", "node3", "node5"} {
+		w.dispatch(id)
+	}
+	close(release)
+
+	for _, want := range []string{"node2", "node5"} {
+		select {
+		case res := <-updates:
+			if res != want {
+				t.Fatalf("Expected '%s', got '%s'", want, res)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("The queued updates were not delivered")
+		}
+	}
+	<-batches
+	if res := fmt.Sprint(<-batches); res != "[node4 node3 node5]" {
+		t.Fatalf("The queued updates should merge into one deduplicated batch, got %s", res)
+	}
+	select {
+	case res := <-updates:
+		t.Fatalf("A merged batch should run the callbacks once, got '%s' again", res)
+	case <-time.After(10 * time.Millisecond):
+	}
+}